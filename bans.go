@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "gopkg.in/inconshreveable/log15.v2"
+)
+
+const legalitySnapshotFile = "legalities.json"
+const legalityHistoryFile = "legality-history.json"
+
+// How many changes we remember, and how many !bans shows
+const legalityHistoryLength = 500
+const bansShown = 8
+
+// legalityChange is a card's banned or restricted status changing in a format
+type legalityChange struct {
+	Date   string `json:"date"`
+	Card   string `json:"card"`
+	Format string `json:"format"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+// verb is what happened to the card, like banned or unrestricted
+func (lc legalityChange) verb() string {
+	switch lc.To {
+	case "banned", "restricted":
+		return lc.To
+	}
+	return "un" + lc.From
+}
+
+func (lc legalityChange) String() string {
+	f := formatFor(lc.Format)
+	switch lc.To {
+	case "banned", "restricted":
+		return fmt.Sprintf("%s is now %s in %s", lc.Card, lc.To, f.long)
+	}
+	return fmt.Sprintf("%s is no longer %s in %s", lc.Card, lc.From, f.long)
+}
+
+// legalitySnapshot is every card's banned and restricted statuses at one time.
+// Cards that aren't banned or restricted anywhere are there too, so new cards can be told from changed ones.
+type legalitySnapshot struct {
+	Taken   string                       `json:"taken"`
+	Formats []string                     `json:"formats"`
+	Cards   map[string]map[string]string `json:"cards"`
+}
+
+// isBanOrRestriction says whether a status is one we keep track of
+func isBanOrRestriction(status string) bool {
+	return status == "banned" || status == "restricted"
+}
+
+func takeLegalitySnapshot(cards []Card, now time.Time) legalitySnapshot {
+	ls := legalitySnapshot{Taken: now.Format("2006-01-02"), Cards: make(map[string]map[string]string)}
+	formats := make(map[string]bool)
+	for _, c := range cards {
+		var statuses map[string]string
+		for f, status := range c.Legalities {
+			formats[f] = true
+			if isBanOrRestriction(status) {
+				if statuses == nil {
+					statuses = make(map[string]string)
+				}
+				statuses[f] = status
+			}
+		}
+		ls.Cards[c.Name] = statuses
+	}
+	for f := range formats {
+		ls.Formats = append(ls.Formats, f)
+	}
+	sort.Strings(ls.Formats)
+	return ls
+}
+
+// diffLegalities finds the bans, restrictions and unbans between two snapshots.
+// Cards that are new, formats that are new, and cards rotating out while banned aren't changes.
+func diffLegalities(old legalitySnapshot, cards []Card, date string) []legalityChange {
+	var ret []legalityChange
+	for _, c := range cards {
+		before, known := old.Cards[c.Name]
+		if !known {
+			continue
+		}
+		for _, f := range old.Formats {
+			from, to := before[f], c.Legalities[f]
+			switch {
+			case isBanOrRestriction(to) && from != to:
+				ret = append(ret, legalityChange{Date: date, Card: c.Name, Format: f, From: nco(from, "legal"), To: to})
+			case from != "" && to == "legal":
+				ret = append(ret, legalityChange{Date: date, Card: c.Name, Format: f, From: from, To: to})
+			}
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ret[i].Format != ret[j].Format {
+			return formatOrder(ret[i].Format) < formatOrder(ret[j].Format)
+		}
+		return ret[i].Card < ret[j].Card
+	})
+	return ret
+}
+
+// formatOrder puts formats in the order we list them, with any we don't know about last
+func formatOrder(key string) int {
+	for i, f := range knownFormats {
+		if f.key == key {
+			return i
+		}
+	}
+	return len(knownFormats)
+}
+
+// legalityMonitor keeps the last snapshot and the history of changes on disk
+type legalityMonitor struct {
+	snapshotFile string
+	historyFile  string
+
+	lock    sync.Mutex
+	history []legalityChange
+}
+
+var legalities *legalityMonitor
+
+func newLegalityMonitor(snapshotFile string, historyFile string) *legalityMonitor {
+	lm := &legalityMonitor{snapshotFile: snapshotFile, historyFile: historyFile}
+	if err := readJSONFile(historyFile, &lm.history); err != nil && !os.IsNotExist(err) {
+		log.Warn("Error reading legality history", "Error", err)
+	}
+	return lm
+}
+
+// update compares the cards with the last snapshot, and once announce says any changes have gone out,
+// remembers them and takes a new snapshot. Until then the old snapshot stays, so the changes are found again next time.
+// The first time there's nothing to compare with, so nothing has changed.
+func (lm *legalityMonitor) update(cards []Card, now time.Time, announce func([]legalityChange) bool) ([]legalityChange, error) {
+	if len(cards) == 0 {
+		return nil, nil
+	}
+	lm.lock.Lock()
+	defer lm.lock.Unlock()
+	var old legalitySnapshot
+	var changes []legalityChange
+	err := readJSONFile(lm.snapshotFile, &old)
+	switch {
+	case err == nil:
+		changes = diffLegalities(old, cards, now.Format("2006-01-02"))
+	case !os.IsNotExist(err):
+		log.Warn("Error reading legality snapshot, starting again", "Error", err)
+	}
+	if len(changes) > 0 && !announce(changes) {
+		return changes, fmt.Errorf("Couldn't announce the legality changes")
+	}
+	if err := writeJSONFile(lm.snapshotFile, takeLegalitySnapshot(cards, now)); err != nil {
+		return changes, err
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	lm.history = append(lm.history, changes...)
+	if len(lm.history) > legalityHistoryLength {
+		lm.history = lm.history[len(lm.history)-legalityHistoryLength:]
+	}
+	return changes, writeJSONFile(lm.historyFile, lm.history)
+}
+
+// recent are the latest changes in a format, newest first
+func (lm *legalityMonitor) recent(f gameFormat, n int) []legalityChange {
+	lm.lock.Lock()
+	defer lm.lock.Unlock()
+	var ret []legalityChange
+	for i := len(lm.history) - 1; i >= 0 && len(ret) < n; i-- {
+		if lm.history[i].Format == f.key {
+			ret = append(ret, lm.history[i])
+		}
+	}
+	return ret
+}
+
+// How many changes are announced one by one, rather than summed up a format at a time
+const legalityChangesListed = 3
+
+// formatLegalityChanges announces a few changes one by one, or sums up more like "Modern: X banned, Y unbanned"
+func formatLegalityChanges(changes []legalityChange) string {
+	if len(changes) <= legalityChangesListed {
+		var ret []string
+		for _, lc := range changes {
+			ret = append(ret, lc.String())
+		}
+		return strings.Join(ret, " · ")
+	}
+	var formats []string
+	byFormat := make(map[string][]string)
+	for _, lc := range changes {
+		if _, ok := byFormat[lc.Format]; !ok {
+			formats = append(formats, lc.Format)
+		}
+		byFormat[lc.Format] = append(byFormat[lc.Format], lc.Card+" "+lc.verb())
+	}
+	ret := []string{"Banned and restricted update"}
+	for _, f := range formats {
+		ret = append(ret, fmt.Sprintf("%s: %s", formatFor(f).long, strings.Join(byFormat[f], ", ")))
+	}
+	return strings.Join(ret, " · ")
+}
+
+// checkLegalities looks for changes in a fresh copy of the bulk data, and tells the channels that want to know
+func checkLegalities(ci *CardIndex) {
+	if legalities == nil {
+		return
+	}
+	if _, err := legalities.update(ci.Cards(), time.Now().UTC(), announceLegalityChanges); err != nil {
+		log.Warn("Error updating legalities", "Error", err)
+	}
+}
+
+// announceLegalityChanges tells the channels that want to know, and says whether any of them heard
+func announceLegalityChanges(changes []legalityChange) bool {
+	if len(conf.LegalityChannels) == 0 {
+		return true
+	}
+	announced := false
+	for _, channel := range conf.LegalityChannels {
+		if err := postToChannel(channel, formatLegalityChanges(changes)); err != nil {
+			log.Warn("Error announcing legality changes", "Channel", channel, "Error", err)
+			continue
+		}
+		announced = true
+	}
+	if announced {
+		legalityChanges.Add(int64(len(changes)))
+	}
+	return announced
+}
+
+// The legality check at startup waits until there's somewhere to announce the changes
+var startupLegalityCheck sync.Once
+
+// announcesLegalitiesOnIRC says whether the startup legality check has to wait for us to join a channel
+func announcesLegalitiesOnIRC() bool {
+	for _, channel := range conf.LegalityChannels {
+		if isIRCChannel(channel) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleBansQuery lists the recent changes to a format's banned and restricted list
+func handleBansQuery(params *fryatogParams, tokens []string) string {
+	if legalities == nil {
+		return "Not keeping track of bans"
+	}
+	f, ok := lookupFormat(strings.Join(tokens, " "))
+	if !ok {
+		return "!bans <format>"
+	}
+	changes := legalities.recent(f, bansShown)
+	if len(changes) == 0 {
+		return fmt.Sprintf("No recent changes in %s", f.long)
+	}
+	ret := []string{f.long}
+	for _, lc := range changes {
+		ret = append(ret, fmt.Sprintf("%s %s %s", lc.Date, lc.Card, lc.verb()))
+	}
+	return strings.Join(ret, " · ")
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func legalityCard(name string, legalities map[string]string) Card {
+	return Card{Name: name, Legalities: legalities}
+}
+
+func announced([]legalityChange) bool {
+	return true
+}
+
+func TestLegalityMonitor(t *testing.T) {
+	dir := t.TempDir()
+	lm := newLegalityMonitor(filepath.Join(dir, "legalities.json"), filepath.Join(dir, "history.json"))
+	before := []Card{
+		legalityCard("Ponder", map[string]string{"modern": "not_legal", "legacy": "legal", "vintage": "restricted", "standard": "not_legal"}),
+		legalityCard("Grief", map[string]string{"modern": "legal", "legacy": "legal", "vintage": "legal", "standard": "not_legal"}),
+		legalityCard("Sorin, Imperious Bloodlord", map[string]string{"modern": "legal", "legacy": "legal", "vintage": "legal", "standard": "banned"}),
+	}
+	changes, err := lm.update(before, time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC), announced)
+	if err != nil || len(changes) != 0 {
+		t.Fatalf("The first snapshot shouldn't have changes -- got %v %v", changes, err)
+	}
+
+	after := []Card{
+		// Unrestricted, and banned somewhere new
+		legalityCard("Ponder", map[string]string{"modern": "not_legal", "legacy": "banned", "vintage": "legal", "standard": "not_legal", "timeless": "banned"}),
+		legalityCard("Grief", map[string]string{"modern": "banned", "legacy": "legal", "vintage": "legal", "standard": "not_legal"}),
+		// Rotating out while banned isn't an unban
+		legalityCard("Sorin, Imperious Bloodlord", map[string]string{"modern": "legal", "legacy": "legal", "vintage": "legal", "standard": "not_legal"}),
+		// New cards aren't changes
+		legalityCard("Brand New", map[string]string{"modern": "banned"}),
+	}
+	// Nobody heard, so the changes are still new next time
+	changes, err = lm.update(after, time.Date(2026, time.October, 8, 0, 0, 0, 0, time.UTC), func([]legalityChange) bool { return false })
+	if err == nil || len(changes) != 3 {
+		t.Fatalf("Incorrect unannounced changes -- got %v %v", changes, err)
+	}
+	changes, err = lm.update(after, time.Date(2026, time.October, 8, 0, 0, 0, 0, time.UTC), announced)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := []string{"Grief is now banned in Modern", "Ponder is now banned in Legacy", "Ponder is no longer restricted in Vintage"}
+	if len(changes) != len(want) {
+		t.Fatalf("Incorrect changes -- got %v -- want %v", changes, want)
+	}
+	for i, lc := range changes {
+		if lc.String() != want[i] {
+			t.Errorf("Incorrect change -- got %q -- want %q", lc.String(), want[i])
+		}
+	}
+	if got, want := formatLegalityChanges(changes), "Grief is now banned in Modern · Ponder is now banned in Legacy · Ponder is no longer restricted in Vintage"; got != want {
+		t.Errorf("Incorrect announcement -- got %q -- want %q", got, want)
+	}
+	many := append(changes, legalityChange{Card: "Opt", Format: "modern", From: "legal", To: "banned"})
+	if got, want := formatLegalityChanges(many), "Banned and restricted update · Modern: Grief banned, Opt banned · Legacy: Ponder banned · Vintage: Ponder unrestricted"; got != want {
+		t.Errorf("Incorrect announcement -- got %q -- want %q", got, want)
+	}
+
+	// Nothing new the next time
+	if changes, _ := lm.update(after, time.Date(2026, time.October, 9, 0, 0, 0, 0, time.UTC), announced); len(changes) != 0 {
+		t.Errorf("Incorrect changes -- got %v", changes)
+	}
+
+	// The history survives a restart
+	legalities = newLegalityMonitor(filepath.Join(dir, "legalities.json"), filepath.Join(dir, "history.json"))
+	defer func() { legalities = nil }()
+	tables := []struct {
+		input  string
+		output string
+	}{
+		{"modern", "Modern · 2026-10-08 Grief banned"},
+		{"vintage", "Vintage · 2026-10-08 Ponder unrestricted"},
+		{"pauper", "No recent changes in Pauper"},
+		{"nonsense", "!bans <format>"},
+	}
+	for _, table := range tables {
+		if got := handleBansQuery(&fryatogParams{}, []string{table.input}); got != table.output {
+			t.Errorf("Incorrect output for %s -- got %q -- want %q", table.input, got, table.output)
+		}
+	}
+}
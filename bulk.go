@@ -0,0 +1,307 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	raven "github.com/getsentry/raven-go"
+	log "gopkg.in/inconshreveable/log15.v2"
+)
+
+const bulkDataFile = "bulkcards.json"
+const scryfallBulkDataAPIURL = "https://api.scryfall.com/bulk-data/%s"
+const defaultBulkDataType = "oracle-cards"
+
+// The bulk files are big, so give them longer than a normal request
+const bulkDataDownloadTimeout = 10 * time.Minute
+
+// How often to re-download the bulk card data
+var bulkDataRefreshTimer = 24 * time.Hour
+
+// BulkDataInfo represents the JSON returned by the /bulk-data Scryfall API
+type BulkDataInfo struct {
+	Object      string `json:"object"`
+	Type        string `json:"type"`
+	UpdatedAt   string `json:"updated_at"`
+	DownloadURI string `json:"download_uri"`
+	Size        int    `json:"size"`
+}
+
+// CardIndex is an in-memory index over Scryfall's bulk card data,
+// so we can answer card lookups without going to the network.
+// It never changes once it's built, so it's safe to share; a refresh builds a new one.
+type CardIndex struct {
+	cards  []Card
+	byName map[string]int
+	// Sorted normalised names (including individual face names) for prefix and fuzzy matching
+	names []string
+}
+
+func newCardIndex(cards []Card) *CardIndex {
+	ci := &CardIndex{cards: cards, byName: make(map[string]int)}
+	for i, c := range cards {
+		keys := []string{normaliseCardName(c.Name)}
+		for _, cf := range c.CardFaces {
+			keys = append(keys, normaliseCardName(cf.Name))
+		}
+		for _, k := range keys {
+			if k == "" {
+				continue
+			}
+			// Prefer real cards over tokens, art cards and the like of the same name
+			if existing, ok := ci.byName[k]; ok && !IsDumbCard(cards[existing]) {
+				continue
+			}
+			if _, ok := ci.byName[k]; !ok {
+				ci.names = append(ci.names, k)
+			}
+			ci.byName[k] = i
+		}
+	}
+	sort.Strings(ci.names)
+	return ci
+}
+
+// Len returns the number of cards in the index, and is safe to call on a nil index.
+func (ci *CardIndex) Len() int {
+	if ci == nil {
+		return 0
+	}
+	return len(ci.cards)
+}
+
+// Cards returns the whole corpus of indexed cards.
+func (ci *CardIndex) Cards() []Card {
+	if ci == nil {
+		return nil
+	}
+	return ci.cards
+}
+
+// lookup finds a card by exact, normalised, short, prefix or fuzzy name, in that order.
+func (ci *CardIndex) lookup(input string) (Card, error) {
+	if ci.Len() == 0 {
+		return Card{}, fmt.Errorf("Card index is empty")
+	}
+	ncn := normaliseCardName(input)
+	if qualifiedName, ok := shortCardNames[ncn]; ok {
+		ncn = normaliseCardName(qualifiedName)
+	}
+	if ncn == "" {
+		return Card{}, fmt.Errorf("Card not found")
+	}
+
+	// Exact (normalised) name
+	if i, ok := ci.byName[ncn]; ok {
+		return ci.cards[i], nil
+	}
+
+	// Unique prefix, or something legendary-ish
+	start := sort.SearchStrings(ci.names, ncn)
+	var prefixed []string
+	seen := make(map[int]bool)
+	for _, n := range ci.names[start:] {
+		if !strings.HasPrefix(n, ncn) {
+			break
+		}
+		// A face name and the full name both point at the same card
+		if i := ci.byName[n]; !seen[i] {
+			seen[i] = true
+			prefixed = append(prefixed, ci.cards[i].Name)
+		}
+	}
+	if len(prefixed) == 1 {
+		return ci.cards[ci.byName[normaliseCardName(prefixed[0])]], nil
+	}
+	if j := pickLegendaryish(prefixed); j != "" {
+		cardUniquePrefixHits.Add(1)
+		return ci.cards[ci.byName[normaliseCardName(j)]], nil
+	}
+	if len(prefixed) > 0 {
+		return Card{}, fmt.Errorf("Too many cards match %s", input)
+	}
+
+	// Typos -- closest name by edit distance, as long as it's unambiguous
+	maxDistance := max(1, len(ncn)/5)
+	bestDistance := maxDistance + 1
+	var best []string
+	for _, n := range ci.names {
+		if abs(len(n)-len(ncn)) > maxDistance {
+			continue
+		}
+		d := levenshteinDistance(n, ncn)
+		switch {
+		case d < bestDistance:
+			bestDistance = d
+			best = []string{n}
+		case d == bestDistance:
+			best = append(best, n)
+		}
+	}
+	if len(best) == 1 {
+		return ci.cards[ci.byName[best[0]]], nil
+	}
+	return Card{}, fmt.Errorf("Card not found")
+}
+
+// getIndexedCard looks up a card in the local bulk data index.
+// It has the same shape as CardSource.Named, so it can be dropped in anywhere one is used.
+func getIndexedCard(input string, isLang bool) (Card, error) {
+	indexRequests.Add(1)
+	// The bulk data is English only
+	if isLang {
+		return Card{}, fmt.Errorf("Card index has no foreign cards")
+	}
+	card, err := cardIndex.Load().lookup(input)
+	if err != nil {
+		return Card{}, err
+	}
+	if IsDumbCard(card) {
+		return Card{}, fmt.Errorf("Dumb card returned, keep trying")
+	}
+	indexHits.Add(1)
+	return card, nil
+}
+
+func getBulkDataType(conf *configuration) string {
+	if conf.BulkDataType == "" {
+		return defaultBulkDataType
+	}
+	return conf.BulkDataType
+}
+
+// bulkDataHasEveryPrinting says whether the bulk data has every printing of each card, rather than just one,
+// which set and rarity searches need to give the right answer
+func bulkDataHasEveryPrinting() bool {
+	switch strings.ReplaceAll(getBulkDataType(&conf), "_", "-") {
+	case "default-cards", "all-cards":
+		return true
+	}
+	return false
+}
+
+// fetchBulkDataInfo finds out where to download a type of bulk data from
+func fetchBulkDataInfo(dataType string) (BulkDataInfo, error) {
+	var bdi BulkDataInfo
+	infoURL := fmt.Sprintf(scryfallBulkDataAPIURL, dataType)
+	log.Debug("FetchBulkData: Attempting to fetch", "URL", infoURL)
+	resp, err := upstream.Get(infoURL)
+	if err != nil {
+		raven.CaptureError(err, nil)
+		log.Warn("FetchBulkData: The HTTP request failed", "Error", err)
+		return bdi, fmt.Errorf("Something went wrong fetching the bulk data information")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		log.Warn("FetchBulkData: Scryfall returned a non-200", "Status Code", resp.StatusCode)
+		return bdi, fmt.Errorf("Scryfall returned a non-200")
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&bdi); err != nil {
+		raven.CaptureError(err, nil)
+		return bdi, fmt.Errorf("Something went wrong parsing the bulk data information")
+	}
+	return bdi, nil
+}
+
+func fetchBulkData() error {
+	return downloadBulkData(getBulkDataType(&conf), bulkDataFile)
+}
+
+// downloadBulkData saves the latest of a type of bulk data to a file
+func downloadBulkData(dataType string, path string) error {
+	bdi, err := fetchBulkDataInfo(dataType)
+	if err != nil {
+		return err
+	}
+
+	log.Debug("FetchBulkData: Attempting to fetch", "URL", bdi.DownloadURI, "Updated", bdi.UpdatedAt)
+	dl, err := upstream.GetWithTimeout(bdi.DownloadURI, bulkDataDownloadTimeout)
+	if err != nil {
+		raven.CaptureError(err, nil)
+		log.Warn("FetchBulkData: The HTTP request failed", "Error", err)
+		return fmt.Errorf("Something went wrong fetching the bulk data")
+	}
+	defer dl.Body.Close()
+	if dl.StatusCode != 200 {
+		log.Warn("FetchBulkData: Scryfall returned a non-200", "Status Code", dl.StatusCode)
+		return fmt.Errorf("Scryfall returned a non-200")
+	}
+	// Write to a temporary file first so a failed download doesn't clobber a good one
+	out, err := os.Create(path + ".tmp")
+	if err != nil {
+		raven.CaptureError(err, nil)
+		return err
+	}
+	if _, err = io.Copy(out, dl.Body); err != nil {
+		out.Close()
+		log.Warn("FetchBulkData: Error writing to bulk data file", "Error", err)
+		return err
+	}
+	out.Close()
+	return os.Rename(path+".tmp", path)
+}
+
+// loadCardIndex parses a Scryfall bulk data file, one card at a time, into an index.
+func loadCardIndex(path string) (*CardIndex, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		raven.CaptureError(err, nil)
+		log.Warn("Error opening bulk data file", "Error", err)
+		return nil, err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	// Opening [
+	if _, err := dec.Token(); err != nil {
+		raven.CaptureError(err, nil)
+		return nil, fmt.Errorf("Something went wrong parsing the bulk data")
+	}
+	var cards []Card
+	for dec.More() {
+		var c Card
+		if err := dec.Decode(&c); err != nil {
+			raven.CaptureError(err, nil)
+			log.Warn("Error parsing bulk data file", "Error", err)
+			return nil, fmt.Errorf("Something went wrong parsing the bulk data")
+		}
+		cards = append(cards, c)
+	}
+	log.Debug("Finished loading bulk data", "Length", len(cards))
+	return newCardIndex(cards), nil
+}
+
+func importBulkData(forceFetch bool) (*CardIndex, error) {
+	log.Debug("In importBulkData", "Forced?", forceFetch)
+	if forceFetch {
+		if err := fetchBulkData(); err != nil {
+			log.Warn("Error fetching bulk data", "Error", err)
+			return nil, err
+		}
+	}
+	if _, err := os.Stat(bulkDataFile); err != nil {
+		if err := fetchBulkData(); err != nil {
+			log.Warn("Error fetching bulk data", "Error", err)
+			return nil, err
+		}
+	}
+	return loadCardIndex(bulkDataFile)
+}
+
+func refreshBulkDataTimer() {
+	for {
+		time.Sleep(bulkDataRefreshTimer)
+		log.Debug("Refreshing bulk data")
+		ci, err := importBulkData(true)
+		if err != nil {
+			log.Warn("Refresh bulk data timer", "Error", err)
+			continue
+		}
+		cardIndex.Store(ci)
+		checkLegalities(ci)
+	}
+}
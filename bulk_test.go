@@ -0,0 +1,105 @@
+package main
+
+import (
+	"testing"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const testBulkDataFile = "test_data/bulk-oracle-cards.json"
+
+func TestLoadCardIndex(t *testing.T) {
+	ci, err := loadCardIndex(testBulkDataFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ci.Len() != 30 {
+		t.Errorf("Incorrect number of cards -- got %d -- want %d", ci.Len(), 30)
+	}
+	var nilIndex *CardIndex
+	if nilIndex.Len() != 0 {
+		t.Errorf("Nil index should be empty")
+	}
+}
+
+func TestIndexedCardLookup(t *testing.T) {
+	ci, err := loadCardIndex(testBulkDataFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cardIndex.Store(ci)
+	defer cardIndex.Store(nil)
+	if err = importShortCardNames(); err != nil {
+		t.Errorf("Error importing short card names: %v", err)
+	}
+	tables := []struct {
+		input   string
+		isLang  bool
+		output  string
+		wanterr bool
+	}{
+		{"Ponder", false, "Ponder", false},
+		{"ponder", false, "Ponder", false},
+		{"Jace, the Mind Sculptor", false, "Jace, the Mind Sculptor", false},
+		{"jace the mind sculptor", false, "Jace, the Mind Sculptor", false},
+		{"jtms", false, "Jace, the Mind Sculptor", false},
+		{"Explosion", false, "Expansion // Explosion", false},
+		{"Insectile", false, "", true},
+		{"Tarmogof", false, "Tarmogoyf", false},
+		{"Tarmogoyff", false, "Tarmogoyf", false},
+		{"Thermo Alchemist", false, "Thermo-Alchemist", false},
+		{"Shahraz", false, "Shahrazad", false},
+		{"Jace", false, "", true},
+		{"Nicol", false, "Nicol Bolas, the Ravager // Nicol Bolas, the Arisen", false},
+		{"Handy Dandy Clone Machine", false, "", true},
+		{"Ponder", true, "", true},
+		{"Lightning Bolt", false, "", true},
+		{"To", false, "", true},
+	}
+	for _, table := range tables {
+		got, err := getIndexedCard(table.input, table.isLang)
+		if (err != nil) != table.wanterr {
+			t.Errorf("Unexpected error for %s: %v", table.input, err)
+		}
+		if got.Name != table.output {
+			t.Errorf("Incorrect output for %s -- got %s -- want %s", table.input, got.Name, table.output)
+		}
+	}
+}
+
+func TestLevenshteinDistance(t *testing.T) {
+	tables := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"ponder", "ponder", 0},
+		{"ponder", "pondr", 1},
+		{"tarmogoyf", "tarmogof", 1},
+		{"kitten", "sitting", 3},
+		{"", "abc", 3},
+	}
+	for _, table := range tables {
+		if got := levenshteinDistance(table.a, table.b); got != table.want {
+			t.Errorf("Incorrect distance between %s and %s -- got %d -- want %d", table.a, table.b, got, table.want)
+		}
+	}
+}
+
+func TestNamedFallsBackToScryfall(t *testing.T) {
+	var err error
+	nameToCardCache, err = lru.NewARC(2048)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	// An index from before Tarmogoyf was printed
+	cardIndex.Store(newCardIndex([]Card{{Name: "Ponder", Lang: "en"}}))
+	defer cardIndex.Store(nil)
+	source := newFakeScryfall(t)
+	for i := 0; i < 2; i++ {
+		card, err := source.Named("Tarmogoyf", false)
+		if err != nil || card.Name != "Tarmogoyf" {
+			t.Errorf("Incorrect card not in the index -- got %q %v -- want Tarmogoyf", card.Name, err)
+		}
+	}
+}
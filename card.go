@@ -1,16 +1,15 @@
 package main
 
 import (
-	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math"
-	"net/http"
+	"math/rand"
 	"net/url"
 	"os"
 	"reflect"
-	"strconv"
 	"strings"
 	"time"
 
@@ -19,80 +18,115 @@ import (
 )
 
 const namesFile = "names.json"
-const pointsFile = "points.txt"
-const scryfallNamesAPIURL = "https://api.scryfall.com/catalog/card-names"
-const scryfallFuzzyAPIURL = "https://api.scryfall.com/cards/named?fuzzy=%s"
-const scryfallRandomAPIURL = "https://api.scryfall.com/cards/random"
-const scryfallSearchAPIURL = "https://api.scryfall.com/cards/search"
+const scryfallAPIURL = "https://api.scryfall.com"
+const scryfallNamesAPIURL = scryfallAPIURL + "/catalog/card-names"
+const scryfallFuzzyAPIPath = "/cards/named?fuzzy=%s"
+const scryfallRandomAPIPath = "/cards/random"
+const scryfallSearchAPIPath = "/cards/search"
+const scryfallPrintingAPIPath = "/cards/%s/%s"
+const scryfallCardAPIPath = "/cards/%s"
+const scryfallSetAPIPath = "/sets/%s"
 const highlanderPointsURL = "http://decklist.mtgpairings.info/js/cards/highlander.txt"
 
 const noFlavourText = "Flavour text not found"
 const fullyShownSearchThreshold = 2 // limit for how many search results are fully displayed (inclusive)
 const shownSearchThreshold = 20     // limit for how many search results are listed as names (inclusive)
 
+// scryfallSource is the CardSource backed by the Scryfall API, with our caches and the local index in front of it.
+type scryfallSource struct {
+	baseURL  string
+	client   *upstreamClient
+	prefetch *prefetcher
+}
+
+func newScryfallSource(baseURL string) *scryfallSource {
+	return &scryfallSource{
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		client:   upstream,
+		prefetch: newPrefetcher(prefetchWorkers, prefetchQueueLength),
+	}
+}
+
 // TODO: Also CardFaces
-func (card *Card) getExtraMetadata(inputURL string) {
+func (card *Card) getExtraMetadata(source CardSource) {
 	log.Debug("Getting Metadata")
 	// This is called even for empty Card objects, do don't do anything in that case
 	if card.ID == "" {
 		return
 	}
-	fetchURL := card.PrintsSearchURI
 	var cm CardMetadata
 	// Already have metadata?
 	if !reflect.DeepEqual(card.Metadata, cm) {
 		return
 	}
-	// Use parameter over stored, for recursive lists
-	if inputURL != "" {
-		fetchURL = inputURL
-	}
-	// Have a url?
-	if fetchURL == "" {
-		return
-	}
 	metadataRequests.Add(1)
-	log.Debug("GetExtraMetadata: Attempting to fetch", "URL", fetchURL)
-	resp, err := http.Get(fetchURL)
+	// Every page, so the flavour texts and printings go all the way back to the first
+	prints, err := source.Prints(card, false, true)
 	if err != nil {
-		raven.CaptureError(err, nil)
-		log.Warn("GetExtraMetadata: The HTTP request failed", "Error", err)
+		log.Info("GetExtraMetadata: Unable to get printings", "Error", err)
 		return
 	}
-	defer resp.Body.Close()
-	var list CardList
-	if resp.StatusCode == 200 {
-		if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
-			raven.CaptureError(err, nil)
-			return
+	// These are in printing order, since the prints_search_uri includes "order=released"
+	for _, c := range prints {
+		if c.ID == card.ID {
+			continue
 		}
-		if len(list.Warnings) > 0 {
-			return
+		if c.FlavourText != "" {
+			cm.PreviousFlavourTexts = append(cm.PreviousFlavourTexts, c.FlavourText)
 		}
-		if list.HasMore {
-			defer card.getExtraMetadata(list.NextPage)
+		cm.PreviousPrintings = append(cm.PreviousPrintings, c.formatExpansions())
+
+		if card.getReminderTexts() == "Reminder text not found" && c.getReminderTexts() != "Reminder text not found" {
+			cm.PreviousReminderTexts = append(cm.PreviousReminderTexts, c.getReminderTexts())
 		}
-		// These are in printing order, since the prints_search_uri includes "order=released"
-		for _, c := range list.Data {
-			if c.ID == card.ID {
-				continue
-			}
-			if c.FlavourText != "" {
-				cm.PreviousFlavourTexts = append(cm.PreviousFlavourTexts, c.FlavourText)
-			}
-			cm.PreviousPrintings = append(cm.PreviousPrintings, c.formatExpansions())
+	}
+	card.Metadata = cm
+	// Update the Cache ???? Necessary ?
+	nameToCardCache.Add(normaliseCardName(card.Name), *card)
+	// log.Debug("After metadata extraction", "Card", card)
+}
 
-			if card.getReminderTexts() == "Reminder text not found" && c.getReminderTexts() != "Reminder text not found" {
-				cm.PreviousReminderTexts = append(cm.PreviousReminderTexts, c.getReminderTexts())
-			}
+// Prints fetches the first page of a card's printings, or with allPages, every page of them.
+func (s *scryfallSource) Prints(card *Card, multilingual bool, allPages bool) ([]Card, error) {
+	// Have a url?
+	if card.PrintsSearchURI == "" {
+		return []Card{}, fmt.Errorf("No URL")
+	}
+	fetchURL := card.PrintsSearchURI
+	if multilingual {
+		fetchURL += "&include_multilingual=true"
+	}
+	var prints []Card
+	for fetchURL != "" {
+		log.Debug("Prints: Attempting to fetch", "URL", fetchURL)
+		resp, err := s.client.Get(fetchURL)
+		if err != nil {
+			raven.CaptureError(err, nil)
+			log.Warn("Prints: The HTTP request failed", "Error", err)
+			return []Card{}, fmt.Errorf("Error fetching results")
+		}
+		if resp.StatusCode != 200 {
+			resp.Body.Close()
+			log.Info("Prints: Scryfall returned a non-200", "Status Code", resp.StatusCode)
+			return []Card{}, fmt.Errorf("Unable to fetch printings from Scryfall")
+		}
+		var list CardList
+		err = json.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		if err != nil {
+			raven.CaptureError(err, nil)
+			return []Card{}, fmt.Errorf("Error decoding results")
+		}
+		if len(list.Warnings) > 0 {
+			return []Card{}, fmt.Errorf("Warnings in response")
+		}
+		prints = append(prints, list.Data...)
+		fetchURL = ""
+		if list.HasMore && allPages {
+			fetchURL = list.NextPage
 		}
-		card.Metadata = cm
-		// Update the Cache ???? Necessary ?
-		nameToCardCache.Add(normaliseCardName(card.Name), *card)
-		// log.Debug("After metadata extraction", "Card", card)
-		return
 	}
-	log.Info("GetExtraMetadata: Scryfall returned a non-200", "Status Code", resp.StatusCode)
+	return prints, nil
 }
 
 // Get all possible most recent reminder texts for a card, \n separated
@@ -109,18 +143,22 @@ func (card Card) getReminderTexts() string {
 		}
 
 	}
-	reminders := reminderRegexp.FindAllStringSubmatch(cardText, -1)
-	if len(reminders) == 0 {
+	ret := reminderTextsIn(cardText)
+	if len(ret) == 0 {
 		if len(card.Metadata.PreviousReminderTexts) > 0 {
 			return card.Metadata.PreviousReminderTexts[0]
 		}
 		return "Reminder text not found"
 	}
+	return strings.Join(ret, "\n")
+}
+
+func reminderTextsIn(cardText string) []string {
 	var ret []string
-	for _, m := range reminders {
+	for _, m := range reminderRegexp.FindAllStringSubmatch(cardText, -1) {
 		ret = append(ret, m[1])
 	}
-	return strings.Join(ret, "\n")
+	return ret
 }
 
 // Get the most recent Flavour Text that exists
@@ -138,9 +176,8 @@ func (card *Card) getFlavourText() string {
 	return noFlavourText
 }
 
-// TODO: This isn't great right now since it gets both sides' flavour text.
-// It's ~fine, but in a perfect world if I request the flavour text of the
-// back side that's all I'd get back.
+// This gets both sides' flavour text, asking for a face by name
+// goes through getFaceFlavourText instead and gets just that side.
 func getDfcFlavourText(card *Card) string {
 	dfcFlavour := ""
 	var parts []string
@@ -216,6 +253,11 @@ func (cc CommonCard) getCardOrFaceAsString(mode string) []string {
 }
 
 func (card *Card) formatCardForSlack() string {
+	return card.formatCardForSlackWithPoints(defaultPointsList())
+}
+
+// formatCardForSlackWithPoints shows what the card costs in the given points list
+func (card *Card) formatCardForSlackWithPoints(pl *pointsList) string {
 	var s []string
 	if len(card.CardFaces) > 0 {
 		for _, cf := range card.CardFaces {
@@ -232,13 +274,22 @@ func (card *Card) formatCardForSlack() string {
 	if card.Reserved {
 		s = append(s, "· [RL] ·")
 	}
-	if points, ok := highlanderPoints[normaliseCardName(card.Name)]; ok {
-		s = append(s, fmt.Sprintf("[:point_right: %d :point_left:]", points))
+	if m := card.meldLine(); m != "" {
+		// The [RL] already ends with a separator
+		if !card.Reserved {
+			m = "· " + m
+		}
+		s = append(s, m)
 	}
-	return strings.Join(s, " ")
+	return pl.withPoints(strings.Join(s, " "), card.Name, false)
 }
 
 func (card *Card) formatCardForIRC() string {
+	return card.formatCardForIRCWithFormats(defaultLegalityFormats)
+}
+
+// formatCardForIRCWithFormats shows legality in just the given formats
+func (card *Card) formatCardForIRCWithFormats(formats []string) string {
 	var s []string
 	if len(card.CardFaces) > 0 {
 		// DFC and Flip and Split - produce two cards
@@ -249,7 +300,7 @@ func (card *Card) formatCardForIRC() string {
 			r = append(r, cf.CommonCard.getCardOrFaceAsString("irc")...)
 			if cf.ManaCost != "" {
 				r = append(r, fmt.Sprintf("· %s ·", card.formatExpansions()))
-				r = append(r, card.formatLegalities())
+				r = append(r, card.formatLegalities(formats))
 			}
 			s = append(s, strings.Join(r, " "))
 		}
@@ -263,71 +314,168 @@ func (card *Card) formatCardForIRC() string {
 	if card.Reserved {
 		s = append(s, "[RL] ·")
 	}
-	s = append(s, card.formatLegalities())
+	s = append(s, card.formatLegalities(formats))
+	if m := card.meldLine(); m != "" {
+		s = append(s, "· "+m)
+	}
 
 	return strings.Join(s, " ")
 }
 
-func (card *Card) getRulings(rulingNumber int) string {
+// How many rulings go in a reply when we're not asked for one in particular
+const rulingsPerPage = 3
+
+// rulingQuery is what's wanted from a card's rulings: one by its number, or a page of the ones mentioning all the keywords
+type rulingQuery struct {
+	number   int
+	keywords []string
+	page     int
+}
+
+// numberedRuling is an official ruling with the number people cite it by, which is its place in Gatherer order
+type numberedRuling struct {
+	CardRuling
+	Number int
+}
+
+func (nr numberedRuling) format() string {
+	return fmt.Sprintf("[%d] %s", nr.Number, nr.formatRuling())
+}
+
+func (card *Card) getRulings(source CardSource, rulingNumber int) string {
+	return card.getFaceRulings(source, rulingNumber, -1)
+}
+
+// getFaceRulings leaves out the rulings that are only about the other faces of the card, unless face is -1
+func (card *Card) getFaceRulings(source CardSource, rulingNumber int, face int) string {
+	return card.queryFaceRulings(source, rulingQuery{number: rulingNumber}, face)
+}
+
+// officialRulings are the card's rulings from Wizards, numbered the same whichever face was asked for
+func (card *Card) officialRulings(face int) []numberedRuling {
+	var ret []numberedRuling
+	i := 0
+	for _, r := range card.Rulings {
+		if r.Source != "wotc" {
+			continue
+		}
+		i++
+		if !card.rulingIsAboutOtherFace(r, face) {
+			ret = append(ret, numberedRuling{CardRuling: r, Number: i})
+		}
+	}
+	return ret
+}
+
+// rulingMentions says whether a ruling has every one of the keywords in it
+func rulingMentions(r CardRuling, keywords []string) bool {
+	comment := strings.ToLower(r.Comment)
+	for _, k := range keywords {
+		if !strings.Contains(comment, strings.ToLower(k)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (card *Card) queryFaceRulings(source CardSource, query rulingQuery, face int) string {
 	rulingRequests.Add(1)
-	// Do we already have the Rulings?
-	if card.Rulings == nil {
+	// Do we already have the Rulings? The stored ones win over any copy the card has, and aren't kept in it
+	stored := haveStoredRulings() && card.OracleID != ""
+	if card.Rulings == nil || stored {
 		// If we don't, fetch them
-		err := (card).fetchRulings()
+		err := card.fetchRulings(source)
 		if err != nil {
 			return "Problem fetching the rulings"
 		}
 		// Update the Cache ???? Necessary ?
-		nameToCardCache.Add(normaliseCardName(card.Name), *card)
+		if !stored {
+			nameToCardCache.Add(normaliseCardName(card.Name), *card)
+		}
 	}
 	// Now we have them
-	var ret []string
-	i := 0
-	for _, r := range card.Rulings {
-		if r.Source == "wotc" {
-			i++
-			// Do we want a specific ruling?
-			if rulingNumber > 0 && i == rulingNumber {
+	if query.number > 0 {
+		// A number is for the whole card, so it's the same ruling whichever face was asked for
+		for _, r := range card.officialRulings(-1) {
+			if r.Number == query.number {
 				return r.formatRuling()
 			}
-			ret = append(ret, r.formatRuling())
 		}
+		return "Ruling not found"
 	}
-	if rulingNumber > 0 || len(ret) == 0 {
+	var found []numberedRuling
+	for _, r := range card.officialRulings(face) {
+		if rulingMentions(r.CardRuling, query.keywords) {
+			found = append(found, r)
+		}
+	}
+	if len(found) == 0 {
+		if len(query.keywords) > 0 {
+			return fmt.Sprintf("No rulings mention %s", strings.Join(query.keywords, " "))
+		}
 		return "Ruling not found"
 	}
-	if len(ret) > 3 {
-		return fmt.Sprintf("Too many rulings (%d), please request a specific one", len(ret))
+	pages := (len(found) + rulingsPerPage - 1) / rulingsPerPage
+	page := max(query.page, 1)
+	if page > pages {
+		return fmt.Sprintf("Page %d not found, there are %d", page, pages)
+	}
+	var ret []string
+	for _, r := range found[(page-1)*rulingsPerPage : min(page*rulingsPerPage, len(found))] {
+		ret = append(ret, r.format())
+	}
+	if pages > 1 {
+		more := strings.TrimSpace(strings.Join(append([]string{card.Name}, query.keywords...), " "))
+		if page < pages {
+			ret = append(ret, fmt.Sprintf("Page %d of %d · !ruling %s page %d for more", page, pages, more, page+1))
+		} else {
+			ret = append(ret, fmt.Sprintf("Page %d of %d", page, pages))
+		}
 	}
 	return strings.Join(ret, "\n")
 }
 
-func (card *Card) fetchRulings() error {
+func (card *Card) fetchRulings(source CardSource) error {
+	rulings, ok := lookupStoredRulings(card.OracleID)
+	if !ok {
+		var err error
+		rulings, err = source.Rulings(card)
+		if err != nil {
+			return err
+		}
+	}
+	card.Rulings = rulings
+	err := card.sortRulings()
+	if err != nil {
+		return fmt.Errorf("Error sorting rules")
+	}
+	return nil
+}
+
+// Rulings fetches the rulings for a card, in the order Scryfall has them.
+func (s *scryfallSource) Rulings(card *Card) ([]CardRuling, error) {
+	if card.RulingsURI == "" {
+		return nil, fmt.Errorf("No URL")
+	}
 	log.Debug("FetchRulings: Attempting to fetch", "URL", card.RulingsURI)
-	resp, err := http.Get(card.RulingsURI)
+	resp, err := s.client.Get(card.RulingsURI)
 	if err != nil {
 		raven.CaptureError(err, nil)
 		log.Warn("FetchRulings: The HTTP request failed", "Error", err)
-		return fmt.Errorf("Something went wrong fetching the rulings")
+		return nil, fmt.Errorf("Something went wrong fetching the rulings")
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode == 200 {
 		var crr CardRulingResult
 		if err := json.NewDecoder(resp.Body).Decode(&crr); err != nil {
 			raven.CaptureError(err, nil)
-			return fmt.Errorf("Something went wrong parsing the rulings")
+			return nil, fmt.Errorf("Something went wrong parsing the rulings")
 		}
-
-		card.Rulings = crr.Data
-		err = card.sortRulings()
-		if err != nil {
-			return fmt.Errorf("Error sorting rules")
-		}
-		return nil
+		return crr.Data, nil
 	}
 
 	log.Info("FetchRulings: Scryfall returned a non-200", "Status Code", resp.StatusCode)
-	return fmt.Errorf("Unable to fetch rulings from Scryfall")
+	return nil, fmt.Errorf("Unable to fetch rulings from Scryfall")
 }
 
 func (card *Card) sortRulings() error {
@@ -343,20 +491,6 @@ func (card *Card) sortRulings() error {
 		return err
 	}
 
-	// Special case for Mairsil, the Pretender
-	// Scryfall doesn't acknowledge Gatherer ruling order as canonical,
-	// so their "ruling 11" is Gatherer's "ruling 1." Yes, I hate this.
-	if card.Name == "Mairsil, the Pretender" {
-		// Since append requires a slice as its first arg, so we can't just do card.Rulings[11]
-		// We can't use negative indices in Go like we can in Python, so we get a slice that's
-		// just the last object in the list.
-		card.Rulings = append(card.Rulings[len(card.Rulings)-1:], card.Rulings...)
-		// Get a slice that drops the last ruling, since we just moved it to the front of the list
-		// and we don't want it duplicated.
-		card.Rulings = card.Rulings[:len(card.Rulings)-1]
-		return nil
-	}
-
 	for _, ruling := range card.Rulings {
 		rulingDate, err := time.Parse("2006-01-02", ruling.PublishedAt)
 		if err != nil {
@@ -372,53 +506,40 @@ func (card *Card) sortRulings() error {
 		rulingsChunk = append(rulingsChunk, ruling)
 	}
 	sortedRulings = append(rulingsChunk, sortedRulings...)
+	// Scryfall doesn't acknowledge Gatherer ruling order as canonical, so some cards need telling
+	if order, ok := lookupRulingOrder(nco(card.OracleID, card.Rulings[0].OracleID)); ok {
+		sortedRulings = orderRulings(sortedRulings, order)
+	}
 	card.Rulings = sortedRulings
 	return nil
 }
 
-func (card *Card) cardGetLang(lang string) (Card, error) {
+// Language finds the printing of a card in the given language.
+func (s *scryfallSource) Language(card *Card, lang string) (Card, error) {
 	log.Debug("Getting Card Languages")
 	var c Card
 	// This is called even for empty Card objects, do don't do anything in that case
 	if card.ID == "" {
 		return c, fmt.Errorf("Empty card")
 	}
-	fetchURL := card.PrintsSearchURI + "&include_multilingual=true"
-	// Have a url?
-	if fetchURL == "" {
-		return c, fmt.Errorf("No URL")
-	}
-	log.Debug("cardGetLang: Attempting to fetch", "URL", fetchURL)
-	resp, err := http.Get(fetchURL)
+	prints, err := s.Prints(card, true, false)
 	if err != nil {
-		raven.CaptureError(err, nil)
-		log.Warn("cardGetLang: The HTTP request failed", "Error", err)
-		return c, fmt.Errorf("Error fetching results")
+		return c, err
 	}
-	defer resp.Body.Close()
-	var list CardList
-	if resp.StatusCode == 200 {
-		if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
-			raven.CaptureError(err, nil)
-			return c, fmt.Errorf("Error decoding results")
-		}
-		if len(list.Warnings) > 0 {
-			return c, fmt.Errorf("Warnings in response")
-		}
-		for _, cs := range list.Data {
-			if cs.Lang == lang {
-				return cs, nil
-			}
+	for _, cs := range prints {
+		if cs.Lang == lang {
+			return cs, nil
 		}
 	}
 	return c, fmt.Errorf("Language not found")
 }
 
-func fetchScryfallCardByFuzzyName(input string, isLang bool) (Card, error) {
+// Fuzzy asks Scryfall for its best guess at a card name, skipping our caches.
+func (s *scryfallSource) Fuzzy(input string, isLang bool) (Card, error) {
 	var emptyCard Card
-	url := fmt.Sprintf(scryfallFuzzyAPIURL, url.QueryEscape(input))
+	url := s.baseURL + fmt.Sprintf(scryfallFuzzyAPIPath, url.QueryEscape(input))
 	log.Debug("fetchScryfallCard: Attempting to fetch", "URL", url)
-	resp, err := http.Get(url)
+	resp, err := s.client.Get(url)
 	if err != nil {
 		raven.CaptureError(err, nil)
 		log.Warn("fetchScryfallCard: The HTTP request failed", "Error", err)
@@ -433,7 +554,7 @@ func fetchScryfallCardByFuzzyName(input string, isLang bool) (Card, error) {
 		}
 		if !isLang && card.Lang != "en" {
 			log.Debug("Got back a foreign card when it wasn't requested, let's try again")
-			return fetchScryfallCardByFuzzyName(card.Name, false)
+			return s.Fuzzy(card.Name, false)
 		}
 		if IsDumbCard(card) {
 			return emptyCard, fmt.Errorf("Dumb card returned, keep trying")
@@ -444,6 +565,202 @@ func fetchScryfallCardByFuzzyName(input string, isLang bool) (Card, error) {
 	return card, fmt.Errorf("Card not found by Scryfall")
 }
 
+// Printing finds one particular printing of a card, by set and collector number if we have it,
+// or by name within the set if we don't.
+func (s *scryfallSource) Printing(input string, set string, number string) (Card, error) {
+	printingRequests.Add(1)
+	set = strings.ToLower(set)
+	number = strings.ToLower(number)
+	key := printingCacheKey(input, set, number)
+	if cached, found := printingCache.Get(key); found {
+		return cached.(Card), nil
+	}
+
+	var u string
+	if number != "" {
+		u = s.baseURL + fmt.Sprintf(scryfallPrintingAPIPath, url.PathEscape(set), url.PathEscape(number))
+	} else {
+		u = s.baseURL + fmt.Sprintf(scryfallFuzzyAPIPath, url.QueryEscape(input)) + "&set=" + url.QueryEscape(set)
+	}
+	log.Debug("Printing: Attempting to fetch", "URL", u)
+	resp, err := s.client.Get(u)
+	if err != nil {
+		raven.CaptureError(err, nil)
+		log.Warn("Printing: The HTTP request failed", "Error", err)
+		return Card{}, fmt.Errorf("Something went wrong fetching the card")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		// Misses aren't cached, the printing could be previewed any minute
+		log.Info("Printing: Scryfall returned a non-200", "Status Code", resp.StatusCode)
+		return Card{}, fmt.Errorf("Printing not found")
+	}
+	var card Card
+	if err := json.NewDecoder(resp.Body).Decode(&card); err != nil {
+		raven.CaptureError(err, nil)
+		return Card{}, fmt.Errorf("Something went wrong parsing the card")
+	}
+	printingCache.Add(key, card)
+	// Asking by name and by number should both find it next time
+	printingCache.Add(printingCacheKey("", card.Set, card.CollectorNumber), card)
+	return card, nil
+}
+
+// Related fetches a card by its ID, without the checks Named does, since tokens and the like are what's wanted.
+// We build the URL ourselves rather than following the one we were given.
+func (s *scryfallSource) Related(part RelatedCard) (Card, error) {
+	relatedRequests.Add(1)
+	if cached, found := relatedCardCache.Get(part.ID); found {
+		return cached.(Card), nil
+	}
+	u := s.baseURL + fmt.Sprintf(scryfallCardAPIPath, url.PathEscape(part.ID))
+	log.Debug("Related: Attempting to fetch", "URL", u)
+	resp, err := s.client.Get(u)
+	if err != nil {
+		raven.CaptureError(err, nil)
+		log.Warn("Related: The HTTP request failed", "Error", err)
+		return Card{}, fmt.Errorf("Something went wrong fetching %s", part.Name)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		log.Info("Related: Scryfall returned a non-200", "Status Code", resp.StatusCode)
+		return Card{}, fmt.Errorf("%s not found", part.Name)
+	}
+	var card Card
+	if err := json.NewDecoder(resp.Body).Decode(&card); err != nil {
+		raven.CaptureError(err, nil)
+		return Card{}, fmt.Errorf("Something went wrong parsing %s", part.Name)
+	}
+	relatedCardCache.Add(part.ID, card)
+	return card, nil
+}
+
+// Tokens searches for tokens by name, which Named never finds since they're not legal anywhere
+func (s *scryfallSource) Tokens(name string, pt string) ([]Card, error) {
+	u, _ := url.Parse(s.baseURL + scryfallSearchAPIPath)
+	q := u.Query()
+	q.Add("q", tokenSearchQuery(name, pt))
+	q.Add("include_extras", "true")
+	u.RawQuery = q.Encode()
+	log.Debug("Tokens: Attempting to fetch", "URL", u)
+	resp, err := s.client.Get(u.String())
+	if err != nil {
+		raven.CaptureError(err, nil)
+		log.Warn("Tokens: The HTTP request failed", "Error", err)
+		return nil, fmt.Errorf("Something went wrong fetching tokens")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		log.Info("Tokens: Scryfall returned a non-200", "Status Code", resp.StatusCode)
+		return nil, fmt.Errorf("No tokens found")
+	}
+	var csr CardSearchResult
+	if err := json.NewDecoder(resp.Body).Decode(&csr); err != nil {
+		raven.CaptureError(err, nil)
+		return nil, fmt.Errorf("Something went wrong parsing the tokens")
+	}
+	return csr.Data, nil
+}
+
+// SetCards fetches every card printed in a set, from the set's own search, and saves them to disk for next time
+func (s *scryfallSource) SetCards(set string) ([]Card, error) {
+	set = strings.ToLower(set)
+	saved, fresh, err := readSetCards(set)
+	if err == nil && fresh {
+		return saved, nil
+	}
+	cards, err := s.fetchSetCards(set)
+	if err != nil {
+		// Old cards are better than none, if Scryfall isn't answering
+		if saved != nil && !errors.Is(err, errNoCardsFound) {
+			log.Info("SetCards: Using saved cards", "Set", set, "Error", err)
+			return saved, nil
+		}
+		return nil, err
+	}
+	if err := writeSetCards(set, cards); err != nil {
+		log.Warn("SetCards: Error saving set cards", "Set", set, "Error", err)
+	}
+	return cards, nil
+}
+
+func (s *scryfallSource) fetchSetCards(set string) ([]Card, error) {
+	cs, ok := lookupSet(set)
+	if !ok {
+		// Newer than our set list, maybe
+		u := s.baseURL + fmt.Sprintf(scryfallSetAPIPath, url.PathEscape(set))
+		log.Debug("fetchSetCards: Attempting to fetch", "URL", u)
+		resp, err := s.client.Get(u)
+		if err != nil {
+			raven.CaptureError(err, nil)
+			log.Warn("fetchSetCards: The HTTP request failed", "Error", err)
+			return nil, fmt.Errorf("Something went wrong fetching %s", strings.ToUpper(set))
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != 200 {
+			log.Info("fetchSetCards: Scryfall returned a non-200", "Status Code", resp.StatusCode)
+			if resp.StatusCode == 404 {
+				return nil, fmt.Errorf("%w in %s", errNoCardsFound, strings.ToUpper(set))
+			}
+			return nil, fmt.Errorf("Something went wrong fetching %s", strings.ToUpper(set))
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&cs); err != nil {
+			raven.CaptureError(err, nil)
+			return nil, fmt.Errorf("Something went wrong parsing %s", strings.ToUpper(set))
+		}
+	}
+	if cs.SearchURI == "" {
+		return nil, fmt.Errorf("%w in %s", errNoCardsFound, strings.ToUpper(set))
+	}
+	return s.fetchAllPages(cs.SearchURI, strings.ToUpper(set))
+}
+
+// errNoCardsFound means Scryfall answered, and the search found nothing
+var errNoCardsFound = errors.New("No cards found")
+
+// searchAll runs a search straight against Scryfall, following its pagination, for when we want every result.
+// What's being searched for, like a set code, goes in the errors.
+func (s *scryfallSource) searchAll(query url.Values, what string) ([]Card, error) {
+	u, _ := url.Parse(s.baseURL + scryfallSearchAPIPath)
+	u.RawQuery = query.Encode()
+	return s.fetchAllPages(u.String(), what)
+}
+
+// fetchAllPages follows a search Scryfall gave us the URL of, through every page of it
+func (s *scryfallSource) fetchAllPages(fetchURL string, what string) ([]Card, error) {
+	var cards []Card
+	for fetchURL != "" {
+		log.Debug("fetchAllPages: Attempting to fetch", "URL", fetchURL)
+		resp, err := s.client.Get(fetchURL)
+		if err != nil {
+			raven.CaptureError(err, nil)
+			log.Warn("fetchAllPages: The HTTP request failed", "Error", err)
+			return nil, fmt.Errorf("Something went wrong fetching %s", what)
+		}
+		if resp.StatusCode != 200 {
+			resp.Body.Close()
+			log.Info("fetchAllPages: Scryfall returned a non-200", "Status Code", resp.StatusCode)
+			if resp.StatusCode == 404 {
+				return nil, fmt.Errorf("%w in %s", errNoCardsFound, what)
+			}
+			return nil, fmt.Errorf("Something went wrong fetching %s", what)
+		}
+		var csr CardSearchResult
+		err = json.NewDecoder(resp.Body).Decode(&csr)
+		resp.Body.Close()
+		if err != nil {
+			raven.CaptureError(err, nil)
+			return nil, fmt.Errorf("Something went wrong parsing %s", what)
+		}
+		cards = append(cards, csr.Data...)
+		fetchURL = ""
+		if csr.HasMore {
+			fetchURL = csr.NextPage
+		}
+	}
+	return cards, nil
+}
+
 func IsDumbCard(card Card) bool {
 	releaseTime, err := time.Parse("2006-01-02", card.ReleasedAt)
 	if err != nil {
@@ -455,21 +772,21 @@ func IsDumbCard(card Card) bool {
 	// Dumb cards are "Not Legal" (not legal, banned, nor restricted) in any format (we test vintage for simplicity)
 	// Preview cards are not legal anywhere until their release by default, so they cannot be counted as dumb
 	// We also make an exception for Dungeons, which are available in the normal search despite technically being tokens
-	return card.Legalities.Vintage == "not_legal" &&
+	return card.Legalities["vintage"] == "not_legal" &&
 		(card.Reprint || releaseTime.Before(time.Now())) &&
 		!strings.Contains(card.TypeLine, "Dungeon")
 }
 
-func fetchDumbScryfallCardByName(input string, isLang bool) (Card, error) {
+func (s *scryfallSource) fetchDumbCardByName(input string, isLang bool) (Card, error) {
 	var emptyCard Card
-	u, _ := url.Parse(scryfallSearchAPIURL)
+	u, _ := url.Parse(s.baseURL + scryfallSearchAPIPath)
 	q := u.Query()
 	q.Add("include_extras", "true")
 	queryString := "(border:silver or is:funny or st:funny or t:scheme or t:vanguard or t:plane or t:phenomenon) " + input
 	q.Add("q", queryString)
 	u.RawQuery = q.Encode()
 	log.Debug("searchScryfallCard: Attempting to fetch", "URL", u)
-	resp, err := http.Get(u.String())
+	resp, err := s.client.Get(u.String())
 	if err != nil {
 		raven.CaptureError(err, nil)
 		log.Warn("searchDumbScryfallCard: The HTTP request failed", "Error", err)
@@ -526,7 +843,7 @@ func checkCacheForCard(ncn string) (Card, error) {
 	return emptyCard, fmt.Errorf("Card not found in cache")
 }
 
-func getCachedOrStoreCard(card *Card, ncn string) (Card, error) {
+func getCachedOrStoreCard(source CardSource, card *Card, ncn string) (Card, error) {
 	log.Debug("In GCOSC", "Card Name", card.Name, "ncn", ncn)
 	cNcn := normaliseCardName(card.Name)
 	// If it's a foreign card, store the foreign name
@@ -534,7 +851,7 @@ func getCachedOrStoreCard(card *Card, ncn string) (Card, error) {
 		cNcn = normaliseCardName(card.PrintedName)
 	}
 
-	card.getExtraMetadata("")
+	card.getExtraMetadata(source)
 	// Remember what they typed
 	nameToCardCache.Add(ncn, *card)
 
@@ -559,7 +876,8 @@ func getCachedOrStoreCard(card *Card, ncn string) (Card, error) {
 	return *card, nil
 }
 
-func getScryfallCard(input string, isLang bool) (Card, error) {
+// Named finds a card by name, trying the cache and the local index before asking Scryfall.
+func (s *scryfallSource) Named(input string, isLang bool) (Card, error) {
 	cardRequests.Add(1)
 	var card Card
 
@@ -579,19 +897,29 @@ func getScryfallCard(input string, isLang bool) (Card, error) {
 		return card, err
 	}
 
+	// If we have the bulk data, it knows nearly every card, so try it before bothering Scryfall.
+	// Anything newer than the last download is only on Scryfall, so a miss still goes there.
+	if !isLang && cardIndex.Load().Len() > 0 {
+		log.Debug("Checking local index for card", "Name", ncn)
+		card, err = getIndexedCard(input, isLang)
+		if err == nil {
+			return getCachedOrStoreCard(s, &card, ncn)
+		}
+	}
+
 	log.Debug("Checking Scryfall for card", "Name", ncn)
 	// Try fuzzily matching the name
-	card, err = fetchScryfallCardByFuzzyName(input, isLang)
+	card, err = s.Fuzzy(input, isLang)
 
 	if err == nil {
-		return getCachedOrStoreCard(&card, ncn)
+		return getCachedOrStoreCard(s, &card, ncn)
 	}
 	// No luck - try unique prefix
 	cardName := lookupUniqueNamePrefix(input)
 	if cardName != "" {
-		card, err = fetchScryfallCardByFuzzyName(cardName, isLang)
+		card, err = s.Fuzzy(cardName, isLang)
 		if err == nil {
-			return getCachedOrStoreCard(&card, ncn)
+			return getCachedOrStoreCard(s, &card, ncn)
 		}
 	}
 	// Store the empty result
@@ -599,7 +927,8 @@ func getScryfallCard(input string, isLang bool) (Card, error) {
 	return card, fmt.Errorf("No card found")
 }
 
-func getDumbScryfallCard(input string, isLang bool) (Card, error) {
+// Dumb finds the cards that Named filters out: un-cards, vanguards, planes and schemes.
+func (s *scryfallSource) Dumb(input string, isLang bool) (Card, error) {
 	dumbCardRequests.Add(1)
 	var card Card
 	ncn := normaliseCardName(input)
@@ -611,25 +940,41 @@ func getDumbScryfallCard(input string, isLang bool) (Card, error) {
 	}
 
 	log.Debug("Checking Scryfall for card", "Name", ncn)
-	card, err = fetchDumbScryfallCardByName(input, isLang)
+	card, err = s.fetchDumbCardByName(input, isLang)
 	if err == nil {
 		return card, nil
 	}
 	return card, fmt.Errorf("No card found")
 }
 
-func getRandomScryfallCard(cardTokens []string) (Card, error) {
+// Random picks a random card matching the query, or any card at all if there's no query.
+func (s *scryfallSource) Random(cardTokens []string) (Card, error) {
 	randomRequests.Add(1)
 	var card Card
-	u, _ := url.Parse(scryfallRandomAPIURL)
+	u, _ := url.Parse(s.baseURL + scryfallRandomAPIPath)
 	if len(cardTokens) > 0 {
 		q := u.Query()
 		q.Set("q", strings.Join(cardTokens, " "))
 		u.RawQuery = q.Encode()
 	}
 
+	// Pick from the local index if we can evaluate the query ourselves
+	if cardIndex.Load().Len() > 0 {
+		csr, err := searchLocalCards(strings.Join(cardTokens, " "))
+		if err == nil {
+			if csr.Status == 400 || csr.TotalCards == 0 {
+				return card, fmt.Errorf("Error retrieving card")
+			}
+			card = csr.Data[rand.Intn(len(csr.Data))]
+			// The metadata means fetching the printings, so leave it to the prefetcher rather than wait on Scryfall
+			s.cacheSearchResults(CardSearchResult{Data: []Card{card}})
+			return card, nil
+		}
+		log.Debug("GetRandomScryfallCard: Falling back to Scryfall", "Reason", err)
+	}
+
 	log.Debug("GetRandomScryfallCard: Attempting to fetch", "URL", u.String())
-	resp, err := http.Get(u.String())
+	resp, err := s.client.Get(u.String())
 	if err != nil {
 		raven.CaptureError(err, nil)
 		log.Error("getRandomScryfallCard: The HTTP request failed", "Error", err)
@@ -641,7 +986,7 @@ func getRandomScryfallCard(cardTokens []string) (Card, error) {
 			raven.CaptureError(err, nil)
 			return card, fmt.Errorf("Something went wrong parsing the card")
 		}
-		card.getExtraMetadata("")
+		card.getExtraMetadata(s)
 		nameToCardCache.Add(normaliseCardName(card.Name), card)
 		return card, nil
 	}
@@ -649,15 +994,28 @@ func getRandomScryfallCard(cardTokens []string) (Card, error) {
 	return card, fmt.Errorf("Error retrieving card")
 }
 
-func searchScryfallCard(cardTokens []string) ([]Card, error) {
+// Search runs a Scryfall search query, returning either the cards or an error summarising them.
+func (s *scryfallSource) Search(cardTokens []string) ([]Card, error) {
 	searchRequests.Add(1)
-	// TODO: Validate Search Parameters
-	u, _ := url.Parse(scryfallSearchAPIURL)
+	// Evaluate the query ourselves if we can
+	if cardIndex.Load().Len() > 0 {
+		csr, err := searchLocalCards(strings.Join(cardTokens, " "))
+		if err == nil {
+			if csr.Status == 400 {
+				return []Card{}, searchResultError(csr)
+			}
+			log.Debug("searchScryfallCard: Local", "Total cards found", csr.TotalCards)
+			s.cacheSearchResults(csr)
+			return ParseAndFormatSearchResults(csr)
+		}
+		log.Debug("searchScryfallCard: Falling back to Scryfall", "Reason", err)
+	}
+	u, _ := url.Parse(s.baseURL + scryfallSearchAPIPath)
 	q := u.Query()
 	q.Add("q", strings.Join(cardTokens, " "))
 	u.RawQuery = q.Encode()
 	log.Debug("searchScryfallCard: Attempting to fetch", "URL", u)
-	resp, err := http.Get(u.String())
+	resp, err := s.client.Get(u.String())
 	if err != nil {
 		raven.CaptureError(err, nil)
 		log.Warn("searchScryfallCard: The HTTP request failed", "Error", err)
@@ -672,6 +1030,7 @@ func searchScryfallCard(cardTokens []string) ([]Card, error) {
 			return []Card{}, fmt.Errorf("Something went wrong parsing the card search results")
 		}
 		log.Debug("searchScryfallCard", "Total cards found", csr.TotalCards)
+		s.cacheSearchResults(csr)
 		return ParseAndFormatSearchResults(csr)
 	}
 	// This is the general "please learn scryfall syntax" reply
@@ -681,26 +1040,30 @@ func searchScryfallCard(cardTokens []string) ([]Card, error) {
 			return []Card{}, fmt.Errorf("Something went wrong parsing the card search results")
 		}
 		log.Error("searchScryfallCard: Scryfall returned 400, handling")
-		return []Card{}, fmt.Errorf("%v (%v)", csr.Details, strings.Join(csr.Warnings, " "))
+		return []Card{}, searchResultError(csr)
 	}
 
 	log.Error("searchScryfallCard: Scryfall returned a non-200, non-400", "Status Code", resp.StatusCode)
 	return []Card{}, fmt.Errorf("No cards found")
 }
 
-func ParseAndFormatSearchResults(csr CardSearchResult) ([]Card, error) {
-	if len(csr.Data) <= shownSearchThreshold {
-		for _, c := range csr.Data {
-			x := c
-			cNcn := normaliseCardName(c.Name)
-			// Sneakily add all these to the Cache
-			if _, ok := nameToCardCache.Peek(cNcn); !ok {
-				go func(cp *Card, cNcn string) {
-					_, _ = getCachedOrStoreCard(cp, cNcn)
-				}(&x, cNcn)
-			}
+// cacheSearchResults sneakily adds any results we're about to show to the Cache
+func (s *scryfallSource) cacheSearchResults(csr CardSearchResult) {
+	if len(csr.Data) > shownSearchThreshold {
+		return
+	}
+	for _, c := range csr.Data {
+		x := c
+		cNcn := normaliseCardName(c.Name)
+		if _, ok := nameToCardCache.Peek(cNcn); !ok {
+			s.prefetch.submit(func() {
+				_, _ = getCachedOrStoreCard(s, &x, cNcn)
+			})
 		}
 	}
+}
+
+func ParseAndFormatSearchResults(csr CardSearchResult) ([]Card, error) {
 	if csr.Warnings != nil {
 		return []Card{}, fmt.Errorf("%s", strings.Join(csr.Warnings, " "))
 	}
@@ -730,7 +1093,7 @@ func fetchCardNames() error {
 		return err
 	}
 	log.Debug("FetchCardNames: Attempting to fetch", "URL", scryfallNamesAPIURL)
-	resp, err := http.Get(scryfallNamesAPIURL)
+	resp, err := upstream.Get(scryfallNamesAPIURL)
 	if err != nil {
 		raven.CaptureError(err, nil)
 		log.Warn("FetchCardNames: The HTTP request failed", "Error", err)
@@ -779,78 +1142,7 @@ func importCardNames(forceFetch bool) ([]string, error) {
 		return []string{}, fmt.Errorf("Something went wrong parsing the cardname catalog")
 	}
 	log.Debug("Finished importing", "Length", len(catalog.Data))
+	setCardNameSet(catalog.Data)
+	setSuggestionNames(catalog.Data)
 	return catalog.Data, nil
 }
-
-func fetchHighlanderPoints() error {
-	out, err := os.Create(pointsFile)
-	if err != nil {
-		return err
-	}
-	log.Debug("FetchHighlanderPoints: Attempting to fetch", "URL", highlanderPointsURL)
-	resp, err := http.Get(highlanderPointsURL)
-	if err != nil {
-		raven.CaptureError(err, nil)
-		log.Warn("FetchHighlanderPoints: The HTTP request failed", "Error", err)
-		return fmt.Errorf("Something went wrong fetching the points")
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode == 200 {
-		_, err = io.Copy(out, resp.Body)
-		if err != nil {
-			raven.CaptureError(err, nil)
-			log.Warn("FetchHighlanderPoints: Error writing to points file", "Error", err)
-			return err
-		}
-		out.Close()
-		return nil
-	}
-	log.Warn("FetchHighlanderPoints: The site returned a non-200", "Status Code", resp.StatusCode)
-	return fmt.Errorf("Points file returned a non-200")
-}
-
-func importHighlanderPoints(forceFetch bool) error {
-	log.Debug("In importHighlanderPoints", "Forced?", forceFetch)
-	if forceFetch {
-		if err := fetchHighlanderPoints(); err != nil {
-			raven.CaptureError(err, nil)
-			log.Warn("Error fetching points", "Error", err)
-			return err
-		}
-	}
-	if _, err := os.Stat(pointsFile); err != nil {
-		if err := fetchCardNames(); err != nil {
-			raven.CaptureError(err, nil)
-			log.Warn("Error fetching points", "Error", err)
-			return err
-		}
-	}
-	// Parse it.
-	f, err := os.Open(pointsFile)
-	if err != nil {
-		raven.CaptureError(err, nil)
-		log.Warn("Error opening points file", "Error", err)
-		return err
-	}
-	defer f.Close()
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		lineFields := strings.Fields(scanner.Text())
-		cardName := normaliseCardName(strings.Join(lineFields[0:len(lineFields)-1], " "))
-		points, err := strconv.Atoi(lineFields[len(lineFields)-1])
-		if err != nil {
-			log.Warn("Unable to convert points line", "Error", err)
-			continue
-		}
-		if conf.DevMode {
-			log.Debug("ImportPoints", "Cardname", cardName, "Points", points)
-		}
-		highlanderPoints[cardName] = points
-	}
-	if err := scanner.Err(); err != nil {
-		raven.CaptureError(err, nil)
-		log.Warn("Error reading points file", "Error", err)
-		return err
-	}
-	return nil
-}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
 	"os"
 	"reflect"
 	"strings"
@@ -55,97 +54,6 @@ var (
 	}
 )
 
-func (card *Card) fakeGetLangs(lang string) (Card, error) {
-	var c Card
-	var list CardList
-	fi, err := os.Open("test_data/" + normaliseCardName(card.Name) + "-langs.json")
-	if err != nil {
-		return c, fmt.Errorf("Unable to open langs JSON: %v", err)
-	}
-	if err := json.NewDecoder(fi).Decode(&list); err != nil {
-		return c, fmt.Errorf("Something went wrong parsing the card list")
-	}
-	if len(list.Warnings) > 0 {
-		return c, fmt.Errorf("Scryfall said there were errors: %v", list.Warnings)
-	}
-	// These are in printing order, since the prints_search_uri includes "order=released"
-	for _, cs := range list.Data {
-		if cs.Lang == lang {
-			return cs, nil
-		}
-	}
-	return c, fmt.Errorf("Unknown Language")
-}
-
-func (card *Card) fakeGetMetadata() error {
-	var cm CardMetadata
-	var list CardList
-	fi, err := os.Open("test_data/" + normaliseCardName(card.Name) + "-printings.json")
-	if err != nil {
-		return fmt.Errorf("Unable to open printings JSON: %v", err)
-	}
-	if err := json.NewDecoder(fi).Decode(&list); err != nil {
-		return fmt.Errorf("Something went wrong parsing the card list")
-	}
-	if len(list.Warnings) > 0 {
-		return fmt.Errorf("Scryfall said there were errors: %v", list.Warnings)
-	}
-	// These are in printing order, since the prints_search_uri includes "order=released"
-	for _, c := range list.Data {
-		if c.ID == card.ID {
-			continue
-		}
-		if c.FlavourText != "" {
-			cm.PreviousFlavourTexts = append(cm.PreviousFlavourTexts, c.FlavourText)
-		}
-		cm.PreviousPrintings = append(cm.PreviousPrintings, c.formatExpansions())
-		// Only need previous reminder text if current one doesn't have
-		if c.getReminderTexts() == "Reminder text not found" && c.getReminderTexts() != "Reminder text not found" {
-			cm.PreviousReminderTexts = append(cm.PreviousReminderTexts, c.getReminderTexts())
-		}
-	}
-	card.Metadata = cm
-	return nil
-}
-
-func (card *Card) fakeGetRulings(rulingNumber int) string {
-	var crr CardRulingResult
-	fi, err := os.Open("test_data/" + normaliseCardName(card.Name) + "-rulings.json")
-	if err != nil {
-		return fmt.Sprintf("Unable to open printings JSON: %v", err)
-	}
-	if err = json.NewDecoder(fi).Decode(&crr); err != nil {
-		return fmt.Sprintf("Something went wrong parsing the rulings: %v", err)
-	}
-
-	card.Rulings = crr.Data
-
-	err = card.sortRulings()
-	if err != nil {
-		return "Something went wrong sorting the rulings"
-	}
-
-	var ret []string
-	i := 0
-	for _, r := range card.Rulings {
-		if r.Source == "wotc" {
-			i++
-			// Do we want a specific ruling?
-			if rulingNumber > 0 && i == rulingNumber {
-				return r.formatRuling()
-			}
-			ret = append(ret, r.formatRuling())
-		}
-	}
-	if rulingNumber > 0 || len(ret) == 0 {
-		return "Ruling not found"
-	}
-	if len(ret) > 3 {
-		return "Too many rulings, please request a specific one"
-	}
-	return strings.Join(ret, "\n")
-}
-
 func TestPrintCardForIRC(t *testing.T) {
 	tables := []struct {
 		cardname string
@@ -193,8 +101,7 @@ func TestPrintCardForIRC(t *testing.T) {
 }
 
 func TestPrintCardForSlack(t *testing.T) {
-	highlanderPoints = make(map[string]int)
-	err := importHighlanderPoints(true)
+	err := defaultPointsList().importPoints(true)
 	if err != nil {
 		t.Errorf("Unexpected error: %s", err)
 	}
@@ -299,15 +206,15 @@ func TestGetRulings(t *testing.T) {
 		{TestCardWithEmptyRulings, 0, "Ruling not found"},
 		{TestCardWithEmptyRulings, 1, "Ruling not found"},
 		{TestCardWithOneNonWOTCRuling, 0, "Ruling not found"},
-		{TestCardWithOneWOTCRuling, 0, "1900-01-01: Print Me"},
+		{TestCardWithOneWOTCRuling, 0, "[1] 1900-01-01: Print Me"},
 		{TestCardWithOneWOTCRuling, 1, "1900-01-01: Print Me"},
-		{TestCardWithTwoWOTCRulings, 0, "1900-02-02: Print Me\n1900-03-03: Print Me Too!"},
+		{TestCardWithTwoWOTCRulings, 0, "[1] 1900-02-02: Print Me\n[2] 1900-03-03: Print Me Too!"},
 		{TestCardWithTwoWOTCRulings, 1, "1900-02-02: Print Me"},
 		{TestCardWithTwoWOTCRulings, 2, "1900-03-03: Print Me Too!"},
 	}
 
 	for _, table := range tables {
-		got := (table.input).getRulings(table.rulingNumber)
+		got := (table.input).getRulings(fakeCardSource{}, table.rulingNumber)
 		if got != table.output {
 			t.Errorf("Incorrect output -- got %s -- want %s", got, table.output)
 		}
@@ -332,25 +239,65 @@ func TestSortRulings(t *testing.T) {
 		{"Mairsil, the Pretender", 2, "2017-08-25: If another player gains control of Mairsil, it will have the abilities of only cards that player owns in exile with cage counters on them."},
 		{"Mairsil, the Pretender", 12, "Ruling not found"},
 	}
+	var err error
+	nameToCardCache, err = lru.NewARC(2048)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	// Mairsil's rulings are in Gatherer's order, not date order
+	if err := importRulingOrders(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	source := newFakeScryfall(t)
 
 	for _, table := range tables {
-		fi, err := os.Open(RealCards[table.cardname])
+		c, err := source.Fuzzy(table.cardname, false)
 		if err != nil {
-			t.Errorf("Unable to open %v", RealCards[table.cardname])
+			t.Errorf("Unable to fetch %v: %v", table.cardname, err)
 		}
 
-		var c Card
-		if err := json.NewDecoder(fi).Decode(&c); err != nil {
-			t.Errorf("Something went wrong parsing the card: %s", err)
-		}
-
-		got := c.fakeGetRulings(table.rulingNumber)
+		got := c.getRulings(source, table.rulingNumber)
 		if got != table.output {
 			t.Errorf("Incorrect output -- got %s -- want %s", got, table.output)
 		}
 	}
 }
 
+func TestRulingSearch(t *testing.T) {
+	tables := []struct {
+		message string
+		output  []string
+	}{
+		{"ruling Tawnos's Coffin", []string{"[1] 2004-10-04: The creature returns", "[2] 2007-09-16: If the targeted creature is a token", "[3] 2007-09-16: If the exiled card is returned", "Page 1 of 2 · !ruling Tawnos's Coffin page 2 for more"}},
+		{"ruling Tawnos's Coffin page 2", []string{"[4] 2007-09-16: If Tawnos’s Coffin leaves", "[5] 2008-04-01: Because the new wording", "[6] 2008-04-01: The effect doesn’t care", "Page 2 of 2"}},
+		{"ruling Tawnos's Coffin page 3", []string{"Page 3 not found, there are 2"}},
+		{"ruling Tawnos's Coffin AURA", []string{"[2] 2007-09-16: If the targeted creature is a token", "[3] 2007-09-16: If the exiled card is returned"}},
+		// Every word, wherever it is
+		{"ruling Tawnos's Coffin exiled card", []string{"[3] 2007-09-16: If the exiled card is returned", "[5] 2008-04-01: Because the new wording", "[6] 2008-04-01: The effect doesn’t care"}},
+		{"ruling Tawnos's Coffin banana", []string{"No rulings mention banana"}},
+	}
+	var err error
+	nameToCardCache, err = lru.NewARC(2048)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	source := newFakeScryfall(t)
+
+	for _, table := range tables {
+		got := strings.Split(handleCardMetadataQuery(&fryatogParams{message: table.message, source: source}, "ruling"), "\n")
+		if len(got) != len(table.output) {
+			t.Errorf("Incorrect output for %s -- got %q -- want %q", table.message, got, table.output)
+			continue
+		}
+		for i := range got {
+			if !strings.HasPrefix(got[i], table.output[i]) {
+				t.Errorf("Incorrect output for %s -- got %q -- want %q", table.message, got[i], table.output[i])
+			}
+		}
+	}
+
+}
+
 func TestGetPrintings(t *testing.T) {
 	tables := []struct {
 		cardname string
@@ -360,18 +307,18 @@ func TestGetPrintings(t *testing.T) {
 		{"Disenchant", "\x02Disenchant\x0F {1}{W} · Instant · Destroy target artifact or enchantment. · IMA-C,PRM-C,CN2-C,TPR-C,[...],A25-C · Vin,Cmr,Leg,Mod"},
 		{"Ral, Storm Conduit", "\x02Ral, Storm Conduit\x0F {2}{U}{R} · Legendary Planeswalker — Ral · [4] Whenever you cast or copy an instant or sorcery spell, Ral, Storm Conduit deals 1 damage to target opponent or planeswalker. \\ +2: Scry 1. \\ −2: When you cast your next instant or sorcery spell this turn, copy that spell. You may choose new targets for the copy. · WAR-R · Vin,Cmr,Leg,Mod,Std"},
 	}
+	var err error
+	nameToCardCache, err = lru.NewARC(2048)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	source := newFakeScryfall(t)
 	for _, table := range tables {
-		fi, err := os.Open(RealCards[table.cardname])
+		c, err := source.Fuzzy(table.cardname, false)
 		if err != nil {
-			t.Errorf("Unable to open %v", RealCards[table.cardname])
-		}
-		var c Card
-		if err := json.NewDecoder(fi).Decode(&c); err != nil {
-			t.Errorf("Something went wrong parsing the card: %s", err)
-		}
-		if err = c.fakeGetMetadata(); err != nil {
-			t.Errorf("Unexpected error: %v", err)
+			t.Errorf("Unable to fetch %v: %v", table.cardname, err)
 		}
+		c.getExtraMetadata(source)
 		fc := c.formatCardForIRC()
 		if fc != table.output {
 			t.Errorf("Incorrect output -- got %s -- want %s", fc, table.output)
@@ -449,12 +396,13 @@ func TestReplaceManaCostForSlack(t *testing.T) {
 }
 
 func TestImportPoints(t *testing.T) {
-	highlanderPoints = make(map[string]int)
-	err := importHighlanderPoints(false)
+	pl := defaultPointsList()
+	pl.setPoints(nil)
+	err := pl.importPoints(false)
 	if err != nil {
 		t.Errorf("Unexpected error: %s", err)
 	}
-	if len(highlanderPoints) == 0 {
+	if len(pl.points) == 0 {
 		t.Errorf("Empty Highlander points after import")
 	}
 }
@@ -470,16 +418,13 @@ func TestLangs(t *testing.T) {
 		{"Wicked Guardian", "de", "Böse Stiefmutter", false},
 		{"Wicked Guardian", "abc", "", true},
 	}
+	source := newFakeScryfall(t)
 	for _, table := range tables {
-		fi, err := os.Open(RealCards[table.cardname])
+		c, err := source.Fuzzy(table.cardname, false)
 		if err != nil {
-			t.Errorf("Unable to open %v", RealCards[table.cardname])
-		}
-		var c Card
-		if err := json.NewDecoder(fi).Decode(&c); err != nil {
-			t.Errorf("Something went wrong parsing the card: %s", err)
+			t.Errorf("Unable to fetch %v: %v", table.cardname, err)
 		}
-		tc, err := c.fakeGetLangs(table.lang)
+		tc, err := source.Language(&c, table.lang)
 		if (err != nil) != table.wanterr {
 			t.Errorf("Unexpected error: %v", err)
 		}
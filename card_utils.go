@@ -6,6 +6,7 @@ import (
 	"os"
 	"sort"
 	"strings"
+	"sync"
 
 	raven "github.com/getsentry/raven-go"
 	log "gopkg.in/inconshreveable/log15.v2"
@@ -61,97 +62,105 @@ type CommonCard struct {
 	Loyalty         string   `json:"loyalty"`
 }
 
+// ImageUris are the pictures Scryfall has of a card, or of one face of a DFC
+type ImageUris struct {
+	Small      string `json:"small"`
+	Normal     string `json:"normal"`
+	Large      string `json:"large"`
+	Png        string `json:"png"`
+	ArtCrop    string `json:"art_crop"`
+	BorderCrop string `json:"border_crop"`
+}
+
 // CardFace represents the individual information for each face of a DFC
 type CardFace struct {
 	CommonCard
-	Object         string `json:"object"`
-	Name           string `json:"name"`
-	FlavourText    string `json:"flavor_text,omitempty"`
-	PrintedName    string `json:"printed_name,omitempty"`
-	Watermark      string `json:"watermark"`
-	Artist         string `json:"artist"`
-	IllustrationID string `json:"illustration_id,omitempty"`
+	Object         string    `json:"object"`
+	Name           string    `json:"name"`
+	Colors         []string  `json:"colors"`
+	FlavourText    string    `json:"flavor_text,omitempty"`
+	PrintedName    string    `json:"printed_name,omitempty"`
+	Watermark      string    `json:"watermark"`
+	Artist         string    `json:"artist"`
+	IllustrationID string    `json:"illustration_id,omitempty"`
+	ImageUris      ImageUris `json:"image_uris,omitempty"`
+}
+
+// RelatedCard is another card that a card refers to: a token it makes, a meld partner or a combo piece
+type RelatedCard struct {
+	Object    string `json:"object"`
+	ID        string `json:"id"`
+	Component string `json:"component"`
+	Name      string `json:"name"`
+	TypeLine  string `json:"type_line"`
+	URI       string `json:"uri"`
 }
 
 // Card represents the JSON returned by the /cards Scryfall API
 type Card struct {
 	CommonCard
-	Object        string `json:"object"`
-	ID            string `json:"id"`
-	OracleID      string `json:"oracle_id"`
-	MultiverseIds []int  `json:"multiverse_ids"`
-	MtgoID        int    `json:"mtgo_id"`
-	MtgoFoilID    int    `json:"mtgo_foil_id"`
-	TcgplayerID   int    `json:"tcgplayer_id"`
-	Name          string `json:"name"`
-	PrintedName   string `json:"printed_name,omitempty"`
-	Lang          string `json:"lang"`
-	ReleasedAt    string `json:"released_at"`
-	URI           string `json:"uri"`
-	ScryfallURI   string `json:"scryfall_uri"`
-	Layout        string `json:"layout"`
-	HighresImage  bool   `json:"highres_image"`
-	ImageUris     struct {
-		Small      string `json:"small"`
-		Normal     string `json:"normal"`
-		Large      string `json:"large"`
-		Png        string `json:"png"`
-		ArtCrop    string `json:"art_crop"`
-		BorderCrop string `json:"border_crop"`
-	} `json:"image_uris"`
-	Cmc           float32    `json:"cmc"`
-	Colors        []string   `json:"colors"`
-	ColorIdentity []string   `json:"color_identity"`
-	CardFaces     []CardFace `json:"card_faces"`
-	Legalities    struct {
-		Standard  string `json:"standard"`
-		Future    string `json:"future"`
-		Frontier  string `json:"frontier"`
-		Modern    string `json:"modern"`
-		Legacy    string `json:"legacy"`
-		Pauper    string `json:"pauper"`
-		Vintage   string `json:"vintage"`
-		Penny     string `json:"penny"`
-		Commander string `json:"commander"`
-		OneV1     string `json:"1v1"`
-		Duel      string `json:"duel"`
-		Brawl     string `json:"brawl"`
-		Pioneer   string `json:"pioneer"`
-	} `json:"legalities"`
-	Games           []string `json:"games"`
-	Reserved        bool     `json:"reserved"`
-	Foil            bool     `json:"foil"`
-	Nonfoil         bool     `json:"nonfoil"`
-	Oversized       bool     `json:"oversized"`
-	Promo           bool     `json:"promo"`
-	Reprint         bool     `json:"reprint"`
-	Set             string   `json:"set"`
-	SetName         string   `json:"set_name"`
-	SetType         string   `json:"set_type"`
-	SetURI          string   `json:"set_uri"`
-	SetSearchURI    string   `json:"set_search_uri"`
-	ScryfallSetURI  string   `json:"scryfall_set_uri"`
-	RulingsURI      string   `json:"rulings_uri"`
-	PrintsSearchURI string   `json:"prints_search_uri"`
-	CollectorNumber string   `json:"collector_number"`
-	Digital         bool     `json:"digital"`
-	Rarity          string   `json:"rarity"`
-	FlavourText     string   `json:"flavor_text"`
-	IllustrationID  string   `json:"illustration_id"`
-	Artist          string   `json:"artist"`
-	BorderColor     string   `json:"border_color"`
-	Frame           string   `json:"frame"`
-	FrameEffect     string   `json:"frame_effect"`
-	FullArt         bool     `json:"full_art"`
-	Timeshifted     bool     `json:"timeshifted"`
-	Colorshifted    bool     `json:"colorshifted"`
-	Futureshifted   bool     `json:"futureshifted"`
-	StorySpotlight  bool     `json:"story_spotlight"`
-	EdhrecRank      int      `json:"edhrec_rank"`
-	Usd             string   `json:"usd"`
-	Eur             string   `json:"eur"`
-	Tix             string   `json:"tix"`
-	RelatedUris     struct {
+	Object          string            `json:"object"`
+	ID              string            `json:"id"`
+	OracleID        string            `json:"oracle_id"`
+	MultiverseIds   []int             `json:"multiverse_ids"`
+	MtgoID          int               `json:"mtgo_id"`
+	MtgoFoilID      int               `json:"mtgo_foil_id"`
+	TcgplayerID     int               `json:"tcgplayer_id"`
+	Name            string            `json:"name"`
+	PrintedName     string            `json:"printed_name,omitempty"`
+	Lang            string            `json:"lang"`
+	ReleasedAt      string            `json:"released_at"`
+	URI             string            `json:"uri"`
+	ScryfallURI     string            `json:"scryfall_uri"`
+	Layout          string            `json:"layout"`
+	HighresImage    bool              `json:"highres_image"`
+	ImageUris       ImageUris         `json:"image_uris"`
+	Cmc             float32           `json:"cmc"`
+	Colors          []string          `json:"colors"`
+	ColorIdentity   []string          `json:"color_identity"`
+	CardFaces       []CardFace        `json:"card_faces"`
+	AllParts        []RelatedCard     `json:"all_parts,omitempty"`
+	Legalities      map[string]string `json:"legalities"`
+	Games           []string          `json:"games"`
+	Reserved        bool              `json:"reserved"`
+	Foil            bool              `json:"foil"`
+	Nonfoil         bool              `json:"nonfoil"`
+	Oversized       bool              `json:"oversized"`
+	Promo           bool              `json:"promo"`
+	Reprint         bool              `json:"reprint"`
+	Booster         bool              `json:"booster"`
+	Set             string            `json:"set"`
+	SetName         string            `json:"set_name"`
+	SetType         string            `json:"set_type"`
+	SetURI          string            `json:"set_uri"`
+	SetSearchURI    string            `json:"set_search_uri"`
+	ScryfallSetURI  string            `json:"scryfall_set_uri"`
+	RulingsURI      string            `json:"rulings_uri"`
+	PrintsSearchURI string            `json:"prints_search_uri"`
+	CollectorNumber string            `json:"collector_number"`
+	Digital         bool              `json:"digital"`
+	Rarity          string            `json:"rarity"`
+	FlavourText     string            `json:"flavor_text"`
+	IllustrationID  string            `json:"illustration_id"`
+	Artist          string            `json:"artist"`
+	BorderColor     string            `json:"border_color"`
+	Frame           string            `json:"frame"`
+	FrameEffect     string            `json:"frame_effect"`
+	FullArt         bool              `json:"full_art"`
+	Timeshifted     bool              `json:"timeshifted"`
+	Colorshifted    bool              `json:"colorshifted"`
+	Futureshifted   bool              `json:"futureshifted"`
+	StorySpotlight  bool              `json:"story_spotlight"`
+	EdhrecRank      int               `json:"edhrec_rank"`
+	Prices          struct {
+		Usd       string `json:"usd"`
+		UsdFoil   string `json:"usd_foil"`
+		UsdEtched string `json:"usd_etched"`
+		Eur       string `json:"eur"`
+		EurFoil   string `json:"eur_foil"`
+		Tix       string `json:"tix"`
+	} `json:"prices"`
+	RelatedUris struct {
 		Gatherer       string `json:"gatherer"`
 		TcgplayerDecks string `json:"tcgplayer_decks"`
 		Edhrec         string `json:"edhrec"`
@@ -218,6 +227,29 @@ func standardiseColorIndicator(ColorIndicators []string) string {
 	return "[" + strings.Join(colorWords, "/") + "]"
 }
 
+// Every card name, normalised once when the names are loaded, so that isCardName is a lookup
+var (
+	cardNameSet     map[string]bool
+	cardNameSetLock sync.RWMutex
+)
+
+func setCardNameSet(names []string) {
+	set := make(map[string]bool, len(names))
+	for _, x := range names {
+		set[normaliseCardName(x)] = true
+	}
+	cardNameSetLock.Lock()
+	defer cardNameSetLock.Unlock()
+	cardNameSet = set
+}
+
+// isCardName says whether the input is exactly the name of a card, so it isn't taken for a command
+func isCardName(input string) bool {
+	cardNameSetLock.RLock()
+	defer cardNameSetLock.RUnlock()
+	return cardNameSet[normaliseCardName(input)]
+}
+
 func normaliseCardName(input string) string {
 	ret := nonAlphaRegex.ReplaceAllString(strings.ToLower(input), "")
 	// log.Debug("Normalising", "Input", input, "Output", ret)
@@ -239,7 +271,7 @@ func replaceManaCostForSlack(input string) string {
 	return manaString
 }
 
-// TODO: Have a command to see all printing information
+// Only the most recent printings are shown, !printings has all of them
 func (card *Card) formatExpansions() string {
 	var ret []string
 	if card.Name != "Plains" && card.Name != "Island" && card.Name != "Swamp" && card.Name != "Mountain" && card.Name != "Forest" {
@@ -256,14 +288,11 @@ func (card *Card) formatExpansions() string {
 	return strings.Join(sliceUniqMap(ret), ",")
 }
 
-func (card *Card) formatLegalities() string {
+func (card *Card) formatLegalities(formats []string) string {
 	var ret []string
-	ret = append(ret, formatLegality(card.Legalities.Vintage, "Vin"))
-	ret = append(ret, formatLegality(card.Legalities.Commander, "Cmr"))
-	ret = append(ret, formatLegality(card.Legalities.Legacy, "Leg"))
-	ret = append(ret, formatLegality(card.Legalities.Modern, "Mod"))
-	ret = append(ret, formatLegality(card.Legalities.Pioneer, "Pio"))
-	ret = append(ret, formatLegality(card.Legalities.Standard, "Std"))
+	for _, f := range formats {
+		ret = append(ret, formatLegality(card.Legalities[f], formatFor(f).short))
+	}
 	return strings.Join(removeEmptyStrings(ret), ",")
 }
 
@@ -304,17 +333,29 @@ func lookupUniqueNamePrefix(input string) string {
 	if len(c) == 1 {
 		return c[0]
 	}
-	// Look for something legendary-ish
+	if j := pickLegendaryish(c); j != "" {
+		cardUniquePrefixHits.Add(1)
+		return j
+	}
+	return ""
+}
+
+// looksLegendaryish guesses from the name alone whether a card is a legend, like "Jace, the Mind Sculptor"
+func looksLegendaryish(name string) bool {
+	return strings.Contains(name, ",") || strings.Contains(name, "the")
+}
+
+// pickLegendaryish returns the only name that looks like a legendary card, if there is exactly one.
+func pickLegendaryish(names []string) string {
 	var i int
 	var j string
-	for _, x := range c {
-		if strings.Contains(x, ",") || strings.Contains(x, "the") {
+	for _, x := range names {
+		if looksLegendaryish(x) {
 			i++
 			j = x
 		}
 	}
 	if i == 1 {
-		cardUniquePrefixHits.Add(1)
 		return j
 	}
 	return ""
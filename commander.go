@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The ways a commander can be paired with a second one, as they appear in Oracle text
+var (
+	partnerWithRegex   = regexp.MustCompile(`(?m)^Partner with ([^(\n]+?)\s*(?:\(|$)`)
+	partnerRegex       = regexp.MustCompile(`(?m)^Partner(—[^(\n]+?)?\s*(?:\(|$)`)
+	friendsForeverText = "Friends forever"
+	backgroundText     = "Choose a Background"
+	doctorsCompanion   = "Doctor's companion"
+)
+
+// colorIdentityName spells out a color identity, like Blue/Black/Red
+func colorIdentityName(ci []string) string {
+	if len(ci) == 0 {
+		return "Colorless"
+	}
+	return strings.Trim(standardiseColorIndicator(ci), "[]")
+}
+
+// frontTypeLine is the type line of the card, or of its front face, which is what counts for being a commander
+func (card *Card) frontTypeLine() string {
+	if len(card.CardFaces) > 0 && card.CardFaces[0].TypeLine != "" {
+		return card.CardFaces[0].TypeLine
+	}
+	return card.TypeLine
+}
+
+// frontOracleText is the rules text of the card, or of its front face
+func (card *Card) frontOracleText() string {
+	if len(card.CardFaces) > 0 && card.CardFaces[0].OracleText != "" {
+		return card.CardFaces[0].OracleText
+	}
+	return card.OracleText
+}
+
+// commanderStatus says whether the card can be a commander, and if not, why not
+func (card *Card) commanderStatus() (bool, string) {
+	switch card.Legalities["commander"] {
+	case "banned":
+		return false, "it's banned in Commander"
+	case "legal":
+	default:
+		return false, "it isn't legal in Commander"
+	}
+	typeLine := card.frontTypeLine()
+	switch {
+	case strings.Contains(card.frontOracleText(), "can be your commander"):
+		return true, ""
+	case strings.Contains(typeLine, "Legendary") && strings.Contains(typeLine, "Creature"):
+		return true, ""
+	case strings.Contains(typeLine, "Legendary") && strings.Contains(typeLine, "Background"):
+		return true, "alongside a commander with Choose a Background"
+	}
+	return false, "it isn't a legendary creature"
+}
+
+// commanderPairing is how this commander can be paired with another, or "" if it can't
+func (card *Card) commanderPairing() string {
+	text := card.frontOracleText()
+	typeLine := card.frontTypeLine()
+	switch {
+	case partnerWithRegex.MatchString(text):
+		return "Partner with " + partnerWithRegex.FindStringSubmatch(text)[1]
+	case partnerRegex.MatchString(text):
+		return "Partner" + partnerRegex.FindStringSubmatch(text)[1]
+	case strings.Contains(text, friendsForeverText):
+		return friendsForeverText
+	case strings.Contains(text, backgroundText):
+		return backgroundText
+	case strings.Contains(text, doctorsCompanion):
+		return doctorsCompanion + ", with a Time Lord Doctor"
+	case strings.Contains(typeLine, "Time Lord Doctor"):
+		return "Pairs with a Doctor's companion"
+	}
+	return ""
+}
+
+func (card *Card) formatCommander() string {
+	ok, why := card.commanderStatus()
+	if !ok {
+		return fmt.Sprintf("%s can't be your commander: %s", card.Name, why)
+	}
+	ret := []string{fmt.Sprintf("%s can be your commander", card.Name)}
+	if why != "" {
+		ret[0] += " " + why
+	}
+	ret = append(ret, "Color identity: "+colorIdentityName(card.ColorIdentity))
+	if p := card.commanderPairing(); p != "" {
+		ret = append(ret, p)
+	}
+	return strings.Join(ret, " · ")
+}
+
+// fitsIdentity says whether a card can go in a deck with this commander
+func (card *Card) fitsIdentity(commander *Card) bool {
+	for _, c := range card.ColorIdentity {
+		if !stringSliceContains(commander.ColorIdentity, c) {
+			return false
+		}
+	}
+	return true
+}
+
+func formatCommanderCheck(commander *Card, card *Card) string {
+	var ret string
+	if card.fitsIdentity(commander) {
+		ret = fmt.Sprintf("%s (%s) fits in %s's color identity (%s)", card.Name, colorIdentityName(card.ColorIdentity), commander.Name, colorIdentityName(commander.ColorIdentity))
+	} else {
+		ret = fmt.Sprintf("%s (%s) doesn't fit in %s's color identity (%s)", card.Name, colorIdentityName(card.ColorIdentity), commander.Name, colorIdentityName(commander.ColorIdentity))
+	}
+	if card.Legalities["commander"] == "banned" {
+		ret += fmt.Sprintf(", but %s is banned in Commander", card.Name)
+	}
+	if ok, why := commander.commanderStatus(); !ok {
+		ret += fmt.Sprintf(" · %s can't be your commander: %s", commander.Name, why)
+	}
+	return ret
+}
+
+// isNamed says whether the input is the card's name, or the name of one of its faces
+func (card *Card) isNamed(input string) bool {
+	ncn := normaliseCardName(input)
+	if ncn == normaliseCardName(card.Name) {
+		return true
+	}
+	for _, cf := range card.CardFaces {
+		if ncn == normaliseCardName(cf.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitCommanderCheck works out where the commander's name ends and the card's begins.
+// A comma or | between them settles it, though names have commas in too, so it's the first that leaves two cards.
+// Otherwise the commander is the shortest run of words that's a card's name.
+func splitCommanderCheck(source CardSource, tokens []string) (Card, Card, error) {
+	input := strings.Join(tokens, " ")
+	for i, r := range input {
+		if r != ',' && r != '|' {
+			continue
+		}
+		commander, err := findCard(strings.Fields(input[:i]), false, source.Named)
+		if err != nil || !commander.isNamed(input[:i]) {
+			continue
+		}
+		card, err := findCard(strings.Fields(input[i+1:]), false, source.Named)
+		if err != nil {
+			return commander, card, fmt.Errorf("Card not found")
+		}
+		return commander, card, nil
+	}
+	for i := 1; i < len(tokens); i++ {
+		commander, err := findCard(tokens[:i], false, source.Named)
+		if err != nil || !commander.isNamed(strings.Join(tokens[:i], " ")) {
+			continue
+		}
+		card, err := findCard(tokens[i:], false, source.Named)
+		if err != nil {
+			return commander, card, fmt.Errorf("Card not found")
+		}
+		return commander, card, nil
+	}
+	return Card{}, Card{}, fmt.Errorf("Couldn't tell the commander from the card, try !commander check <commander>, <card>")
+}
+
+func handleColorIdentityQuery(params *fryatogParams, cardTokens []string) string {
+	commanderRequests.Add(1)
+	card, err := findCard(cardTokens, false, params.source.Named)
+	if err != nil {
+		return "Card not found"
+	}
+	return fmt.Sprintf("%s · Color identity: %s", card.Name, colorIdentityName(card.ColorIdentity))
+}
+
+// handleCommanderQuery deals with !commander <card> and !commander check <commander> <card>
+func handleCommanderQuery(params *fryatogParams, cardTokens []string) string {
+	commanderRequests.Add(1)
+	if len(cardTokens) > 2 && strings.ToLower(cardTokens[0]) == "check" {
+		commander, card, err := splitCommanderCheck(params.source, cardTokens[1:])
+		if err != nil {
+			return err.Error()
+		}
+		return formatCommanderCheck(&commander, &card)
+	}
+	card, err := findCard(cardTokens, false, params.source.Named)
+	if err != nil {
+		return "Card not found"
+	}
+	return card.formatCommander()
+}
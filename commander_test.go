@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+func TestCommanderPairing(t *testing.T) {
+	tables := []struct {
+		typeLine   string
+		oracleText string
+		want       string
+	}{
+		{"Legendary Creature — Human Wizard", "Flying", ""},
+		{"Legendary Creature — Human Rogue", "Partner (You can have two commanders if both have partner.)", "Partner"},
+		{"Legendary Creature — Elf Warrior", "Partner with Khorvath Brightflame (When this creature enters, target player may put Khorvath into their hand from their library, then shuffle.)\nFlying", "Partner with Khorvath Brightflame"},
+		{"Legendary Creature — Human Survivor", "Partner—Survivors (You can have two commanders if both have this ability.)", "Partner—Survivors"},
+		{"Legendary Creature — Human", "Friends forever (You can have two commanders if both have friends forever.)", "Friends forever"},
+		{"Legendary Creature — Dwarf", "Choose a Background (You can have a Background as a second commander.)", "Choose a Background"},
+		{"Legendary Creature — Human", "Doctor's companion (You can have two commanders if the other is the Doctor.)", "Doctor's companion, with a Time Lord Doctor"},
+		{"Legendary Creature — Time Lord Doctor", "Whenever you attack, proliferate.", "Pairs with a Doctor's companion"},
+		{"Legendary Creature — Human", "Whenever a creature with partner attacks, draw a card.", ""},
+	}
+	for _, table := range tables {
+		card := Card{CommonCard: CommonCard{TypeLine: table.typeLine, OracleText: table.oracleText}}
+		if got := card.commanderPairing(); got != table.want {
+			t.Errorf("Incorrect pairing for %q -- got %q -- want %q", table.oracleText, got, table.want)
+		}
+	}
+}
+
+func TestCommanderStatus(t *testing.T) {
+	tables := []struct {
+		typeLine   string
+		oracleText string
+		legality   string
+		want       bool
+		why        string
+	}{
+		{"Legendary Creature — Human Wizard", "", "legal", true, ""},
+		{"Creature — Lhurgoyf", "", "legal", false, "it isn't a legendary creature"},
+		{"Legendary Planeswalker — Teferi", "Teferi, Master of Time can be your commander.", "legal", true, ""},
+		{"Legendary Enchantment — Background", "", "legal", true, "alongside a commander with Choose a Background"},
+		{"Legendary Creature — Human Wizard", "", "banned", false, "it's banned in Commander"},
+		{"Legendary Creature — Human Wizard", "", "not_legal", false, "it isn't legal in Commander"},
+	}
+	for _, table := range tables {
+		card := Card{CommonCard: CommonCard{TypeLine: table.typeLine, OracleText: table.oracleText}, Legalities: map[string]string{"commander": table.legality}}
+		got, why := card.commanderStatus()
+		if got != table.want || why != table.why {
+			t.Errorf("Incorrect status for %s -- got %v %q -- want %v %q", table.typeLine, got, why, table.want, table.why)
+		}
+	}
+}
+
+func TestCommanderQuery(t *testing.T) {
+	var err error
+	nameToCardCache, err = lru.NewARC(2048)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	source := newFakeScryfall(t)
+	tables := []struct {
+		input  string
+		output string
+	}{
+		{"Mairsil, the Pretender", "Mairsil, the Pretender can be your commander · Color identity: Blue/Black/Red"},
+		{"Nicol Bolas, the Ravager", "Nicol Bolas, the Ravager // Nicol Bolas, the Arisen can be your commander · Color identity: Blue/Black/Red"},
+		{"Tarmogoyf", "Tarmogoyf can't be your commander: it isn't a legendary creature"},
+		{"Jace, the Mind Sculptor", "Jace, the Mind Sculptor can't be your commander: it isn't a legendary creature"},
+		{"check Mairsil, the Pretender, Ponder", "Ponder (Blue) fits in Mairsil, the Pretender's color identity (Blue/Black/Red)"},
+		{"check Mairsil the Pretender Tarmogoyf", "Tarmogoyf (Green) doesn't fit in Mairsil, the Pretender's color identity (Blue/Black/Red)"},
+		{"check Nicol Bolas, the Ravager | Ancestral Recall", "Ancestral Recall (Blue) fits in Nicol Bolas, the Ravager // Nicol Bolas, the Arisen's color identity (Blue/Black/Red), but Ancestral Recall is banned in Commander"},
+		{"check Tarmogoyf Tawnos's Coffin", "Tawnos's Coffin (Colorless) fits in Tarmogoyf's color identity (Green) · Tarmogoyf can't be your commander: it isn't a legendary creature"},
+		{"check Mairsil the Pretender Lightning Bolt", "Card not found"},
+	}
+	for _, table := range tables {
+		got := handleCommanderQuery(&fryatogParams{isIRC: true, source: source}, strings.Fields(table.input))
+		if got != table.output {
+			t.Errorf("Incorrect output for %s -- got %q -- want %q", table.input, got, table.output)
+		}
+	}
+	if got := handleColorIdentityQuery(&fryatogParams{isIRC: true, source: source}, []string{"Tawnos's", "Coffin"}); got != "Tawnos's Coffin · Color identity: Colorless" {
+		t.Errorf("Incorrect color identity -- got %q", got)
+	}
+}
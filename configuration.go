@@ -39,6 +39,16 @@ type configuration struct {
 		League           string   `json:"League"`
 		WantedCurrencies []string `json:"WantedCurrencies"`
 	} `json:"PoE"`
+	BulkDataType       string                      `json:"BulkDataType"`
+	ChannelFormats     map[string][]string         `json:"ChannelFormats"`
+	PointsLists        map[string]pointsListConfig `json:"PointsLists"`
+	ChannelPointsLists map[string]string           `json:"ChannelPointsLists"`
+	Schedules          []scheduleConfig            `json:"Schedules"`
+	LegalityChannels   []string                    `json:"LegalityChannels"`
+	Spoilers           struct {
+		IntervalMinutes int                 `json:"IntervalMinutes"`
+		Channels        map[string][]string `json:"Channels"`
+	} `json:"Spoilers"`
 	IRC   bool `json:"IRC"`
 	Slack bool `json:"Slack"`
 }
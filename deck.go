@@ -0,0 +1,396 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "gopkg.in/inconshreveable/log15.v2"
+)
+
+var decksFile = "decks.json"
+
+// More problems than this and the details go to the asker rather than the channel
+const deckProblemsInChannel = 3
+
+// Constructed formats
+const defaultMinDeckSize = 60
+const defaultMaxSideboard = 15
+const defaultMaxCopies = 4
+
+// Singleton formats, and how many cards the deck has, commander included
+var singletonDeckSizes = map[string]int{
+	"commander":       100,
+	"duel":            100,
+	"paupercommander": 100,
+	"predh":           100,
+	"gladiator":       100,
+	"brawl":           100,
+	"standardbrawl":   60,
+	"oathbreaker":     60,
+}
+
+// "4 Ponder", "4x Ponder", "SB: 2 Pyroblast", "4 Ponder (M12) 73", or just "Ponder"
+var deckLineRegex = regexp.MustCompile(`(?i)^(?:SB:\s*)?(?:(\d+)x?\s+)?(.+?)(?:\s+\([A-Za-z0-9]+\)(?:\s+\S+)?)?$`)
+
+// Some cards say how many of them a deck can have
+var deckCopiesRegex = regexp.MustCompile(`A deck can have (any number of|up to (\w+)) cards named`)
+
+var deckCopiesWords = map[string]int{"seven": 7, "nine": 9}
+
+// Everyone's saved decklists, by who saved them and then by name, so nobody can overwrite anyone else's
+var (
+	deckStore     map[string]map[string]string
+	deckStoreLock sync.Mutex
+)
+
+// deckSection is which part of the list a card is in
+type deckSection int
+
+const (
+	deckMain deckSection = iota
+	deckSideboard
+	deckCompanion
+	deckCommander
+)
+
+// Headers that start a new part of the list, as MTGO and Arena write them
+var deckSectionHeaders = map[string]deckSection{
+	"deck":       deckMain,
+	"main":       deckMain,
+	"maindeck":   deckMain,
+	"sideboard":  deckSideboard,
+	"sb":         deckSideboard,
+	"companion":  deckCompanion,
+	"commander":  deckCommander,
+	"commanders": deckCommander,
+}
+
+// deckEntry is one line of a decklist
+type deckEntry struct {
+	count   int
+	name    string
+	section deckSection
+}
+
+// parseDecklist reads a decklist, one card per line. A blank line between the
+// main deck and the sideboard counts as a sideboard header, like MTGO lists.
+func parseDecklist(lines []string) []deckEntry {
+	var ret []deckEntry
+	section := deckMain
+	sawHeader := false
+	sawBlank := false
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			sawBlank = len(ret) > 0
+			continue
+		}
+		if s, ok := deckSectionHeaders[strings.ToLower(strings.TrimSuffix(line, ":"))]; ok {
+			section = s
+			sawHeader = true
+			continue
+		}
+		if strings.HasPrefix(strings.ToLower(line), "name ") || strings.ToLower(line) == "about" {
+			continue
+		}
+		m := deckLineRegex.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		count := 1
+		if m[1] != "" {
+			count, _ = strconv.Atoi(m[1])
+		}
+		lineSection := section
+		if sawBlank && !sawHeader && section == deckMain {
+			section = deckSideboard
+			lineSection = deckSideboard
+		}
+		if strings.HasPrefix(strings.ToUpper(line), "SB:") {
+			lineSection = deckSideboard
+		}
+		ret = append(ret, deckEntry{count: count, name: strings.TrimSpace(m[2]), section: lineSection})
+	}
+	return ret
+}
+
+// maxCopies is how many of a card a deck can have, for a format that allows the given number of most cards
+func (card *Card) maxCopies(usual int) int {
+	if strings.Contains(card.TypeLine, "Basic") && strings.Contains(card.TypeLine, "Land") {
+		return -1
+	}
+	m := deckCopiesRegex.FindStringSubmatch(card.OracleText)
+	switch {
+	case m == nil:
+		return usual
+	case m[1] == "any number of":
+		return -1
+	default:
+		if n, ok := deckCopiesWords[m[2]]; ok {
+			return n
+		}
+		return usual
+	}
+}
+
+// deckReport is what we found out about a decklist
+type deckReport struct {
+	format    gameFormat
+	main      int
+	sideboard int
+	companion int
+	commander int
+	points    int
+	problems  []string
+}
+
+func (dr deckReport) String() string {
+	ret := []string{fmt.Sprintf("Deck · %s", dr.format.long), fmt.Sprintf("%d main", dr.main)}
+	if dr.commander > 0 {
+		ret = append(ret, fmt.Sprintf("%d commander", dr.commander))
+	}
+	if dr.sideboard > 0 {
+		ret = append(ret, fmt.Sprintf("%d sideboard", dr.sideboard))
+	}
+	if dr.companion > 0 {
+		ret = append(ret, fmt.Sprintf("%d companion", dr.companion))
+	}
+	if dr.points > 0 {
+		ret = append(ret, fmt.Sprintf("%d points", dr.points))
+	}
+	switch {
+	case len(dr.problems) == 0:
+		ret = append(ret, "Legal")
+	case len(dr.problems) == 1:
+		ret = append(ret, "1 problem: "+dr.problems[0])
+	case len(dr.problems) <= deckProblemsInChannel:
+		ret = append(ret, fmt.Sprintf("%d problems: %s", len(dr.problems), strings.Join(dr.problems, " · ")))
+	default:
+		return strings.Join(append(ret, fmt.Sprintf("%d problems, the details are coming separately", len(dr.problems))), " · ") +
+			overflowMarker + strings.Join(dr.problems, "\n")
+	}
+	return strings.Join(ret, " · ")
+}
+
+// checkDecklist looks up every card in the list, checks the deck against the format and adds up its points
+func checkDecklist(source CardSource, entries []deckEntry, f gameFormat, pl *pointsList) deckReport {
+	dr := deckReport{format: f}
+	deckSize, singleton := singletonDeckSizes[f.key]
+	usualCopies := defaultMaxCopies
+	if singleton {
+		usualCopies = 1
+	}
+
+	copies := make(map[string]int)
+	cards := make(map[string]Card)
+	var order []string
+	var notFound []string
+	sideboardNames := make(map[string]bool)
+	companions := make(map[string]int)
+	for _, e := range entries {
+		card, err := findCard(strings.Fields(e.name), false, source.Named)
+		if err != nil {
+			notFound = append(notFound, e.name)
+			continue
+		}
+		if _, ok := cards[card.Name]; !ok {
+			cards[card.Name] = card
+			order = append(order, card.Name)
+		}
+		switch e.section {
+		case deckMain:
+			dr.main += e.count
+		case deckSideboard:
+			dr.sideboard += e.count
+			sideboardNames[card.Name] = true
+		case deckCompanion:
+			dr.companion += e.count
+			companions[card.Name] += e.count
+			continue
+		case deckCommander:
+			dr.commander += e.count
+		}
+		copies[card.Name] += e.count
+		points, _ := pl.cardPoints(card.Name)
+		dr.points += points * e.count
+	}
+	// A companion lives in the sideboard, but Arena lists it in both places
+	sideboard := dr.sideboard
+	for name, n := range companions {
+		if !sideboardNames[name] {
+			sideboard += n
+			copies[name] += n
+			points, _ := pl.cardPoints(name)
+			dr.points += points * n
+		}
+	}
+
+	for _, name := range notFound {
+		dr.problems = append(dr.problems, fmt.Sprintf("Card not found: %s", name))
+	}
+	var notLegal, banned []string
+	for _, name := range order {
+		card := cards[name]
+		switch card.Legalities[f.key] {
+		case "legal":
+		case "restricted":
+			if copies[name] > 1 {
+				dr.problems = append(dr.problems, fmt.Sprintf("%s is restricted, but there are %d", name, copies[name]))
+			}
+			continue
+		case "banned":
+			banned = append(banned, name)
+			continue
+		default:
+			notLegal = append(notLegal, name)
+			continue
+		}
+		if most := card.maxCopies(usualCopies); most >= 0 && copies[name] > most {
+			dr.problems = append(dr.problems, fmt.Sprintf("%d copies of %s, the most is %d", copies[name], name, most))
+		}
+	}
+	if len(banned) > 0 {
+		dr.problems = append(dr.problems, "Banned: "+strings.Join(banned, ", "))
+	}
+	if len(notLegal) > 0 {
+		dr.problems = append(dr.problems, "Not legal: "+strings.Join(notLegal, ", "))
+	}
+
+	if singleton {
+		if total := dr.main + dr.commander; total != deckSize {
+			dr.problems = append(dr.problems, fmt.Sprintf("The deck has %d cards, it should have %d", total, deckSize))
+		}
+	} else {
+		if dr.main < defaultMinDeckSize {
+			dr.problems = append(dr.problems, fmt.Sprintf("The main deck has %d cards, it needs at least %d", dr.main, defaultMinDeckSize))
+		}
+		if sideboard > defaultMaxSideboard {
+			dr.problems = append(dr.problems, fmt.Sprintf("The sideboard has %d cards, the most is %d", sideboard, defaultMaxSideboard))
+		}
+	}
+	if dr.companion > 1 {
+		dr.problems = append(dr.problems, fmt.Sprintf("There are %d companions, there can only be one", dr.companion))
+	}
+	return dr
+}
+
+// loadDeckStore reads the saved decklists, the first time they're needed
+func loadDeckStore() {
+	if deckStore != nil {
+		return
+	}
+	deckStore = make(map[string]map[string]string)
+	b, err := os.ReadFile(decksFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warn("Error reading saved decks", "Error", err)
+		}
+		return
+	}
+	if err := json.Unmarshal(b, &deckStore); err != nil {
+		log.Warn("Error parsing saved decks", "Error", err)
+		deckStore = make(map[string]map[string]string)
+	}
+}
+
+// savedDeck finds a decklist someone saved with !deck save
+func savedDeck(user string, name string) (string, bool) {
+	deckStoreLock.Lock()
+	defer deckStoreLock.Unlock()
+	loadDeckStore()
+	list, ok := deckStore[strings.ToLower(user)][strings.ToLower(name)]
+	return list, ok
+}
+
+func saveDeck(user string, name string, list string) error {
+	deckStoreLock.Lock()
+	defer deckStoreLock.Unlock()
+	loadDeckStore()
+	user = strings.ToLower(user)
+	if deckStore[user] == nil {
+		deckStore[user] = make(map[string]string)
+	}
+	deckStore[user][strings.ToLower(name)] = list
+	b, err := json.MarshalIndent(deckStore, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(decksFile, b, 0644)
+}
+
+// splitDeckInput separates the first line, with the format or a saved deck's name, from the list.
+// Lists come a card per line, or separated by ; on IRC where there's only the one line.
+func splitDeckInput(input string) (string, []string) {
+	lines := strings.FieldsFunc(strings.ReplaceAll(input, "\r", ""), func(r rune) bool { return r == ';' })
+	var split []string
+	for _, l := range lines {
+		split = append(split, strings.Split(l, "\n")...)
+	}
+	if len(split) == 0 {
+		return "", nil
+	}
+	return strings.TrimSpace(split[0]), split[1:]
+}
+
+func handleDeckQuery(params *fryatogParams, input string) string {
+	deckRequests.Add(1)
+	header, lines := splitDeckInput(input)
+	headerTokens := strings.Fields(header)
+
+	// !deck save <name>, followed by the list
+	if len(headerTokens) == 2 && strings.ToLower(headerTokens[0]) == "save" {
+		entries := parseDecklist(lines)
+		if len(entries) == 0 {
+			return "There's no decklist to save"
+		}
+		if err := saveDeck(params.user, headerTokens[1], strings.Join(lines, "\n")); err != nil {
+			log.Warn("Error saving deck", "Error", err)
+			return "Problem saving the deck"
+		}
+		var total int
+		for _, e := range entries {
+			total += e.count
+		}
+		return fmt.Sprintf("Saved %s (%d cards)", headerTokens[1], total)
+	}
+
+	// The first line is the format and maybe a saved deck, unless it's already part of the list.
+	// There's no default format, as a deck that's fine in one is full of problems in another.
+	usage := "!deck <format> followed by the list, one card per line (or separated by ;), or !deck <saved deck> <format>"
+	var f gameFormat
+	var rest []string
+	for i := 0; i < len(headerTokens); i++ {
+		if i+1 < len(headerTokens) {
+			if two, ok := lookupFormat(headerTokens[i] + " " + headerTokens[i+1]); ok {
+				f = two
+				i++
+				continue
+			}
+		}
+		if one, ok := lookupFormat(headerTokens[i]); ok {
+			f = one
+			continue
+		}
+		rest = append(rest, headerTokens[i])
+	}
+	if len(rest) == 1 {
+		if list, ok := savedDeck(params.user, rest[0]); ok {
+			lines = append(strings.Split(list, "\n"), lines...)
+			rest = nil
+		}
+	}
+	if len(rest) > 0 {
+		lines = append([]string{header}, lines...)
+	}
+
+	entries := parseDecklist(lines)
+	if len(entries) == 0 || f.key == "" {
+		return usage
+	}
+	return checkDecklist(params.source, entries, f, params.pointsList()).String()
+}
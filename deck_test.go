@@ -0,0 +1,142 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+func TestParseDecklist(t *testing.T) {
+	tables := []struct {
+		name  string
+		input string
+		want  []deckEntry
+	}{
+		{"plain", "4 Ponder\n4x Tarmogoyf\nDryad Arbor", []deckEntry{
+			{4, "Ponder", deckMain}, {4, "Tarmogoyf", deckMain}, {1, "Dryad Arbor", deckMain},
+		}},
+		{"mtgo", "4 Ponder\n\n2 Tarmogoyf", []deckEntry{
+			{4, "Ponder", deckMain}, {2, "Tarmogoyf", deckSideboard},
+		}},
+		{"sb prefix", "4 Ponder\nSB: 2 Tarmogoyf", []deckEntry{
+			{4, "Ponder", deckMain}, {2, "Tarmogoyf", deckSideboard},
+		}},
+		{"arena", "About\nName Fish\n\nCompanion\n1 Lurrus of the Dream-Den (IKO) 226\n\nDeck\n4 Ponder (M12) 73\n\nSideboard\n1 Lurrus of the Dream-Den (IKO) 226", []deckEntry{
+			{1, "Lurrus of the Dream-Den", deckCompanion}, {4, "Ponder", deckMain}, {1, "Lurrus of the Dream-Den", deckSideboard},
+		}},
+		{"commander", "Commander\n1 Arlinn Kord\nDeck\n99 Forest", []deckEntry{
+			{1, "Arlinn Kord", deckCommander}, {99, "Forest", deckMain},
+		}},
+	}
+	for _, table := range tables {
+		got := parseDecklist(strings.Split(table.input, "\n"))
+		if !reflect.DeepEqual(got, table.want) {
+			t.Errorf("Incorrect output for %s -- got %+v -- want %+v", table.name, got, table.want)
+		}
+	}
+}
+
+func TestMaxCopies(t *testing.T) {
+	tables := []struct {
+		card Card
+		want int
+	}{
+		{Card{CommonCard: CommonCard{TypeLine: "Instant"}}, 4},
+		{Card{CommonCard: CommonCard{TypeLine: "Basic Land — Forest"}}, -1},
+		{Card{CommonCard: CommonCard{TypeLine: "Basic Snow Land — Island"}}, -1},
+		{Card{CommonCard: CommonCard{TypeLine: "Creature — Rat", OracleText: "A deck can have any number of cards named Relentless Rats."}}, -1},
+		{Card{CommonCard: CommonCard{TypeLine: "Legendary Creature — Dwarf", OracleText: "A deck can have up to seven cards named Seven Dwarves."}}, 7},
+	}
+	for _, table := range tables {
+		if got := table.card.maxCopies(defaultMaxCopies); got != table.want {
+			t.Errorf("Incorrect output for %s -- got %d -- want %d", table.card.TypeLine, got, table.want)
+		}
+	}
+}
+
+func TestCheckDecklist(t *testing.T) {
+	var err error
+	nameToCardCache, err = lru.NewARC(2048)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	source := newFakeScryfall(t)
+	pl := defaultPointsList()
+	pl.setPoints(map[string]int{normaliseCardName("Ancestral Recall"): 4})
+	defer pl.setPoints(nil)
+
+	tables := []struct {
+		format   string
+		input    string
+		output   string
+		overflow string
+	}{
+		{"vintage", "4 Ponder\n1 Ancestral Recall", "Deck · Vintage · 5 main · 4 points · 2 problems: Ponder is restricted, but there are 4 · The main deck has 5 cards, it needs at least 60", ""},
+		{"vintage", "56 Tarmogoyf\n4 Ponder\nSideboard\n4 Tarmogoyf", "Deck · Vintage · 60 main · 4 sideboard · 2 problems: 60 copies of Tarmogoyf, the most is 4 · Ponder is restricted, but there are 4", ""},
+		{"commander", "Commander\n1 Arlinn Kord\nDeck\n1 Ancestral Recall\n1 Ponder", "Deck · Commander · 2 main · 1 commander · 4 points · 2 problems: Banned: Ancestral Recall · The deck has 3 cards, it should have 100", ""},
+		{"modern", "4 Ponder\n4 Lightning Bolt\n1 Ancestral Recall\n\n16 Tarmogoyf", "Deck · Modern · 5 main · 16 sideboard · 4 points · 6 problems, the details are coming separately",
+			"Card not found: Lightning Bolt\n16 copies of Tarmogoyf, the most is 4\nBanned: Ponder\nNot legal: Ancestral Recall\nThe main deck has 5 cards, it needs at least 60\nThe sideboard has 16 cards, the most is 15"},
+	}
+	for _, table := range tables {
+		f, _ := lookupFormat(table.format)
+		got, overflow := splitOverflow(checkDecklist(source, parseDecklist(strings.Split(table.input, "\n")), f, pl).String())
+		if got != table.output || overflow != table.overflow {
+			t.Errorf("Incorrect output for %q -- got %q / %q -- want %q / %q", table.input, got, overflow, table.output, table.overflow)
+		}
+	}
+}
+
+func TestDeckQuery(t *testing.T) {
+	var err error
+	nameToCardCache, err = lru.NewARC(2048)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	source := newFakeScryfall(t)
+	oldFile := decksFile
+	decksFile = filepath.Join(t.TempDir(), "decks.json")
+	deckStoreLock.Lock()
+	deckStore = map[string]map[string]string{"fry": {"goyf": "60 Tarmogoyf"}}
+	deckStoreLock.Unlock()
+	defer func() {
+		decksFile = oldFile
+		deckStore = nil
+	}()
+	usage := "!deck <format> followed by the list, one card per line (or separated by ;), or !deck <saved deck> <format>"
+
+	tables := []struct {
+		user   string
+		input  string
+		output string
+	}{
+		{"fry", " legacy; 4 Ponder; 56 Tarmogoyf", "Deck · Legacy · 60 main · 1 problem: 56 copies of Tarmogoyf, the most is 4"},
+		{"fry", " vintage\n4 Ponder\n4 Tarmogoyf", "Deck · Vintage · 8 main · 2 problems: Ponder is restricted, but there are 4 · The main deck has 8 cards, it needs at least 60"},
+		// There's no guessing the format
+		{"fry", "\n4 Ponder\n4 Tarmogoyf", usage},
+		{"fry", " 4 Tarmogoyf; 4 Ponder", usage},
+		{"fry", " goyf", usage},
+		{"fry", " goyf modern", "Deck · Modern · 60 main · 1 problem: 60 copies of Tarmogoyf, the most is 4"},
+		{"fry", " modern", usage},
+		// Everyone has their own saved decks
+		{"volo", " goyf modern", "Deck · Modern · 0 main · 2 problems: Card not found: goyf modern · The main deck has 0 cards, it needs at least 60"},
+		{"volo", " save goyf; 4 Ponder", "Saved goyf (4 cards)"},
+		{"volo", " goyf legacy", "Deck · Legacy · 4 main · 1 problem: The main deck has 4 cards, it needs at least 60"},
+		{"fry", " goyf modern", "Deck · Modern · 60 main · 1 problem: 60 copies of Tarmogoyf, the most is 4"},
+	}
+	for _, table := range tables {
+		got := handleDeckQuery(&fryatogParams{isIRC: true, source: source, user: table.user}, table.input)
+		if got != table.output {
+			t.Errorf("Incorrect output for %q -- got %q -- want %q", table.input, got, table.output)
+		}
+	}
+
+	// Slack decklists arrive a card per line, and have to get all the way through the dispatcher
+	got := tokeniseAndDispatchInput(&fryatogParams{slackm: "!deck legacy\n4 Ponder\n56 Tarmogoyf", channel: "C1", user: "fry"}, source)
+	want := []string{"Deck · Legacy · 60 main · 1 problem: 56 copies of Tarmogoyf, the most is 4"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Incorrect output for a Slack decklist -- got %q -- want %q", got, want)
+	}
+}
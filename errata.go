@@ -0,0 +1,304 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	raven "github.com/getsentry/raven-go"
+	log "gopkg.in/inconshreveable/log15.v2"
+)
+
+var printedWordingsFile = "printed_wordings.json"
+
+// How many old wordings fit in one message
+const ircErrataShown = 3
+const slackErrataShown = 10
+
+// How many unchanged words to keep either side of a change
+const errataContextWords = 3
+
+// PrintedWording is the text as it was printed on a card, and when that printing came out
+type PrintedWording struct {
+	Set        string `json:"set"`
+	ReleasedAt string `json:"released_at"`
+	Text       string `json:"text"`
+}
+
+// cardWordings are the old printed wordings of a card, shipped in printed_wordings.json and added to by ops.
+// Scryfall only has the printed text of non-English printings, so the English wordings have to come from us.
+type cardWordings struct {
+	// Only there for whoever's reading the file
+	Name     string           `json:"name"`
+	Wordings []PrintedWording `json:"wordings"`
+}
+
+// The printed wordings, by oracle ID
+var (
+	printedWordings     map[string]cardWordings
+	printedWordingsLock sync.RWMutex
+)
+
+func importPrintedWordings() error {
+	log.Debug("In importPrintedWordings")
+	var pw map[string]cardWordings
+	if err := readJSONFile(printedWordingsFile, &pw); err != nil {
+		raven.CaptureError(err, nil)
+		log.Warn("Error reading printed wordings file", "Error", err)
+		return err
+	}
+	printedWordingsLock.Lock()
+	defer printedWordingsLock.Unlock()
+	printedWordings = pw
+	log.Debug("Populated printed wordings", "Length", len(pw))
+	return nil
+}
+
+func lookupPrintedWordings(oracleID string) []PrintedWording {
+	printedWordingsLock.RLock()
+	defer printedWordingsLock.RUnlock()
+	if oracleID == "" {
+		return nil
+	}
+	return append([]PrintedWording{}, printedWordings[oracleID].Wordings...)
+}
+
+// setPrintedWording changes or, with no text, removes a card's wording in one set, and saves them all
+func setPrintedWording(card *Card, wording PrintedWording) error {
+	printedWordingsLock.Lock()
+	defer printedWordingsLock.Unlock()
+	if printedWordings == nil {
+		printedWordings = make(map[string]cardWordings)
+	}
+	cw := cardWordings{Name: card.Name}
+	for _, w := range printedWordings[card.OracleID].Wordings {
+		if w.Set != wording.Set {
+			cw.Wordings = append(cw.Wordings, w)
+		}
+	}
+	if wording.Text != "" {
+		cw.Wordings = append(cw.Wordings, wording)
+	}
+	if len(cw.Wordings) == 0 {
+		delete(printedWordings, card.OracleID)
+	} else {
+		printedWordings[card.OracleID] = cw
+	}
+	return writeJSONFile(printedWordingsFile, printedWordings)
+}
+
+// oracleWording is the card's current Oracle text, with any faces joined up
+func (card *Card) oracleWording() string {
+	if len(card.CardFaces) == 0 || card.OracleText != "" {
+		return card.OracleText
+	}
+	var parts []string
+	for _, cf := range card.CardFaces {
+		if cf.OracleText != "" {
+			parts = append(parts, cf.OracleText)
+		}
+	}
+	return strings.Join(parts, " // ")
+}
+
+// diffWord is a word as it's compared, ignoring case and punctuation at either end,
+// so that "Destroy" and "destroy." are the same word
+func diffWord(word string) string {
+	return strings.ToLower(strings.Trim(word, `.,;:"'()`))
+}
+
+// significantlyDifferent says whether two wordings differ in more than case, punctuation and spacing
+func significantlyDifferent(a string, b string) bool {
+	wa, wb := strings.Fields(a), strings.Fields(b)
+	if len(wa) != len(wb) {
+		return true
+	}
+	for i := range wa {
+		if diffWord(wa[i]) != diffWord(wb[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// wordDiff shows how to get from one wording to another, a word at a time: [-removed-] {+added+}.
+// Long runs of unchanged words are cut down to a little context either side of each change.
+func wordDiff(from string, to string) string {
+	a, b := strings.Fields(from), strings.Fields(to)
+	// lcs[i][j] is the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if diffWord(a[i]) == diffWord(b[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ret, same, removed, added []string
+	flushChanges := func() {
+		if len(removed) > 0 {
+			ret = append(ret, "[-"+strings.Join(removed, " ")+"-]")
+		}
+		if len(added) > 0 {
+			ret = append(ret, "{+"+strings.Join(added, " ")+"+}")
+		}
+		removed, added = nil, nil
+	}
+	flushSame := func(atStart bool, atEnd bool) {
+		keep := same
+		switch {
+		case atStart && atEnd:
+		case atStart && len(same) > errataContextWords:
+			keep = append([]string{"…"}, same[len(same)-errataContextWords:]...)
+		case atEnd && len(same) > errataContextWords:
+			keep = append(append([]string{}, same[:errataContextWords]...), "…")
+		case !atStart && !atEnd && len(same) > 2*errataContextWords+1:
+			keep = append(append(append([]string{}, same[:errataContextWords]...), "…"), same[len(same)-errataContextWords:]...)
+		}
+		ret = append(ret, keep...)
+		same = nil
+	}
+
+	i, j := 0, 0
+	started := false
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && diffWord(a[i]) == diffWord(b[j]):
+			flushChanges()
+			same = append(same, b[j])
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			if len(same) > 0 {
+				flushSame(!started, false)
+			}
+			started = true
+			added = append(added, b[j])
+			j++
+		default:
+			if len(same) > 0 {
+				flushSame(!started, false)
+			}
+			started = true
+			removed = append(removed, a[i])
+			i++
+		}
+	}
+	flushChanges()
+	if len(same) > 0 {
+		flushSame(!started, true)
+	}
+	return strings.Join(ret, " ")
+}
+
+// errataWordings are the distinct old wordings of the card that aren't what Oracle says now, oldest first
+func (card *Card) errataWordings() []PrintedWording {
+	wordings := lookupPrintedWordings(card.OracleID)
+	sort.SliceStable(wordings, func(i, j int) bool {
+		return wordings[i].ReleasedAt < wordings[j].ReleasedAt
+	})
+	oracle := card.oracleWording()
+	var ret []PrintedWording
+	for _, w := range wordings {
+		if !significantlyDifferent(w.Text, oracle) {
+			continue
+		}
+		if len(ret) > 0 && !significantlyDifferent(w.Text, ret[len(ret)-1].Text) {
+			continue
+		}
+		ret = append(ret, w)
+	}
+	return ret
+}
+
+// formatErrata shows each old wording of the card against its current Oracle text
+func (card *Card) formatErrata(isIRC bool) string {
+	if len(lookupPrintedWordings(card.OracleID)) == 0 {
+		return fmt.Sprintf("No printed text to compare for %s", card.Name)
+	}
+	wordings := card.errataWordings()
+	if len(wordings) == 0 {
+		return fmt.Sprintf("%s is still printed with its current Oracle text", card.Name)
+	}
+	shown := slackErrataShown
+	if isIRC {
+		shown = ircErrataShown
+	}
+	var entries []string
+	for _, w := range wordings[:min(shown, len(wordings))] {
+		entries = append(entries, fmt.Sprintf("%s (%s): %s", strings.ToUpper(w.Set), w.ReleasedAt, wordDiff(w.Text, card.oracleWording())))
+	}
+	var ret string
+	if isIRC {
+		ret = fmt.Sprintf("\x02%s\x0F · %s", card.Name, strings.Join(entries, " · "))
+	} else {
+		ret = fmt.Sprintf("*<%s|%s>*\n%s", card.ScryfallURI, card.Name, strings.Join(entries, "\n"))
+	}
+	if len(wordings) > shown {
+		ret += fmt.Sprintf(" · and %d more wordings since", len(wordings)-shown)
+	}
+	return ret
+}
+
+func handleErrataQuery(params *fryatogParams, cardTokens []string) string {
+	errataRequests.Add(1)
+	card, err := findCard(cardTokens, false, params.source.Named)
+	if err != nil {
+		return "Card not found"
+	}
+	return card.formatErrata(params.isIRC)
+}
+
+// handlePrintedWordingCommand lets ops type in how a card was worded in a set, for !errata to compare.
+// !printedwording lea Disenchant | Destroy target artifact or enchantment.
+func handlePrintedWordingCommand(params *fryatogParams, input string) string {
+	usage := "!printedwording reload, !printedwording clear <set> <card>, or !printedwording <set> <card> | <wording>"
+	input = strings.TrimSpace(input)
+	tokens := strings.Fields(input)
+	if len(tokens) == 1 && tokens[0] == "reload" {
+		if err := importPrintedWordings(); err != nil {
+			return "Problem reloading the printed wordings"
+		}
+		return "Done!"
+	}
+	clearing := len(tokens) > 0 && tokens[0] == "clear"
+	if clearing {
+		input = strings.TrimPrefix(input, "clear")
+	}
+	cardPart, text, found := strings.Cut(input, "|")
+	text = strings.TrimSpace(text)
+	tokens = strings.Fields(cardPart)
+	if len(tokens) < 2 || clearing == found || (!clearing && text == "") {
+		return usage
+	}
+	set := strings.ToLower(tokens[0])
+	card, err := findCard(tokens[1:], false, params.source.Named)
+	if err != nil || card.OracleID == "" {
+		return "Card not found"
+	}
+	if clearing {
+		if err := setPrintedWording(&card, PrintedWording{Set: set}); err != nil {
+			log.Warn("Error saving printed wordings", "Error", err)
+			return "Problem saving the printed wordings"
+		}
+		return fmt.Sprintf("Forgot the %s wording of %s", strings.ToUpper(set), card.Name)
+	}
+
+	// The printing says when it came out, and that there was one
+	printing, err := params.source.Printing(card.Name, set, "")
+	if err != nil {
+		return fmt.Sprintf("%s wasn't printed in %s", card.Name, strings.ToUpper(set))
+	}
+	if err := setPrintedWording(&card, PrintedWording{Set: set, ReleasedAt: printing.ReleasedAt, Text: text}); err != nil {
+		log.Warn("Error saving printed wordings", "Error", err)
+		return "Problem saving the printed wordings"
+	}
+	return fmt.Sprintf("Saved the %s wording of %s", strings.ToUpper(set), card.Name)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+func TestWordDiff(t *testing.T) {
+	tables := []struct {
+		from   string
+		to     string
+		output string
+	}{
+		{"Destroy target artifact or enchantment.", "Destroy target artifact or enchantment.", "Destroy target artifact or enchantment."},
+		{"Destroy target artifact or enchantment", "destroy target artifact or enchantment.", "destroy target artifact or enchantment."},
+		{"Draw 3 cards.", "Target player draws three cards.", "[-Draw 3-] {+Target player draws three+} cards."},
+		{"Target player draws 3 cards. Draw a card at the beginning of the next turn's upkeep.", "Target player draws three cards. Draw a card at the beginning of the next turn's upkeep.", "Target player draws [-3-] {+three+} cards. Draw a …"},
+		{"All creatures in play get -1/-1 until end of turn.", "All creatures get -1/-1 until end of turn.", "All creatures [-in play-] get -1/-1 until …"},
+	}
+	for _, table := range tables {
+		if got := wordDiff(table.from, table.to); got != table.output {
+			t.Errorf("Incorrect diff from %q to %q -- got %q -- want %q", table.from, table.to, got, table.output)
+		}
+	}
+}
+
+func TestFormatErrata(t *testing.T) {
+	oldFile := printedWordingsFile
+	printedWordingsFile = filepath.Join(t.TempDir(), "printed_wordings.json")
+	defer func() {
+		printedWordingsFile = oldFile
+		importPrintedWordings()
+	}()
+	card := Card{Name: "Ancestral Recall", OracleID: "o-ancestral", ScryfallURI: "https://scryfall.com/card/vma/1/ancestral-recall"}
+	card.OracleText = "Target player draws three cards."
+	if got := card.formatErrata(true); got != "No printed text to compare for Ancestral Recall" {
+		t.Errorf("Incorrect output with no printed text -- got %q", got)
+	}
+
+	for _, w := range []PrintedWording{
+		{Set: "vma", ReleasedAt: "2014-06-16", Text: "Target player draws three cards."},
+		{Set: "2ed", ReleasedAt: "1993-12-01", Text: "Draw 3 cards or force opponent to draw 3 cards."},
+		{Set: "lea", ReleasedAt: "1993-08-05", Text: "Draw 3 cards or force opponent to draw 3 cards."},
+		{Set: "me1", ReleasedAt: "2007-09-10", Text: "Target player draws three cards"},
+	} {
+		if err := setPrintedWording(&card, w); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	want := "\x02Ancestral Recall\x0F · LEA (1993-08-05): [-Draw 3-] {+Target player draws three+} cards. [-or force opponent to draw 3 cards.-]"
+	if got := card.formatErrata(true); got != want {
+		t.Errorf("Incorrect IRC output -- got %q -- want %q", got, want)
+	}
+	want = "*<https://scryfall.com/card/vma/1/ancestral-recall|Ancestral Recall>*\nLEA (1993-08-05): [-Draw 3-] {+Target player draws three+} cards. [-or force opponent to draw 3 cards.-]"
+	if got := card.formatErrata(false); got != want {
+		t.Errorf("Incorrect Slack output -- got %q -- want %q", got, want)
+	}
+
+	for _, set := range []string{"2ed", "lea", "me1"} {
+		setPrintedWording(&card, PrintedWording{Set: set})
+	}
+	if got := card.formatErrata(true); got != "Ancestral Recall is still printed with its current Oracle text" {
+		t.Errorf("Incorrect output with no errata -- got %q", got)
+	}
+}
+
+func TestPrintedWordingCommand(t *testing.T) {
+	oldFile := printedWordingsFile
+	printedWordingsFile = filepath.Join(t.TempDir(), "printed_wordings.json")
+	defer func() {
+		printedWordingsFile = oldFile
+		importPrintedWordings()
+	}()
+	var err error
+	nameToCardCache, err = lru.NewARC(2048)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	source := newFakeScryfall(t)
+	params := &fryatogParams{isIRC: true, source: source}
+	usage := "!printedwording reload, !printedwording clear <set> <card>, or !printedwording <set> <card> | <wording>"
+
+	tables := []struct {
+		input  string
+		output string
+	}{
+		{" lea Disenchant", usage},
+		{" lea Disenchant |", usage},
+		{" clear lea Disenchant | Destroy", usage},
+		{" lea Not A Card | Destroy target artifact.", "Card not found"},
+		{" xyz Disenchant | Destroy target artifact.", "Disenchant wasn't printed in XYZ"},
+		{" LEA Disenchant | Destroy target artifact or enchantment in play.", "Saved the LEA wording of Disenchant"},
+		// Loaded again, like a restart
+		{" reload", "Done!"},
+		{" 2ed Disenchant | Destroy target artifact or enchantment.", "Saved the 2ED wording of Disenchant"},
+	}
+	for _, table := range tables {
+		if got := handlePrintedWordingCommand(params, table.input); got != table.output {
+			t.Errorf("Incorrect output for %s -- got %q -- want %q", table.input, got, table.output)
+		}
+	}
+	card, err := findCard([]string{"Disenchant"}, false, source.Named)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got, want := card.formatErrata(true), "\x02Disenchant\x0F · LEA (1993-08-05): … artifact or enchantment. [-in play.-]"; got != want {
+		t.Errorf("Incorrect errata -- got %q -- want %q", got, want)
+	}
+	if got := handlePrintedWordingCommand(params, " clear lea Disenchant"); got != "Forgot the LEA wording of Disenchant" {
+		t.Errorf("Incorrect clear -- got %q", got)
+	}
+	if got := card.formatErrata(true); !strings.HasSuffix(got, "is still printed with its current Oracle text") {
+		t.Errorf("Incorrect errata after clearing -- got %q", got)
+	}
+}
+
+func TestShippedPrintedWordings(t *testing.T) {
+	if err := importPrintedWordings(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var err error
+	nameToCardCache, err = lru.NewARC(2048)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	want := "\x02Ancestral Recall\x0F · LEA (1993-08-05): [-Draw 3-] {+Target player draws three+} cards. [-or force opponent to draw 3 cards.-]"
+	if got := handleErrataQuery(&fryatogParams{isIRC: true, source: newFakeScryfall(t)}, []string{"Ancestral", "Recall"}); got != want {
+		t.Errorf("Incorrect errata -- got %q -- want %q", got, want)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Layouts where each face has its own name that people ask for.
+// Meld cards don't need to be here, each half and the melded card are cards in their own right.
+var facedLayouts = []string{"transform", "modal_dfc", "split", "flip", "adventure"}
+
+// faceNamed works out which face of a card was asked for by its name,
+// or -1 if it was the card as a whole.
+func (card *Card) faceNamed(input string) int {
+	if !stringSliceContains(facedLayouts, card.Layout) {
+		return -1
+	}
+	ncn := normaliseCardName(input)
+	for i, cf := range card.CardFaces {
+		if ncn == normaliseCardName(cf.Name) || (cf.PrintedName != "" && ncn == normaliseCardName(cf.PrintedName)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// findCardFace is findCard, which also says which face of the card was named, or -1 for all of it.
+// Only the tokens that named the card count, so "Fire tonight" is still Fire.
+func findCardFace(cardTokens []string, isLang bool, cardGetFunction func(cardname string, isLang bool) (Card, error)) (Card, int, error) {
+	card, rest, err := findCardPrefix(cardTokens, isLang, cardGetFunction)
+	if err != nil {
+		return card, -1, err
+	}
+	return card, card.faceNamed(strings.Join(cardTokens[:len(cardTokens)-len(rest)], " ")), nil
+}
+
+func (card *Card) formatFaceForIRC(face int, formats []string) string {
+	cf := card.CardFaces[face]
+	var s []string
+	s = append(s, fmt.Sprintf("\x02%s\x0F", nco(cf.PrintedName, cf.Name)))
+	s = append(s, cf.CommonCard.getCardOrFaceAsString("irc")...)
+	s = append(s, fmt.Sprintf("· %s ·", card.formatExpansions()))
+	if card.Reserved {
+		s = append(s, "[RL] ·")
+	}
+	s = append(s, card.formatLegalities(formats))
+	return strings.Join(s, " ")
+}
+
+func (card *Card) formatFaceForSlack(face int) string {
+	cf := card.CardFaces[face]
+	var s []string
+	s = append(s, fmt.Sprintf("*<%s|%s>*", card.ScryfallURI, nco(cf.PrintedName, cf.Name)))
+	s = append(s, cf.CommonCard.getCardOrFaceAsString("slack")...)
+	if card.Reserved {
+		s = append(s, "· [RL] ·")
+	}
+	return strings.Join(s, " ")
+}
+
+// formatCardFace shows just the face that was asked for, or the whole card if it was -1
+func (params *fryatogParams) formatCardFace(card *Card, face int) string {
+	pl := params.pointsList()
+	if face < 0 || face >= len(card.CardFaces) {
+		if params.isIRC {
+			return pl.withPoints(card.formatCardForIRCWithFormats(params.legalityFormats()), card.Name, true)
+		}
+		return card.formatCardForSlackWithPoints(pl)
+	}
+	if params.isIRC {
+		return pl.withPoints(card.formatFaceForIRC(face, params.legalityFormats()), card.Name, true)
+	}
+	return pl.withPoints(card.formatFaceForSlack(face), card.Name, false)
+}
+
+// getFaceFlavourText is the flavour text of just one face, or of the whole card if it was -1
+func (card *Card) getFaceFlavourText(face int) string {
+	if face < 0 || face >= len(card.CardFaces) {
+		return card.getFlavourText()
+	}
+	flavourRequests.Add(1)
+	return nco(card.CardFaces[face].FlavourText, noFlavourText)
+}
+
+// getFaceReminderTexts is the reminder text of just one face, or of the whole card if it was -1
+func (card Card) getFaceReminderTexts(face int) string {
+	if face < 0 || face >= len(card.CardFaces) {
+		return card.getReminderTexts()
+	}
+	reminderRequests.Add(1)
+	ret := reminderTextsIn(card.CardFaces[face].OracleText)
+	if len(ret) == 0 {
+		return "Reminder text not found"
+	}
+	return strings.Join(ret, "\n")
+}
+
+// rulingIsAboutOtherFace says whether a ruling only talks about the faces of the card that weren't asked for
+func (card *Card) rulingIsAboutOtherFace(ruling CardRuling, face int) bool {
+	if face < 0 || face >= len(card.CardFaces) || strings.Contains(ruling.Comment, card.CardFaces[face].Name) {
+		return false
+	}
+	for i, cf := range card.CardFaces {
+		if i != face && strings.Contains(ruling.Comment, cf.Name) {
+			return true
+		}
+	}
+	return false
+}
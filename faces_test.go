@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	lru "github.com/hashicorp/golang-lru"
+	hbot "github.com/whyrusleeping/hellabot"
+)
+
+func TestFaceNamed(t *testing.T) {
+	tables := []struct {
+		cardname string
+		input    string
+		output   int
+	}{
+		{"Jace, Vryn's Prodigy", "Jace, Vryn's Prodigy", 0},
+		{"Jace, Vryn's Prodigy", "jace telepath unbound", 1},
+		{"Jace, Vryn's Prodigy", "Jace, Vryn's Prodigy // Jace, Telepath Unbound", -1},
+		{"Jace, Vryn's Prodigy", "Jace", -1},
+		{"Expansion", "Explosion", 1},
+		{"Bushi Tenderfoot", "Kenzo the Hardhearted", 1},
+		{"Halvar, God of Battle", "Sword of the Realms", 1},
+		{"Ponder", "Ponder", -1},
+	}
+	for _, table := range tables {
+		fi, err := os.Open(RealCards[table.cardname])
+		if err != nil {
+			t.Fatalf("Unable to open %v", RealCards[table.cardname])
+		}
+		var c Card
+		if err := json.NewDecoder(fi).Decode(&c); err != nil {
+			t.Fatalf("Something went wrong parsing the card: %s", err)
+		}
+		fi.Close()
+		if got := c.faceNamed(table.input); got != table.output {
+			t.Errorf("Incorrect face of %s for %s -- got %d -- want %d", table.cardname, table.input, got, table.output)
+		}
+	}
+}
+
+func TestFaceLookups(t *testing.T) {
+	var err error
+	nameToCardCache, err = lru.NewARC(2048)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	source := newFakeScryfall(t)
+
+	tables := []struct {
+		input  string
+		output []string
+	}{
+		{"!Jace, Telepath Unbound", []string{"\x02Jace, Telepath Unbound\x0F · Legendary Planeswalker — Jace · [Blue] · [5] +1: Up to one target creature gets -2/-0 until your next turn. \\ −3: You may cast target instant or sorcery card from your graveyard this turn. If that card would be put into your graveyard this turn, exile it instead. \\ −9: You get an emblem with \"Whenever you cast a spell, target opponent puts the top five cards of their library into their graveyard.\" · ORI-M · Vin,Cmr,Leg,Mod"}},
+		{"!card Jace, Telepath Unbound full", []string{"\x02Jace, Vryn's Prodigy\x0F {1}{U} · Legendary Creature — Human Wizard · 0/2 · {T}: Draw a card, then discard a card. If there are five or more cards in your graveyard, exile Jace, Vryn's Prodigy, then return him to the battlefield transformed under his owner's control. · ORI-M · Vin,Cmr,Leg,Mod\n\x02Jace, Telepath Unbound\x0F · Legendary Planeswalker — Jace · [Blue] · [5] +1: Up to one target creature gets -2/-0 until your next turn. \\ −3: You may cast target instant or sorcery card from your graveyard this turn. If that card would be put into your graveyard this turn, exile it instead. \\ −9: You get an emblem with \"Whenever you cast a spell, target opponent puts the top five cards of their library into their graveyard.\""}},
+		{"!Explosion", []string{"\x02Explosion\x0F {X}{U}{U}{R}{R} · Instant · Explosion deals X damage to any target. Target player draws X cards. · GRN-R · Vin,Cmr,Leg,Mod,Std"}},
+		{"!flavor Ancestor's Embrace", []string{"\"Thank you, Grandmother. I love you too.\""}},
+		{"!flavor Kindly Ancestor", []string{"\"You look cold, dearie.\""}},
+		{"!reminder Kenzo the Hardhearted", []string{"Whenever this creature blocks or becomes blocked, it gets +2/+2 until end of turn."}},
+		{"!reminder Bushi Tenderfoot", []string{"Reminder text not found"}},
+	}
+	for _, table := range tables {
+		got := tokeniseAndDispatchInput(&fryatogParams{m: &hbot.Message{Content: table.input}}, source)
+		if len(got) != len(table.output) || got[0] != table.output[0] {
+			t.Errorf("Incorrect output for [%v] -- got %q -- want %q", table.input, got, table.output)
+		}
+	}
+
+	// Whatever comes after the name doesn't stop it naming a face
+	card, face, err := findCardFace([]string{"Explosion", "please"}, false, source.Named)
+	if err != nil || card.Name != "Expansion // Explosion" || face != 1 {
+		t.Errorf("Incorrect face for Explosion please -- got %s %d %v -- want Expansion // Explosion 1", card.Name, face, err)
+	}
+}
+
+func TestRulingIsAboutOtherFace(t *testing.T) {
+	card := Card{Layout: "adventure", CardFaces: []CardFace{{Name: "Bonecrusher Giant"}, {Name: "Stomp"}}}
+	tables := []struct {
+		comment string
+		face    int
+		output  bool
+	}{
+		{"If Stomp is countered, it goes to its owner's graveyard.", 0, true},
+		{"If Stomp is countered, it goes to its owner's graveyard.", 1, false},
+		{"Bonecrusher Giant's triggered ability resolves before Stomp.", 1, false},
+		{"An adventurer card is a creature card in every zone except the stack.", 0, false},
+		{"If Stomp is countered, it goes to its owner's graveyard.", -1, false},
+	}
+	for _, table := range tables {
+		if got := card.rulingIsAboutOtherFace(CardRuling{Comment: table.comment}, table.face); got != table.output {
+			t.Errorf("Incorrect output for %q on face %d -- got %v -- want %v", table.comment, table.face, got, table.output)
+		}
+	}
+}
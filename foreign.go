@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	raven "github.com/getsentry/raven-go"
+	log "gopkg.in/inconshreveable/log15.v2"
+)
+
+// The only bulk data with every language in it, which is far too big to keep, so we keep just the names
+const foreignNamesBulkDataType = "all_cards"
+const foreignNamesFile = "foreign_names.json"
+
+// It's a couple of gigabytes
+const foreignNamesDownloadTimeout = 60 * time.Minute
+
+// Foreign names hardly change, unlike prices and legalities
+var foreignNamesRefreshTimer = 7 * 24 * time.Hour
+
+// What each language is called, and whether !<lang> can fetch a printing in it
+var languageNames = map[string]string{
+	"es":  "Spanish",
+	"fr":  "French",
+	"de":  "German",
+	"it":  "Italian",
+	"pt":  "Portuguese",
+	"ja":  "Japanese",
+	"ko":  "Korean",
+	"ru":  "Russian",
+	"zhs": "Simplified Chinese",
+	"zht": "Traditional Chinese",
+	"he":  "Hebrew",
+	"la":  "Latin",
+	"grc": "Ancient Greek",
+	"ar":  "Arabic",
+	"sa":  "Sanskrit",
+	"ph":  "Phyrexian",
+}
+var languagePrefixes = []string{"es", "fr", "de", "it", "pt", "ja", "ko", "ru", "zhs", "zht"}
+
+// foreignName is what a printed name in another language is for
+type foreignName struct {
+	OracleID string `json:"oracle_id"`
+	// The English name, of the card or of the face the name is on
+	Name string `json:"name"`
+	Lang string `json:"lang"`
+}
+
+var (
+	foreignNames     map[string]foreignName
+	foreignNamesLock sync.RWMutex
+)
+
+// normaliseForeignName is normaliseCardName for every alphabet, since \W would throw away everything in Japanese
+func normaliseForeignName(input string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(input) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// bulkPrinting is the little we need from each card in the all_cards bulk data
+type bulkPrinting struct {
+	Name        string `json:"name"`
+	OracleID    string `json:"oracle_id"`
+	Lang        string `json:"lang"`
+	PrintedName string `json:"printed_name"`
+	CardFaces   []struct {
+		Name        string `json:"name"`
+		OracleID    string `json:"oracle_id"`
+		PrintedName string `json:"printed_name"`
+	} `json:"card_faces"`
+}
+
+// buildForeignNames reads every printing from bulk data, one at a time, and keeps the non-English names.
+// Names that are the same as the English one are left out, so they still go to the English card.
+func buildForeignNames(r io.Reader) (map[string]foreignName, error) {
+	dec := json.NewDecoder(r)
+	// Opening [
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("Something went wrong parsing the foreign names")
+	}
+	ret := make(map[string]foreignName)
+	add := func(printed string, fn foreignName) {
+		key := normaliseForeignName(printed)
+		if key == "" || key == normaliseForeignName(fn.Name) {
+			return
+		}
+		if _, ok := ret[key]; !ok {
+			ret[key] = fn
+		}
+	}
+	for dec.More() {
+		var p bulkPrinting
+		if err := dec.Decode(&p); err != nil {
+			return nil, fmt.Errorf("Something went wrong parsing the foreign names")
+		}
+		if p.Lang == "en" {
+			continue
+		}
+		if p.PrintedName != "" {
+			add(p.PrintedName, foreignName{OracleID: p.OracleID, Name: p.Name, Lang: p.Lang})
+		}
+		for _, cf := range p.CardFaces {
+			if cf.PrintedName != "" {
+				add(cf.PrintedName, foreignName{OracleID: nco(cf.OracleID, p.OracleID), Name: cf.Name, Lang: p.Lang})
+			}
+		}
+	}
+	return ret, nil
+}
+
+// fetchForeignNames streams the all_cards bulk data, keeping just the names, and saves them
+func fetchForeignNames() error {
+	bdi, err := fetchBulkDataInfo(foreignNamesBulkDataType)
+	if err != nil {
+		return err
+	}
+	log.Debug("FetchForeignNames: Attempting to fetch", "URL", bdi.DownloadURI, "Updated", bdi.UpdatedAt)
+	dl, err := upstream.GetWithTimeout(bdi.DownloadURI, foreignNamesDownloadTimeout)
+	if err != nil {
+		raven.CaptureError(err, nil)
+		log.Warn("FetchForeignNames: The HTTP request failed", "Error", err)
+		return fmt.Errorf("Something went wrong fetching the foreign names")
+	}
+	defer dl.Body.Close()
+	if dl.StatusCode != 200 {
+		log.Warn("FetchForeignNames: Scryfall returned a non-200", "Status Code", dl.StatusCode)
+		return fmt.Errorf("Scryfall returned a non-200")
+	}
+	names, err := buildForeignNames(dl.Body)
+	if err != nil {
+		raven.CaptureError(err, nil)
+		return err
+	}
+	return writeJSONFile(foreignNamesFile, names)
+}
+
+// importForeignNames loads the saved names, fetching them first if there aren't any or we're told to
+func importForeignNames(forceFetch bool) error {
+	log.Debug("In importForeignNames", "Forced?", forceFetch)
+	if _, err := os.Stat(foreignNamesFile); forceFetch || err != nil {
+		if err := fetchForeignNames(); err != nil {
+			log.Warn("Error fetching foreign names", "Error", err)
+			return err
+		}
+	}
+	var names map[string]foreignName
+	if err := readJSONFile(foreignNamesFile, &names); err != nil {
+		raven.CaptureError(err, nil)
+		log.Warn("Error reading foreign names", "Error", err)
+		return err
+	}
+	setForeignNames(names)
+	log.Debug("Populated foreign names", "Length", len(names))
+	return nil
+}
+
+func setForeignNames(names map[string]foreignName) {
+	foreignNamesLock.Lock()
+	defer foreignNamesLock.Unlock()
+	foreignNames = names
+}
+
+func refreshForeignNamesTimer() {
+	for {
+		time.Sleep(foreignNamesRefreshTimer)
+		log.Debug("Refreshing foreign names")
+		if err := importForeignNames(true); err != nil {
+			log.Warn("Refresh foreign names timer", "Error", err)
+		}
+	}
+}
+
+// lookupForeignName finds what a name in another language is for, as long as it isn't an English name too
+func lookupForeignName(input string) (foreignName, bool) {
+	foreignNamesLock.RLock()
+	fn, ok := foreignNames[normaliseForeignName(input)]
+	foreignNamesLock.RUnlock()
+	if !ok || isCardName(input) {
+		return foreignName{}, false
+	}
+	return fn, true
+}
+
+// foreignCard finds the English card for a name in another language, from the longest run of words that is one,
+// down to runs of shortest words
+func (params *fryatogParams) foreignCard(cardTokens []string, shortest int) (Card, int, foreignName, bool) {
+	for i := len(cardTokens); i >= max(shortest, 1); i-- {
+		fn, ok := lookupForeignName(strings.Join(cardTokens[:i], " "))
+		if !ok {
+			continue
+		}
+		card, face, err := findCardFace([]string{fn.Name}, false, params.source.Named)
+		if err != nil {
+			continue
+		}
+		foreignLookups.Add(1)
+		return card, face, fn, true
+	}
+	return Card{}, -1, foreignName{}, false
+}
+
+// formatForeignCard shows the English card, and how to get it in the language it was asked for in
+func (params *fryatogParams) formatForeignCard(card *Card, face int, fn foreignName) string {
+	ret := params.formatCardFace(card, face)
+	if !stringSliceContains(languagePrefixes, fn.Lang) {
+		return ret
+	}
+	return fmt.Sprintf("%s · !%s %s for the %s card", ret, fn.Lang, card.Name, languageNames[fn.Lang])
+}
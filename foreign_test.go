@@ -0,0 +1,101 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const testAllCards = `[
+	{"name": "Ponder", "oracle_id": "o-ponder", "lang": "en"},
+	{"name": "Ponder", "oracle_id": "o-ponder", "lang": "ja", "printed_name": "思案"},
+	{"name": "Ponder", "oracle_id": "o-ponder", "lang": "de", "printed_name": "Grübeln"},
+	{"name": "Ponder", "oracle_id": "o-ponder", "lang": "la", "printed_name": "Cogitare"},
+	{"name": "Island", "oracle_id": "o-island", "lang": "pt", "printed_name": "Island"},
+	{"name": "Fire // Ice", "oracle_id": "o-fireice", "lang": "fr", "card_faces": [
+		{"name": "Fire", "printed_name": "Feu"},
+		{"name": "Ice", "printed_name": "Glace"}
+	]}
+]`
+
+func TestBuildForeignNames(t *testing.T) {
+	names, err := buildForeignNames(strings.NewReader(testAllCards))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	tables := []struct {
+		printed string
+		want    foreignName
+		found   bool
+	}{
+		{"思案", foreignName{OracleID: "o-ponder", Name: "Ponder", Lang: "ja"}, true},
+		{"grübeln", foreignName{OracleID: "o-ponder", Name: "Ponder", Lang: "de"}, true},
+		{"Glace", foreignName{OracleID: "o-fireice", Name: "Ice", Lang: "fr"}, true},
+		// The same as the English name
+		{"Island", foreignName{}, false},
+		{"Ponder", foreignName{}, false},
+	}
+	for _, table := range tables {
+		got, ok := names[normaliseForeignName(table.printed)]
+		if ok != table.found || got != table.want {
+			t.Errorf("Incorrect foreign name for %s -- got %v %v -- want %v %v", table.printed, got, ok, table.want, table.found)
+		}
+	}
+}
+
+func TestForeignCard(t *testing.T) {
+	var err error
+	nameToCardCache, err = lru.NewARC(2048)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	names, err := buildForeignNames(strings.NewReader(testAllCards))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	setForeignNames(names)
+	defer setForeignNames(nil)
+	params := &fryatogParams{isIRC: true, source: newFakeScryfall(t)}
+	ponder, err := params.source.Named("Ponder", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	english := params.formatCardFace(&ponder, -1)
+
+	tables := []struct {
+		input  string
+		output string
+	}{
+		{"思案", english + " · !ja Ponder for the Japanese card"},
+		{"Grübeln", english + " · !de Ponder for the German card"},
+		// There's no !la
+		{"Cogitare", english},
+	}
+	for _, table := range tables {
+		card, face, fn, ok := params.foreignCard(strings.Fields(table.input), 1)
+		if !ok {
+			t.Errorf("Card not found for %s", table.input)
+			continue
+		}
+		if got := params.formatForeignCard(&card, face, fn); got != table.output {
+			t.Errorf("Incorrect output for %s -- got %q -- want %q", table.input, got, table.output)
+		}
+	}
+	if _, _, _, ok := params.foreignCard([]string{"Grübeln", "bitte"}, 2); ok {
+		t.Errorf("Found a card in part of the input when asked for all of it")
+	}
+	if _, _, _, ok := params.foreignCard([]string{"Grübeln", "bitte"}, 1); !ok {
+		t.Errorf("Didn't find a card in part of the input")
+	}
+
+	// A name that's English as well as foreign is English
+	setCardNameSet([]string{"Ponder", "Grübeln"})
+	defer setCardNameSet(nil)
+	if _, ok := lookupForeignName("grübeln"); ok {
+		t.Errorf("Took an English card name for a foreign one")
+	}
+	if _, ok := lookupForeignName("思案"); !ok {
+		t.Errorf("Didn't find a foreign name")
+	}
+}
@@ -10,7 +10,7 @@ import (
 func handleHearthstoneQuery(cardTokens []string) string {
 	hearthstoneRequests.Add(1)
 	for _, rc := range reduceCardSentence(cardTokens) {
-		card, err := searchHSCard(rc)
+		card, err := searchHSCard(rc.name)
 		log.Debug("HS Card Func gave us", "CardID", card, "Err", err)
 		if err == nil {
 			return card
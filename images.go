@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Which face "front" and "back" mean
+var imageFaceSelectors = map[string]int{
+	"front": 0,
+	"back":  1,
+}
+
+// imageQuery is a parsed !img or !art request
+type imageQuery struct {
+	cardTokens []string
+	face       int // -1 if not asked for
+}
+
+// cardImage is the picture of one face of a card, and who drew it
+type cardImage struct {
+	name        string
+	url         string
+	artist      string
+	scryfallURI string
+}
+
+// slackImage is what Slack needs to show a picture as an image block
+type slackImage struct {
+	URL     string
+	Title   string
+	Credit  string
+	Link    string
+	AltText string
+}
+
+// slackImages are the pictures handlers want shown as image blocks, which can't be sent back as text.
+// The handlers run alongside each other, so they take turns adding to it.
+type slackImages struct {
+	lock   sync.Mutex
+	images []slackImage
+}
+
+func (si *slackImages) add(img slackImage) {
+	si.lock.Lock()
+	defer si.lock.Unlock()
+	si.images = append(si.images, img)
+}
+
+func (si *slackImages) all() []slackImage {
+	si.lock.Lock()
+	defer si.lock.Unlock()
+	return append([]slackImage{}, si.images...)
+}
+
+// parseImageQuery pulls a "front" or "back" off the end of the card name.
+func parseImageQuery(tokens []string) imageQuery {
+	iq := imageQuery{cardTokens: tokens, face: -1}
+	if len(tokens) > 1 {
+		if face, ok := imageFaceSelectors[strings.ToLower(tokens[len(tokens)-1])]; ok {
+			iq.cardTokens = tokens[:len(tokens)-1]
+			iq.face = face
+		}
+	}
+	return iq
+}
+
+// facesWithImages says whether each face of this card has its own picture, like a DFC,
+// rather than sharing one, like a split or flip card
+func (card *Card) facesWithImages() bool {
+	return len(card.CardFaces) > 0 && card.CardFaces[0].ImageUris.Normal != ""
+}
+
+// image finds the picture of a face of the card, or its art alone.
+// A face of -1 means whichever face has the name that was asked for, or the front.
+func (card *Card) image(asked string, face int, artCrop bool) (cardImage, error) {
+	if face < 0 {
+		face = 0
+		for i, cf := range card.CardFaces {
+			if normaliseCardName(cf.Name) == normaliseCardName(asked) {
+				face = i
+			}
+		}
+	}
+
+	ci := cardImage{name: card.Name, artist: card.Artist, scryfallURI: card.ScryfallURI}
+	uris := card.ImageUris
+	if card.facesWithImages() && face < len(card.CardFaces) {
+		cf := card.CardFaces[face]
+		ci.name = cf.Name
+		if cf.Artist != "" {
+			ci.artist = cf.Artist
+		}
+		uris = cf.ImageUris
+	}
+	if artCrop {
+		ci.url = uris.ArtCrop
+	} else {
+		ci.url = nco(uris.Large, uris.Normal)
+	}
+	if ci.url == "" {
+		return cardImage{}, fmt.Errorf("No image found for %s", ci.name)
+	}
+	return ci, nil
+}
+
+func (ci cardImage) formatForIRC() string {
+	ret := fmt.Sprintf("\x02%s\x0F · %s", ci.name, ci.url)
+	if ci.artist != "" {
+		ret += fmt.Sprintf(" · Illustrated by %s", ci.artist)
+	}
+	return ret
+}
+
+func (ci cardImage) slackImage() slackImage {
+	si := slackImage{URL: ci.url, Title: ci.name, Link: ci.scryfallURI, AltText: ci.name}
+	if ci.artist != "" {
+		si.Credit = fmt.Sprintf("Illustrated by %s", ci.artist)
+	}
+	return si
+}
+
+// formatForSlack is the picture as a link, for when there's nowhere to put an image block
+func (ci cardImage) formatForSlack() string {
+	ret := fmt.Sprintf("*<%s|%s>* · %s", ci.scryfallURI, ci.name, ci.url)
+	if ci.artist != "" {
+		ret += fmt.Sprintf(" · Illustrated by %s", ci.artist)
+	}
+	return ret
+}
+
+func handleImageQuery(params *fryatogParams, cardTokens []string, artCrop bool) string {
+	imageRequests.Add(1)
+	iq := parseImageQuery(cardTokens)
+	card, err := findCard(iq.cardTokens, false, params.source.Named)
+	// "front" and "back" might just be part of the name, like Storm Front
+	if iq.face >= 0 && (err != nil || len(card.CardFaces) == 0) {
+		iq = imageQuery{cardTokens: cardTokens, face: -1}
+		card, err = findCard(iq.cardTokens, false, params.source.Named)
+	}
+	if err != nil {
+		return "Card not found"
+	}
+	ci, err := card.image(strings.Join(iq.cardTokens, " "), iq.face, artCrop)
+	if err != nil {
+		return err.Error()
+	}
+	if params.isIRC {
+		return ci.formatForIRC()
+	}
+	if params.images != nil {
+		params.images.add(ci.slackImage())
+		return ""
+	}
+	return ci.formatForSlack()
+}
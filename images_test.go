@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+func TestParseImageQuery(t *testing.T) {
+	tables := []struct {
+		input  string
+		output imageQuery
+	}{
+		{"Ponder", imageQuery{cardTokens: []string{"Ponder"}, face: -1}},
+		{"Delver of Secrets back", imageQuery{cardTokens: []string{"Delver", "of", "Secrets"}, face: 1}},
+		{"Delver of Secrets FRONT", imageQuery{cardTokens: []string{"Delver", "of", "Secrets"}, face: 0}},
+		{"Back", imageQuery{cardTokens: []string{"Back"}, face: -1}},
+	}
+	for _, table := range tables {
+		got := parseImageQuery(strings.Fields(table.input))
+		if !reflect.DeepEqual(got, table.output) {
+			t.Errorf("Incorrect output for %s -- got %+v -- want %+v", table.input, got, table.output)
+		}
+	}
+}
+
+func TestImageQuery(t *testing.T) {
+	var err error
+	nameToCardCache, err = lru.NewARC(2048)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	source := newFakeScryfall(t)
+
+	tables := []struct {
+		input  string
+		art    bool
+		output string
+	}{
+		{"Ponder", false, "\x02Ponder\x0F · https://img.scryfall.com/cards/large/en/c18/96.jpg?1535503251 · Illustrated by Dan Scott"},
+		{"Ponder", true, "\x02Ponder\x0F · https://img.scryfall.com/cards/art_crop/en/c18/96.jpg?1535503251 · Illustrated by Dan Scott"},
+		{"Jace, Vryn's Prodigy", false, "\x02Jace, Vryn's Prodigy\x0F · https://img.scryfall.com/cards/large/en/ori/60a.jpg?1527690191 · Illustrated by Jaime Jones"},
+		{"Jace, Vryn's Prodigy back", true, "\x02Jace, Telepath Unbound\x0F · https://img.scryfall.com/cards/art_crop/en/ori/60b.jpg?1527690191 · Illustrated by Jaime Jones"},
+		{"Jace, Telepath Unbound", false, "\x02Jace, Telepath Unbound\x0F · https://img.scryfall.com/cards/large/en/ori/60b.jpg?1527690191 · Illustrated by Jaime Jones"},
+		{"Ponder back", false, "\x02Ponder\x0F · https://img.scryfall.com/cards/large/en/c18/96.jpg?1535503251 · Illustrated by Dan Scott"},
+		{"Lightning Bolt", false, "Card not found"},
+	}
+	for _, table := range tables {
+		got := handleImageQuery(&fryatogParams{isIRC: true, source: source}, strings.Fields(table.input), table.art)
+		if got != table.output {
+			t.Errorf("Incorrect output for %s -- got %q -- want %q", table.input, got, table.output)
+		}
+	}
+
+	images := &slackImages{}
+	got := handleImageQuery(&fryatogParams{source: source, images: images}, []string{"Jace,", "Vryn's", "Prodigy", "back"}, false)
+	want := slackImage{
+		URL:     "https://img.scryfall.com/cards/large/en/ori/60b.jpg?1527690191",
+		Title:   "Jace, Telepath Unbound",
+		Credit:  "Illustrated by Jaime Jones",
+		Link:    "https://scryfall.com/card/ori/60/jace-vryns-prodigy-jace-telepath-unbound?utm_source=api",
+		AltText: "Jace, Telepath Unbound",
+	}
+	if si := images.all(); got != "" || len(si) != 1 || si[0] != want {
+		t.Errorf("Incorrect Slack image -- got %q %+v -- want %+v", got, si, want)
+	}
+
+	// Without anywhere to put an image block, it's a link
+	got = handleImageQuery(&fryatogParams{source: source}, []string{"Ponder"}, false)
+	if want := "*<https://scryfall.com/card/c18/96/ponder?utm_source=api|Ponder>* · https://img.scryfall.com/cards/large/en/c18/96.jpg?1535503251 · Illustrated by Dan Scott"; got != want {
+		t.Errorf("Incorrect Slack link -- got %q -- want %q", got, want)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// gameFormat is a format Scryfall tracks legality for, with the names we show for it
+type gameFormat struct {
+	key   string
+	short string
+	long  string
+}
+
+// knownFormats are in the order we list them
+var knownFormats = []gameFormat{
+	{"standard", "Std", "Standard"},
+	{"pioneer", "Pio", "Pioneer"},
+	{"modern", "Mod", "Modern"},
+	{"legacy", "Leg", "Legacy"},
+	{"vintage", "Vin", "Vintage"},
+	{"commander", "Cmr", "Commander"},
+	{"pauper", "Pau", "Pauper"},
+	{"paupercommander", "PauCmr", "Pauper Commander"},
+	{"oathbreaker", "Oat", "Oathbreaker"},
+	{"duel", "Duel", "Duel Commander"},
+	{"predh", "PreDH", "PreDH"},
+	{"brawl", "Brw", "Brawl"},
+	{"standardbrawl", "StdBrw", "Standard Brawl"},
+	{"historic", "His", "Historic"},
+	{"timeless", "Tim", "Timeless"},
+	{"explorer", "Exp", "Explorer"},
+	{"alchemy", "Alc", "Alchemy"},
+	{"gladiator", "Gla", "Gladiator"},
+	{"premodern", "Pre", "Premodern"},
+	{"oldschool", "Old", "Old School"},
+	{"penny", "Pen", "Penny Dreadful"},
+	{"future", "Fut", "Future Standard"},
+	{"frontier", "Fro", "Frontier"},
+}
+
+// Other things people call formats
+var formatAliases = map[string]string{
+	"edh":            "commander",
+	"pdh":            "paupercommander",
+	"cedh":           "commander",
+	"93/94":          "oldschool",
+	"9394":           "oldschool",
+	"penny dreadful": "penny",
+}
+
+// The formats shown on a card line, unless a channel has asked for something else
+var defaultLegalityFormats = []string{"vintage", "commander", "legacy", "modern", "pioneer", "standard"}
+
+// lookupFormat works out which format was meant, by its key, short name, long name or alias.
+func lookupFormat(input string) (gameFormat, bool) {
+	input = strings.ToLower(strings.TrimSpace(input))
+	if alias, ok := formatAliases[input]; ok {
+		input = alias
+	}
+	ncn := normaliseCardName(input)
+	for _, f := range knownFormats {
+		if input == f.key || input == strings.ToLower(f.short) || ncn == normaliseCardName(f.long) {
+			return f, true
+		}
+	}
+	return gameFormat{}, false
+}
+
+// formatFor gives the names to show for a format, even one Scryfall has added since we last looked.
+func formatFor(key string) gameFormat {
+	if f, ok := lookupFormat(key); ok || key == "" {
+		return f
+	}
+	short := key
+	if len(short) > 3 {
+		short = short[:3]
+	}
+	return gameFormat{key, strings.ToUpper(short[:1]) + short[1:], strings.ToUpper(key[:1]) + key[1:]}
+}
+
+// channelLegalityFormats gives the formats to show on card lines in a channel
+func channelLegalityFormats(channel string) []string {
+	if formats, ok := conf.ChannelFormats[channel]; ok && len(formats) > 0 {
+		return formats
+	}
+	return defaultLegalityFormats
+}
+
+// legalityFormats gives the formats to show on card lines wherever this message came from
+func (params *fryatogParams) legalityFormats() []string {
+	return channelLegalityFormats(params.channel)
+}
+
+// formatLegalityStatus answers whether a card can be played in a single format.
+func (card *Card) formatLegalityStatus(f gameFormat) string {
+	switch card.Legalities[f.key] {
+	case "legal":
+		return fmt.Sprintf("%s is legal in %s", card.Name, f.long)
+	case "restricted":
+		return fmt.Sprintf("%s is restricted in %s", card.Name, f.long)
+	case "banned":
+		return fmt.Sprintf("%s is banned in %s", card.Name, f.long)
+	case "not_legal":
+		return fmt.Sprintf("%s is not legal in %s", card.Name, f.long)
+	}
+	return fmt.Sprintf("No legality information for %s in %s", card.Name, f.long)
+}
+
+// formatAllLegalities groups every format a card has a status in, by that status.
+func (card *Card) formatAllLegalities() string {
+	var keys []string
+	for _, f := range knownFormats {
+		if _, ok := card.Legalities[f.key]; ok {
+			keys = append(keys, f.key)
+		}
+	}
+	var unknown []string
+	for k := range card.Legalities {
+		if _, ok := lookupFormat(k); !ok {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+	keys = append(keys, unknown...)
+
+	byStatus := make(map[string][]string)
+	for _, k := range keys {
+		byStatus[card.Legalities[k]] = append(byStatus[card.Legalities[k]], formatFor(k).long)
+	}
+	var ret []string
+	for _, status := range []struct{ key, title string }{{"legal", "Legal"}, {"restricted", "Restricted"}, {"banned", "Banned"}} {
+		if len(byStatus[status.key]) > 0 {
+			ret = append(ret, fmt.Sprintf("%s: %s", status.title, strings.Join(byStatus[status.key], ", ")))
+		}
+	}
+	if len(ret) == 0 {
+		return fmt.Sprintf("%s is not legal in any format", card.Name)
+	}
+	return fmt.Sprintf("%s · %s", card.Name, strings.Join(ret, " · "))
+}
+
+func handleLegalQuery(params *fryatogParams, cardTokens []string) string {
+	legalRequests.Add(1)
+	// The format, if given, is at the end; it might be two words
+	for n := 2; n >= 1; n-- {
+		if len(cardTokens) <= n {
+			continue
+		}
+		f, ok := lookupFormat(strings.Join(cardTokens[len(cardTokens)-n:], " "))
+		if !ok {
+			continue
+		}
+		card, err := findCard(cardTokens[:len(cardTokens)-n], false, params.source.Named)
+		if err != nil {
+			return "Card not found"
+		}
+		return card.formatLegalityStatus(f)
+	}
+	card, err := findCard(cardTokens, false, params.source.Named)
+	if err != nil {
+		return "Card not found"
+	}
+	return card.formatAllLegalities()
+}
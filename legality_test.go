@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+func TestLookupFormat(t *testing.T) {
+	tables := []struct {
+		input  string
+		output string
+		ok     bool
+	}{
+		{"vintage", "vintage", true},
+		{"Vin", "vintage", true},
+		{"EDH", "commander", true},
+		{"pauper commander", "paupercommander", true},
+		{"Old School", "oldschool", true},
+		{"93/94", "oldschool", true},
+		{"hearthstone", "", false},
+	}
+	for _, table := range tables {
+		got, ok := lookupFormat(table.input)
+		if ok != table.ok || got.key != table.output {
+			t.Errorf("Incorrect format for %s -- got %v %v -- want %v %v", table.input, got.key, ok, table.output, table.ok)
+		}
+	}
+}
+
+func TestFormatLegalities(t *testing.T) {
+	fi, err := os.Open(RealCards["Ponder"])
+	if err != nil {
+		t.Fatalf("Unable to open %v", RealCards["Ponder"])
+	}
+	var c Card
+	if err := json.NewDecoder(fi).Decode(&c); err != nil {
+		t.Fatalf("Something went wrong parsing the card: %s", err)
+	}
+	tables := []struct {
+		formats []string
+		output  string
+	}{
+		{defaultLegalityFormats, "VinRes,Cmr,Leg,ModBan"},
+		{[]string{"pauper", "paupercommander", "commander"}, "Pau,Cmr"},
+		{[]string{"standard"}, ""},
+	}
+	for _, table := range tables {
+		if got := c.formatLegalities(table.formats); got != table.output {
+			t.Errorf("Incorrect output for %v -- got %s -- want %s", table.formats, got, table.output)
+		}
+	}
+
+	conf.ChannelFormats = map[string][]string{"#mtgpauper": {"pauper"}}
+	defer func() { conf.ChannelFormats = nil }()
+	if got := channelLegalityFormats("#mtgpauper"); len(got) != 1 || got[0] != "pauper" {
+		t.Errorf("Incorrect channel formats -- got %v", got)
+	}
+	if got := channelLegalityFormats("##mtg"); len(got) != len(defaultLegalityFormats) {
+		t.Errorf("Expected the default formats -- got %v", got)
+	}
+	if got := c.formatCardForIRCWithFormats(channelLegalityFormats("#mtgpauper")); !strings.HasSuffix(got, "· C18-C · Pau") {
+		t.Errorf("Incorrect card line for a Pauper channel -- got %s", got)
+	}
+}
+
+func TestLegalQuery(t *testing.T) {
+	var err error
+	nameToCardCache, err = lru.NewARC(2048)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	source := newFakeScryfall(t)
+	tables := []struct {
+		input  string
+		output string
+	}{
+		{"Ponder", "Ponder · Legal: Legacy, Commander, Pauper, Duel Commander · Restricted: Vintage · Banned: Modern"},
+		{"Ponder modern", "Ponder is banned in Modern"},
+		{"Ponder vintage", "Ponder is restricted in Vintage"},
+		{"Ponder pauper", "Ponder is legal in Pauper"},
+		{"Ponder Old School", "Ponder is not legal in Old School"},
+		{"Ponder alchemy", "No legality information for Ponder in Alchemy"},
+		{"Ancestral Recall edh", "Ancestral Recall is banned in Commander"},
+		{"Lightning Bolt legacy", "Card not found"},
+	}
+	for _, table := range tables {
+		got := handleLegalQuery(&fryatogParams{isIRC: true, source: source}, strings.Fields(table.input))
+		if got != table.output {
+			t.Errorf("Incorrect output for %s -- got %q -- want %q", table.input, got, table.output)
+		}
+	}
+}
@@ -10,8 +10,10 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
+	"unicode/utf8"
 
 	blizzard "github.com/FuzzyStatic/blizzard/v2"
 	"github.com/FuzzyStatic/blizzard/v2/wowgd"
@@ -44,7 +46,17 @@ var (
 	cardNames      []string
 	shortCardNames = make(map[string]string)
 
-	highlanderPoints = make(map[string]int)
+	// Local index of Scryfall's bulk card data, replaced whole when it's refreshed
+	cardIndex atomic.Pointer[CardIndex]
+
+	// Where card lookups go
+	cardSource CardSource = newScryfallSource(scryfallAPIURL)
+
+	// Recurring posts
+	postScheduler *scheduler
+
+	// Watches for new previews
+	spoilers *spoilerWatcher
 
 	// How often to dump the card cache
 	cacheDumpTimer = 10 * time.Minute
@@ -61,30 +73,53 @@ var (
 	wowChieves           *wowgd.AchievementIndex
 )
 
+// Commands whose arguments can run past the length we cut card names down to
+var untruncatedCommands = []string{"search ", "random ", "price ", "printings ", "legal ", "wow", "deck", "points ", "commander check ", "ruling ", "rulings-mentioning "}
+
 const cardCacheGob = "cardcache.gob"
 const cardShortNameFile = "short_names.json"
 
-// CardGetter defines a function that retrieves a card's text.
-// Defining this type allows us to override it in testing, and not hit scryfall.com a million times.
-type CardGetter func(cardname string, isLang bool) (Card, error)
-
-// RandomCardGetter defines a function that retrieves a random card's text.
-type RandomCardGetter func(cardTokens []string) (Card, error)
-
-// MultipleCardGetter defines a function that retrieves a bunch of cards.
-type MultipleCardGetter func(searchTokens []string) ([]Card, error)
+// CardSource defines everywhere we get card data from.
+// Defining this interface allows us to override it in testing, and not hit scryfall.com a million times.
+type CardSource interface {
+	// Named retrieves a card by (roughly) its name
+	Named(cardname string, isLang bool) (Card, error)
+	// Fuzzy retrieves a card by Scryfall's best guess at its name
+	Fuzzy(cardname string, isLang bool) (Card, error)
+	// Dumb retrieves un-cards, vanguards, planes and schemes by name
+	Dumb(cardname string, isLang bool) (Card, error)
+	// Random retrieves a random card matching a search
+	Random(cardTokens []string) (Card, error)
+	// Search retrieves a bunch of cards matching a search
+	Search(searchTokens []string) ([]Card, error)
+	// Rulings retrieves a card's rulings
+	Rulings(card *Card) ([]CardRuling, error)
+	// Prints retrieves a card's printings, optionally in every language, and only the first page of them unless asked for all
+	Prints(card *Card, multilingual bool, allPages bool) ([]Card, error)
+	// Language retrieves a card's printing in another language
+	Language(card *Card, lang string) (Card, error)
+	// Printing retrieves one printing of a card, by set and collector number or by name and set
+	Printing(cardname string, set string, number string) (Card, error)
+	// Related retrieves a card that another refers to, like a token it makes
+	Related(part RelatedCard) (Card, error)
+	// Tokens retrieves the tokens with a name, and optionally a power and toughness like 2/2
+	Tokens(name string, pt string) ([]Card, error)
+	// SetCards retrieves every card in a set, as they were printed there
+	SetCards(set string) ([]Card, error)
+}
 
 // fryatogParams contains the common things passed to and from functions.
 type fryatogParams struct {
-	m                     *hbot.Message
-	slackm                string
-	isIRC                 bool
-	message               string
-	fullInput             string
-	cardGetFunction       CardGetter
-	dumbCardGetFunction   CardGetter
-	randomCardGetFunction RandomCardGetter
-	cardFindFunction      MultipleCardGetter
+	m         *hbot.Message
+	slackm    string
+	isIRC     bool
+	message   string
+	fullInput string
+	channel   string
+	user      string
+	source    CardSource
+	// Where pictures for Slack image blocks go, since they aren't text
+	images *slackImages
 }
 
 func recovery() {
@@ -117,11 +152,29 @@ func init() {
 func printHelp() string {
 	var ret []string
 	ret = append(ret, "!cardname to bring up that card's rules text")
+	ret = append(ret, "!card <cardname> full to bring up every face of a card, even when you named just one")
 	ret = append(ret, "!reminder <cardname> to bring up that card's reminder text")
 	ret = append(ret, "!ruling <cardname> [ruling number] to bring up Gatherer rulings")
+	ret = append(ret, "!ruling <cardname> <keyword> [page N] to search a card's rulings, or !ruling <cardname> page N to see more of them")
+	ret = append(ret, "!rulings-mentioning <cardname> to find the rulings on other cards that talk about it")
 	ret = append(ret, "!rule <rulename> to bring up a Comprehensive Rule entry")
 	ret = append(ret, "!define <glossary> to bring up the definition of a term")
 	ret = append(ret, "!uncard/vanguard/plane/scheme <cardname> to bring up normally filtered out cards")
+	ret = append(ret, "!price <cardname> [set:XYZ] [foil] to bring up a card's current prices")
+	ret = append(ret, "!legal <cardname> [format] to bring up a card's legality in every format, or just one")
+	ret = append(ret, "[[cardname|SET]], [[cardname|SET|number]] or !card SET number to bring up a specific printing")
+	ret = append(ret, "!img <cardname> [front/back] to bring up a picture of the card; !art <cardname> [front/back] for just its art")
+	ret = append(ret, "!errata <cardname> to compare a card's old printed wordings with its Oracle text, for the cards we have them for")
+	ret = append(ret, "!momir <mv>, !jhoira instant/sorcery and !stonehewer <mv> for MoJhoSto; !mojhosto start/turn/status/end to track your avatars each turn")
+	ret = append(ret, "!tokens <cardname> to bring up the tokens a card makes, or !token <name> [P/T] to find a token")
+	ret = append(ret, "!pack <set> to open a booster, or !sealed <set> for a six pack sealed pool")
+	ret = append(ret, "!spoilers to see which sets are being watched for new previews here")
+	ret = append(ret, "!bans <format> for recent changes to the banned and restricted list")
+	ret = append(ret, "!ci <cardname> to bring up a card's color identity")
+	ret = append(ret, "!commander <cardname> to see if a card can be your commander, or !commander check <commander>, <cardname> to see if a card fits its color identity")
+	ret = append(ret, "!points [list] <card, card, ...> to add up a Highlander list's points (lists: canadian, 7point, european)")
+	ret = append(ret, "!deck <format> followed by a decklist to check it, !deck save <name> to keep one of your own, and !deck <name> <format> to check it again")
+	ret = append(ret, "!printings <cardname> [paper-only] [year or year-year] [page N] to list every printing of a card")
 	ret = append(ret, "!url <mtr/ipg/cr/jar> to bring up the links to policy documents")
 	ret = append(ret, "!roll <X> to roll X-sided die; !roll <XdY> to roll X Y-sided dice")
 	ret = append(ret, "!coin to flip a coin (heads/tails); !coin <X> to flip X coins")
@@ -150,11 +203,24 @@ func isSenderAnOp(m *hbot.Message) bool {
 // tokeniseAndDispatchInput splits the given user-supplied string into a number of commands
 // and does some pre-processing to sort out real commands from just normal chat
 // Any real commands are handed to the handleCommand function
-func tokeniseAndDispatchInput(fp *fryatogParams, cardGetFunction CardGetter, dumbCardGetFunction CardGetter, randomCardGetFunction RandomCardGetter, cardFindFunction MultipleCardGetter) []string {
+func isUntruncatedCommand(message string) bool {
+	for _, prefix := range untruncatedCommands {
+		if strings.HasPrefix(message, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func tokeniseAndDispatchInput(fp *fryatogParams, source CardSource) []string {
 	var input string
+	channel := fp.channel
+	user := fp.user
 	isIRC := (fp.m != nil)
 	if isIRC {
 		input = fp.m.Content
+		channel = fp.m.To
+		user = fp.m.From
 	} else if fp.slackm != "" {
 		input = fp.slackm
 	} else {
@@ -210,7 +276,19 @@ func tokeniseAndDispatchInput(fp *fryatogParams, cardGetFunction CardGetter, dum
 				log.Warn("Error importing card names", "Error", err)
 				return []string{"Problem!"}
 			}
+			if err := importSets(true); err != nil {
+				return []string{"Problem!"}
+			}
 			return []string{"Done!"}
+		case input == "!updatebulkdata" && isSenderAnOp(fp.m):
+			ci, err := importBulkData(true)
+			if err != nil {
+				log.Warn("Error importing bulk data", "Error", err)
+				return []string{"Problem!"}
+			}
+			cardIndex.Store(ci)
+			checkLegalities(ci)
+			return []string{fmt.Sprintf("Done! (%d cards)", ci.Len())}
 		case input == "!startup" && isSenderAnOp(fp.m):
 			var ret []string
 			cardNames, err = importCardNames(false)
@@ -219,6 +297,14 @@ func tokeniseAndDispatchInput(fp *fryatogParams, cardGetFunction CardGetter, dum
 			}
 			ret = append(ret, "Done!")
 			return ret
+		case strings.HasPrefix(input, "!schedule") && postScheduler != nil && isSenderAnOp(fp.m):
+			return []string{postScheduler.handleScheduleCommand(strings.Fields(input)[1:])}
+		case strings.HasPrefix(input, "!rulingorder") && isSenderAnOp(fp.m):
+			return []string{handleRulingOrderCommand(&fryatogParams{m: fp.m, source: source}, strings.Fields(input)[1:])}
+		case strings.HasPrefix(input, "!printedwording") && isSenderAnOp(fp.m):
+			return []string{handlePrintedWordingCommand(&fryatogParams{m: fp.m, source: source}, strings.TrimPrefix(input, "!printedwording"))}
+		case strings.HasPrefix(input, "!spoilers ") && spoilers != nil && isSenderAnOp(fp.m):
+			return []string{spoilers.handleSpoilersCommand(channel, strings.Fields(input)[1:])}
 		case input == "!dumpcardcache" && isSenderAnOp(fp.m):
 			if err := dumpCardCache(&conf, nameToCardCache); err != nil {
 				raven.CaptureErrorAndWait(err, nil)
@@ -246,7 +332,8 @@ func tokeniseAndDispatchInput(fp *fryatogParams, cardGetFunction CardGetter, dum
 			log.Info("Triple Iffy Skip", "Message", message)
 			continue
 		}
-		if wordEndingInBang.MatchString(message) && !wordStartingWithBang.MatchString(message) {
+		// Decklists are pasted a card per line, so their newlines aren't the end of the command
+		if wordEndingInBang.MatchString(message) && !wordStartingWithBang.MatchString(message) && !strings.HasPrefix(message, "!deck") {
 			log.Info("WEIB Skip")
 			continue
 		}
@@ -267,7 +354,9 @@ func tokeniseAndDispatchInput(fp *fryatogParams, cardGetFunction CardGetter, dum
 			previousCommandWasValidBang = false
 			continue
 		}
-		message = strings.TrimPrefix(message, "card ")
+		if !cardPrintingRegex.MatchString(message) {
+			message = strings.TrimPrefix(message, "card ")
+		}
 
 		// Longest possible card name query is ~30 chars
 
@@ -276,12 +365,13 @@ func tokeniseAndDispatchInput(fp *fryatogParams, cardGetFunction CardGetter, dum
 		// Last time it bit us, the query '!ruling kozilek the great distortion 1'
 		// was getting chopped off because we had this capped at 35.
 		// Maybe look for some way to make this more robust and Actually Programmatic.
-		if !strings.HasPrefix(message, "search ") && !strings.HasPrefix(message, "random ") && !strings.HasPrefix(message, "wow") && len(message) > 41 {
-			message = message[0:41]
+		if !isUntruncatedCommand(message) && !strings.Contains(message, "|") && utf8.RuneCountInString(message) > 41 {
+			// In runes, so that names in other alphabets aren't cut off mid-letter
+			message = string([]rune(message)[0:41])
 		}
 
 		log.Debug("Dispatching", "index", commands)
-		params := fryatogParams{message: message, fullInput: input, isIRC: isIRC, cardGetFunction: cardGetFunction, dumbCardGetFunction: dumbCardGetFunction, randomCardGetFunction: randomCardGetFunction, cardFindFunction: cardFindFunction}
+		params := fryatogParams{message: message, fullInput: input, isIRC: isIRC, channel: channel, user: user, source: source, images: fp.images}
 		go handleCommand(&params, c)
 		commands++
 	}
@@ -429,12 +519,8 @@ func handleCommand(params *fryatogParams, c chan string) {
 		// Before we search, make sure it's not the actual name of a card
 		for _, x := range cardNames {
 			if normaliseCardName(x) == normaliseCardName(message) {
-				if card, err := findCard(cardTokens, false, params.cardGetFunction); err == nil {
-					if params.isIRC {
-						c <- card.formatCardForIRC()
-					} else {
-						c <- card.formatCardForSlack()
-					}
+				if card, err := findCard(cardTokens, false, params.source.Named); err == nil {
+					c <- params.formatCardFace(&card, -1)
 					return
 				}
 			}
@@ -449,40 +535,130 @@ func handleCommand(params *fryatogParams, c chan string) {
 
 	case cardTokens[0] == "uncard", cardTokens[0] == "vanguard", cardTokens[0] == "plane", cardTokens[0] == "scheme":
 		log.Debug("Special card query", "Input", message)
-		if card, err := findCard(cardTokens[1:], false, params.dumbCardGetFunction); err == nil {
-			if params.isIRC {
-				c <- card.formatCardForIRC()
-			} else {
-				c <- card.formatCardForSlack()
-			}
+		if card, err := findCard(cardTokens[1:], false, params.source.Dumb); err == nil {
+			c <- params.formatCardFace(&card, -1)
 			return
 		}
 
+	case cardTokens[0] == "price" && len(cardTokens) > 1:
+		log.Debug("Price query", "Input", message)
+		c <- handlePriceQuery(params, cardTokens[1:])
+		return
+
+	case (cardTokens[0] == "printings" || cardTokens[0] == "prints") && len(cardTokens) > 1:
+		log.Debug("Printings query", "Input", message)
+		c <- handlePrintingsQuery(params, cardTokens[1:])
+		return
+
+	case cardTokens[0] == "legal" && len(cardTokens) > 1:
+		log.Debug("Legality query", "Input", message)
+		c <- handleLegalQuery(params, cardTokens[1:])
+		return
+
+	case isPrintingLookup(message):
+		log.Debug("Printing lookup", "Input", message)
+		c <- handlePrintingLookup(params, message)
+		return
+
+	case (cardTokens[0] == "img" || cardTokens[0] == "image" || cardTokens[0] == "art") && len(cardTokens) > 1:
+		log.Debug("Image query", "Input", message)
+		c <- handleImageQuery(params, cardTokens[1:], cardTokens[0] == "art")
+		return
+
+	case cardTokens[0] == "errata" && len(cardTokens) > 1:
+		log.Debug("Errata query", "Input", message)
+		c <- handleErrataQuery(params, cardTokens[1:])
+		return
+
+	case cardTokens[0] == "tokens" && len(cardTokens) > 1:
+		log.Debug("Tokens query", "Input", message)
+		c <- handleTokensQuery(params, cardTokens[1:])
+		return
+
+	case cardTokens[0] == "token" && len(cardTokens) > 1:
+		log.Debug("Token query", "Input", message)
+		c <- handleTokenQuery(params, cardTokens[1:])
+		return
+
+	case cardTokens[0] == "pack" && len(cardTokens) > 1 && !isCardName(message):
+		log.Debug("Pack query", "Input", message)
+		c <- handlePackQuery(params, cardTokens[1:])
+		return
+
+	case cardTokens[0] == "sealed" && len(cardTokens) > 1 && !isCardName(message):
+		log.Debug("Sealed query", "Input", message)
+		c <- handleSealedQuery(params, cardTokens[1:])
+		return
+
+	case cardTokens[0] == "spoilers" && len(cardTokens) == 1 && spoilers != nil:
+		log.Debug("Spoilers query", "Input", message)
+		c <- spoilers.watching(params.channel)
+		return
+
+	case cardTokens[0] == "bans" && len(cardTokens) > 1:
+		log.Debug("Bans query", "Input", message)
+		c <- handleBansQuery(params, cardTokens[1:])
+		return
+
+	case cardTokens[0] == "ci" && len(cardTokens) > 1:
+		log.Debug("Color identity query", "Input", message)
+		c <- handleColorIdentityQuery(params, cardTokens[1:])
+		return
+
+	case cardTokens[0] == "commander" && len(cardTokens) > 1 && !isCardName(message):
+		log.Debug("Commander query", "Input", message)
+		c <- handleCommanderQuery(params, cardTokens[1:])
+		return
+
+	case cardTokens[0] == "points" && len(cardTokens) > 1:
+		log.Debug("Points query", "Input", message)
+		c <- handlePointsQuery(params, cardTokens[1:])
+		return
+
+	case cardTokens[0] == "deck":
+		log.Debug("Deck query", "Input", message)
+		// Decklists have all sorts in them, so take the whole thing rather than just the command
+		input := strings.Join(cardTokens[1:], " ")
+		if after, ok := strings.CutPrefix(strings.TrimSpace(params.fullInput), "!deck"); ok {
+			input = after
+		}
+		c <- handleDeckQuery(params, input)
+		return
+
+	case cardTokens[0] == "rulings-mentioning":
+		log.Debug("Asked for rulings mentioning a card")
+		c <- handleRulingsMentioningQuery(params, cardTokens[1:])
+		return
+
 	case cardTokens[0] == "random":
 		log.Debug("Asked for random card")
-		if card, err := getRandomCard(cardTokens[1:], params.randomCardGetFunction); err == nil {
-			if params.isIRC {
-				c <- card.formatCardForIRC()
-			} else {
-				c <- card.formatCardForSlack()
-			}
+		if card, err := getRandomCard(cardTokens[1:], params.source); err == nil {
+			c <- params.formatCardFace(&card, -1)
 			return
 		}
 
 	case cardTokens[0] == "momir":
 		log.Debug("Asked for a Momir card")
 		if len(cardTokens) >= 2 {
-			query := []string{"type:creature", "mv=" + cardTokens[1]}
-			if card, err := getRandomCard(query, params.randomCardGetFunction); err == nil {
-				if params.isIRC {
-					c <- card.formatCardForIRC()
-				} else {
-					c <- card.formatCardForSlack()
-				}
-				return
-			}
+			c <- handleMomirQuery(params, cardTokens[1])
+			return
 		}
 
+	case cardTokens[0] == "jhoira" && len(cardTokens) > 1:
+		log.Debug("Asked for Jhoira cards")
+		c <- handleJhoiraQuery(params, cardTokens[1])
+		return
+
+	case cardTokens[0] == "stonehewer" && len(cardTokens) > 1:
+		log.Debug("Asked for a Stonehewer card")
+		c <- handleStonehewerQuery(params, cardTokens[1])
+		return
+
+	case cardTokens[0] == "mojhosto":
+		log.Debug("MoJhoSto session", "Input", message)
+		c <- handleMojhostoQuery(params, cardTokens[1:])
+		return
+
 	case cardTokens[0] == "en", cardTokens[0] == "es", cardTokens[0] == "fr", cardTokens[0] == "de", cardTokens[0] == "it" && cardTokens[1] != "that", cardTokens[0] == "pt", cardTokens[0] == "ja", cardTokens[0] == "ko", cardTokens[0] == "ru", cardTokens[0] == "zhs", cardTokens[0] == "zht":
 		log.Debug("Asked for card in language", "Input", message)
 		// Before we search for the language, make sure it's not the actual name of a card
@@ -497,8 +673,8 @@ func handleCommand(params *fryatogParams, c chan string) {
 			if cardTokens[0] == "en" {
 				isLang = false
 			}
-			if card, err := findCard(cardTokens[1:], isLang, params.cardGetFunction); err == nil {
-				translatedCard, err := card.cardGetLang(cardTokens[0])
+			if card, err := findCard(cardTokens[1:], isLang, params.source.Named); err == nil {
+				translatedCard, err := params.source.Language(&card, cardTokens[0])
 				if err == nil {
 					if params.isIRC {
 						c <- translatedCard.formatCardForIRC()
@@ -515,16 +691,35 @@ func handleCommand(params *fryatogParams, c chan string) {
 		c <- sendRulesRedirectText(cardTokens)
 		return
 
+	case len(cardTokens) > 1 && cardTokens[len(cardTokens)-1] == "full":
+		log.Debug("Asked for every face of a card")
+		if card, err := findCard(cardTokens[:len(cardTokens)-1], false, params.source.Named); err == nil {
+			c <- params.formatCardFace(&card, -1)
+			return
+		}
+
+	case suggestionPickRegex.MatchString(message):
+		log.Debug("Picking a suggested card", "Input", message)
+		c <- params.pickSuggestion(message)
+		return
+
 	default:
 		log.Debug("I think it's a card")
-		if card, err := findCard(cardTokens, false, params.cardGetFunction); err == nil {
-			if params.isIRC {
-				c <- card.formatCardForIRC()
-			} else {
-				c <- card.formatCardForSlack()
-			}
+		// A whole name in another language beats a near miss in English
+		if card, face, fn, ok := params.foreignCard(cardTokens, len(cardTokens)); ok {
+			c <- params.formatForeignCard(&card, face, fn)
 			return
 		}
+		if card, face, err := findCardFace(cardTokens, false, params.source.Named); err == nil {
+			c <- params.formatCardFace(&card, face)
+			return
+		}
+		if card, face, fn, ok := params.foreignCard(cardTokens, 1); ok {
+			c <- params.formatForeignCard(&card, face, fn)
+			return
+		}
+		c <- params.suggestCards(message)
+		return
 	}
 	// If we got here, no cards found.
 	c <- ""
@@ -539,16 +734,12 @@ func sendRulesRedirectText(cardTokens []string) string {
 
 func handleAdvancedSearchQuery(params *fryatogParams, cardTokens []string) []string {
 	var ret []string
-	cs, err := params.cardFindFunction(cardTokens)
+	cs, err := params.source.Search(cardTokens)
 	if err != nil {
 		return []string{err.Error()}
 	}
 	for _, c := range cs {
-		if params.isIRC {
-			ret = append(ret, c.formatCardForIRC())
-		} else {
-			ret = append(ret, c.formatCardForSlack())
-		}
+		ret = append(ret, params.formatCardFace(&c, -1))
 	}
 	return ret
 }
@@ -560,18 +751,26 @@ func handleCardMetadataQuery(params *fryatogParams, command string) string {
 	)
 	command = strings.ToLower(command)
 	if command == "reminder" {
-		c, err := findCard(strings.Fields(params.message)[1:], false, params.cardGetFunction)
+		c, face, err := findCardFace(strings.Fields(params.message)[1:], false, params.source.Named)
 		if err != nil {
 			return "Card not found"
 		}
-		return c.getReminderTexts()
+		return c.getFaceReminderTexts(face)
 	}
 	if command == "flavor" || command == "flavour" {
-		c, err := findCard(strings.Fields(params.message)[1:], false, params.cardGetFunction)
+		c, face, err := findCardFace(strings.Fields(params.message)[1:], false, params.source.Named)
 		if err != nil {
 			return "Card not found"
 		}
-		return c.getFlavourText()
+		return c.getFaceFlavourText(face)
+	}
+	// Paging through them, which has to come first as the page looks like a ruling number
+	if m := rulingPageRegex.FindStringSubmatch(strings.SplitN(params.message, " ", 2)[1]); m != nil {
+		page, err := strconv.Atoi(m[2])
+		if err != nil {
+			return "Unable to parse page number"
+		}
+		return handleRulingSearch(params, strings.Fields(m[1]), page)
 	}
 	if gathererRulingRegex.MatchString(strings.SplitN(params.message, " ", 2)[1]) {
 		var cardName string
@@ -583,52 +782,70 @@ func handleCardMetadataQuery(params *fryatogParams, command string) string {
 			return ""
 		}
 		if strings.HasPrefix(strings.ToLower(params.message), "ruling") {
-			// If there is no number, set to 0.
+			// If there is no number, anything after the name is what to look for in them
 			if fass[0][1] == "" && fass[0][4] == "" {
-				rulingNumber = 0
-			} else {
-				rulingNumber, err = strconv.Atoi(fass[0][1] + fass[0][4])
-				if err != nil {
-					return "Unable to parse ruling number"
-				}
+				return handleRulingSearch(params, strings.Fields(cardName), 1)
+			}
+			rulingNumber, err = strconv.Atoi(fass[0][1] + fass[0][4])
+			if err != nil {
+				return "Unable to parse ruling number"
 			}
 		}
 		log.Debug("In a Ruling Query - Valid command detected", "Command", command, "Card Name", cardName, "Ruling No.", rulingNumber)
-		c, err := findCard(strings.Split(cardName, " "), false, params.cardGetFunction)
+		c, face, err := findCardFace(strings.Split(cardName, " "), false, params.source.Named)
 		if err != nil {
 			return "Unable to find card"
 		}
-		return c.getRulings(rulingNumber)
+		return c.getFaceRulings(params.source, rulingNumber, face)
 	}
 
 	log.Warn("handleCardMetadataQuery - didn't know what to do", "command", command, "input", params.message)
 	return ""
 }
 
-func findCard(cardTokens []string, isLang bool, cardGetFunction CardGetter) (Card, error) {
+// handleRulingSearch lists a page of a card's rulings, just the ones mentioning whatever comes after the card name if anything does
+func handleRulingSearch(params *fryatogParams, tokens []string, page int) string {
+	c, keywords, err := findCardPrefix(tokens, false, params.source.Named)
+	if err != nil {
+		return "Unable to find card"
+	}
+	face := c.faceNamed(strings.Join(tokens[:len(tokens)-len(keywords)], " "))
+	log.Debug("In a Ruling Query - Search", "Card Name", c.Name, "Keywords", keywords, "Page", page)
+	return c.queryFaceRulings(params.source, rulingQuery{keywords: keywords, page: page}, face)
+}
+
+func findCard(cardTokens []string, isLang bool, cardGetFunction func(cardname string, isLang bool) (Card, error)) (Card, error) {
+	card, _, err := findCardPrefix(cardTokens, isLang, cardGetFunction)
+	return card, err
+}
+
+// findCardPrefix finds the card named by the most tokens from the start, and gives back the tokens left over
+func findCardPrefix(cardTokens []string, isLang bool, cardGetFunction func(cardname string, isLang bool) (Card, error)) (Card, []string, error) {
 	var bestCardSoFar Card
+	var bestRestSoFar []string
 	for _, rc := range reduceCardSentence(cardTokens) {
-		card, err := cardGetFunction(rc, isLang)
+		card, err := cardGetFunction(rc.name, isLang)
 		log.Debug("Card Func gave us", "CardID", card.ID, "Err", err)
 		if err == nil {
-			log.Debug("Found card!", "Token", rc, "CardID", card.ID)
+			log.Debug("Found card!", "Token", rc.name, "CardID", card.ID)
 			if card.Lang == "en" && !isLang {
-				return card, nil
+				return card, cardTokens[rc.tokens:], nil
 			}
 			// Initialise with the longest/best card
 			if bestCardSoFar.ID == "" {
 				bestCardSoFar = card
+				bestRestSoFar = cardTokens[rc.tokens:]
 			}
 		}
 	}
 	if bestCardSoFar.ID != "" {
-		return bestCardSoFar, nil
+		return bestCardSoFar, bestRestSoFar, nil
 	}
-	return Card{}, fmt.Errorf("Card not found")
+	return Card{}, nil, fmt.Errorf("Card not found")
 }
 
-func getRandomCard(cardTokens []string, randomCardGetFunction RandomCardGetter) (Card, error) {
-	card, err := randomCardGetFunction(cardTokens)
+func getRandomCard(cardTokens []string, source CardSource) (Card, error) {
+	card, err := source.Random(cardTokens)
 	if err == nil {
 		log.Debug("Found card!", "CardID", card.ID)
 		return card, nil
@@ -652,6 +869,23 @@ func main() {
 		raven.CaptureErrorAndWait(err, nil)
 	}
 
+	// The sets, so that "!card SET 123" is only a printing when it's a real set
+	if err := importSets(false); err != nil {
+		log.Warn("Error fetching sets", "Err", err)
+		raven.CaptureErrorAndWait(err, nil)
+	}
+
+	// Initialise the local card index
+	ci, err := importBulkData(false)
+	if err != nil {
+		log.Warn("Error importing bulk data", "Err", err)
+		raven.CaptureErrorAndWait(err, nil)
+	}
+	cardIndex.Store(ci)
+
+	// Look for banned and restricted list changes since we last ran, once we're connected to announce them
+	legalities = newLegalityMonitor(legalitySnapshotFile, legalityHistoryFile)
+
 	// Initialise Cardname cache
 	nameToCardCache, err = lru.NewARC(50)
 	if err != nil {
@@ -660,19 +894,52 @@ func main() {
 		panic(err)
 	}
 
-	// Initialise HL points
-	err = importHighlanderPoints(true)
+	// Initialise Highlander points lists
+	configurePointsLists(&conf)
+	err = importPointsLists(true)
 	if err != nil {
 		log.Warn("Error importing Highlander points", "Err", err)
 		raven.CaptureErrorAndWait(err, nil)
 	}
 
+	// Initialise foreign names, which is a big download the first time so it doesn't hold up starting
+	go func() {
+		if err := importForeignNames(false); err != nil {
+			log.Warn("Error importing foreign names", "Err", err)
+		}
+	}()
+
+	// Initialise the rulings, which every printing of a card shares
+	go func() {
+		if err := importRulings(false); err != nil {
+			log.Warn("Error importing rulings", "Err", err)
+		}
+	}()
+
 	// Initialise Short Names
 	if importShortCardNames() != nil {
 		log.Warn("Error importing short card names", "Err", err)
 		raven.CaptureErrorAndWait(err, nil)
 	}
 
+	// Initialise booster layouts
+	if err := importBoosterSlots(); err != nil {
+		log.Warn("Error importing booster slots", "Err", err)
+		raven.CaptureErrorAndWait(err, nil)
+	}
+
+	// Initialise the cards whose rulings Gatherer has in its own order
+	if err := importRulingOrders(); err != nil {
+		log.Warn("Error importing ruling orders", "Err", err)
+		raven.CaptureErrorAndWait(err, nil)
+	}
+
+	// Initialise the old printed wordings for !errata
+	if err := importPrintedWordings(); err != nil {
+		log.Warn("Error importing printed wordings", "Err", err)
+		raven.CaptureErrorAndWait(err, nil)
+	}
+
 	ctx = context.Background()
 
 	hijackSession := func(bot *hbot.Bot) {
@@ -793,9 +1060,48 @@ func main() {
 	bot.AddTrigger(endOfWhoTrigger)
 	bot.AddTrigger(greetingTrigger)
 	bot.AddTrigger(joinTrigger)
+	bot.AddTrigger(legalityTrigger)
 	bot.Logger.SetHandler(log.StdoutHandler)
 
 	go dumpCardCacheTimer(&conf, nameToCardCache)
+	postScheduler, err = newScheduler(conf.Schedules, cardSource, postToChannel)
+	if err != nil {
+		log.Warn("Error setting up schedules", "Err", err)
+		raven.CaptureErrorAndWait(err, nil)
+	} else {
+		go runScheduler(postScheduler)
+	}
+	// Slack is posted to over HTTP, so it's ready now, but IRC has to wait to join the channels
+	if !(conf.IRC && announcesLegalitiesOnIRC()) {
+		startupLegalityCheck.Do(func() { go checkLegalities(cardIndex.Load()) })
+	}
+	go refreshBulkDataTimer()
+	go refreshForeignNamesTimer()
+	go refreshRulingsTimer()
+	for _, pl := range pointsLists {
+		if pl.url != "" {
+			go refreshPointsListTimer(pl)
+		}
+	}
+
+	if ss, ok := cardSource.(*scryfallSource); ok {
+		spoilers = newSpoilerWatcher(ss, spoilersFile, postToChannel)
+		if err := spoilers.load(); err != nil {
+			log.Warn("Error loading spoiler subscriptions", "Err", err)
+		}
+		interval := defaultSpoilerInterval
+		if conf.Spoilers.IntervalMinutes > 0 {
+			interval = time.Duration(conf.Spoilers.IntervalMinutes) * time.Minute
+		}
+		go func() {
+			for channel, sets := range conf.Spoilers.Channels {
+				for _, set := range sets {
+					spoilers.subscribe(channel, set)
+				}
+			}
+			runSpoilerWatcher(spoilers, interval)
+		}()
+	}
 
 	// Start metrics server
 	go func() {
@@ -850,8 +1156,14 @@ var mainTrigger = hbot.Trigger{
 		if m.From == whichNick {
 			log.Debug("Ignoring message from myself", "Input", m.Content)
 		}
-		toPrint := tokeniseAndDispatchInput(&fryatogParams{m: m}, getScryfallCard, getDumbScryfallCard, getRandomScryfallCard, searchScryfallCard)
+		toPrint := tokeniseAndDispatchInput(&fryatogParams{m: m}, cardSource)
 		for _, s := range sliceUniqMap(toPrint) {
+			s, overflow := splitOverflow(s)
+			for _, o := range strings.Split(overflow, "\n") {
+				if o != "" {
+					irc.Msg(m.From, o)
+				}
+			}
 			var prefix string
 			isPublic := strings.Contains(m.To, "#")
 			// If it's not a PM, address them.
@@ -946,3 +1258,14 @@ var joinTrigger = hbot.Trigger{
 		return false
 	},
 }
+
+// legalityTrigger runs the startup legality check once we've joined a channel that hears about legality changes
+var legalityTrigger = hbot.Trigger{
+	Condition: func(bot *hbot.Bot, m *hbot.Message) bool {
+		return m.Command == "JOIN" && m.From == bot.Nick && stringSliceContains(conf.LegalityChannels, m.To)
+	},
+	Action: func(irc *hbot.Bot, m *hbot.Message) bool {
+		startupLegalityCheck.Do(func() { go checkLegalities(cardIndex.Load()) })
+		return false
+	},
+}
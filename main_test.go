@@ -53,6 +53,57 @@ func fakeFindCards(tokens []string) ([]Card, error) {
 	return []Card{card1, card2}, nil
 }
 
+// fakeCardSource is a CardSource that never leaves the test process.
+type fakeCardSource struct{}
+
+func (fakeCardSource) Named(cardname string, isLang bool) (Card, error) {
+	return fakeGetCard(cardname, isLang)
+}
+
+func (fakeCardSource) Fuzzy(cardname string, isLang bool) (Card, error) {
+	return fakeGetCard(cardname, isLang)
+}
+
+func (fakeCardSource) Dumb(cardname string, isLang bool) (Card, error) {
+	return fakeGetCard(cardname, isLang)
+}
+
+func (fakeCardSource) Random(tokens []string) (Card, error) {
+	return fakeGetRandomCard(tokens)
+}
+
+func (fakeCardSource) Search(tokens []string) ([]Card, error) {
+	return fakeFindCards(tokens)
+}
+
+func (fakeCardSource) Rulings(card *Card) ([]CardRuling, error) {
+	return nil, fmt.Errorf("No rulings for %s", card.Name)
+}
+
+func (fakeCardSource) Prints(card *Card, multilingual bool, allPages bool) ([]Card, error) {
+	return nil, fmt.Errorf("No printings for %s", card.Name)
+}
+
+func (fakeCardSource) Language(card *Card, lang string) (Card, error) {
+	return Card{}, fmt.Errorf("No %s printing for %s", lang, card.Name)
+}
+
+func (fakeCardSource) Printing(cardname string, set string, number string) (Card, error) {
+	return Card{}, fmt.Errorf("No printing of %s in %s", cardname, set)
+}
+
+func (fakeCardSource) Related(part RelatedCard) (Card, error) {
+	return Card{}, fmt.Errorf("%s not found", part.Name)
+}
+
+func (fakeCardSource) Tokens(name string, pt string) ([]Card, error) {
+	return nil, fmt.Errorf("No tokens named %s", name)
+}
+
+func (fakeCardSource) SetCards(set string) ([]Card, error) {
+	return nil, fmt.Errorf("No cards found in %s", strings.ToUpper(set))
+}
+
 func fakeFindRealCard(tokens []string) ([]string, error) {
 	var csr CardSearchResult
 	var ret []string
@@ -113,6 +164,8 @@ func TestTokens(t *testing.T) {
 	var testCardExpected = "\x02CARD\x0F ·  · · TESTSET-T · "
 	var testRandomCardExpected = "\x02RANDOMCARD\x0F ·  · · RANDOMTESTSET-R · "
 	var tarmogoyfRulesText = "\x02Tarmogoyf\x0f {1}{G} · Creature — Lhurgoyf · */1+* · Tarmogoyf's power is equal to the number of card types among cards in all graveyards and its toughness is equal to that number plus 1. · UMA-M · Vin,Cmr,Leg,Mod"
+	setKnownSets([]CardSet{{Code: "lea", Name: "Limited Edition Alpha"}})
+	defer setKnownSets(nil)
 	tables := []struct {
 		input  string
 		output []string
@@ -160,9 +213,14 @@ func TestTokens(t *testing.T) {
 		{"Hello! Me & John & Tim are playing a game...", emptyStringSlice},
 		{"Hello! I control !island & swamp ..", []string{testCardExpected, testCardExpected}},
 		{"Hello! I saw [[Tarmogoyf]] & I was wondering...", []string{tarmogoyfRulesText}},
+		{"Is [[Disenchant|LEA|18]] any different?", []string{"No printing found at LEA #18"}},
+		{"[[Disenchant|LEA]]", []string{"No printing of Disenchant found in LEA"}},
+		{"!card LEA 18", []string{"No printing found at LEA #18"}},
+		{"!card Fire Ice", []string{testCardExpected}},
+		{"!card Opt 2", []string{testCardExpected}},
 	}
 	for _, table := range tables {
-		got := tokeniseAndDispatchInput(&fryatogParams{m: &hbot.Message{Content: table.input}}, fakeGetCard, fakeGetCard, fakeGetRandomCard, fakeFindCards)
+		got := tokeniseAndDispatchInput(&fryatogParams{m: &hbot.Message{Content: table.input}}, fakeCardSource{})
 		sort.Strings(got)
 		sort.Strings(table.output)
 		if !reflect.DeepEqual(got, table.output) {
@@ -179,7 +237,7 @@ func TestLanguageRecursion(t *testing.T) {
 		{"Erebos' Titan", []string{"\x02Erebos's Titan\x0f {1}{B}{B}{B} · Creature — Giant · 5/5 · As long as your opponents control no creatures, Erebos's Titan has indestructible. \x1d(Damage and effects that say \"destroy\" don't destroy it.)\x0f \\ Whenever a creature card leaves an opponent's graveyard, you may discard a card. If you do, return Erebos's Titan from your graveyard to your hand. · ORI-M · Vin,Cmr,Leg,Mod,Pio"}},
 	}
 	for _, table := range tables {
-		got := tokeniseAndDispatchInput(&fryatogParams{m: &hbot.Message{Content: table.input}}, fakeGetCard, fakeGetCard, fakeGetRandomCard, fakeFindCards)
+		got := tokeniseAndDispatchInput(&fryatogParams{m: &hbot.Message{Content: table.input}}, fakeCardSource{})
 		if !reflect.DeepEqual(got, table.output) {
 			t.Errorf("Incorrect output for [%v] -- got %q -- want %q", table.input, got, table.output)
 		}
@@ -196,6 +254,8 @@ func TestRegex(t *testing.T) {
 		{"Player != Planeswalker", false, []string{}},
 		{"<MW> !!fract ident &treas nabb", true, []string{"!fract ident ", "&treas nabb"}},
 		{"!Fork. it creates", true, []string{"!Fork. "}},
+		{"!2", true, []string{"!2"}},
+		{"I won 2!1", false, []string{}},
 	}
 	for _, table := range tables {
 		got := botCommandRegex.FindAllString(table.input, -1)
@@ -215,7 +275,8 @@ func TestCardMetadata(t *testing.T) {
 		output  string
 	}{
 		{"ruling", "ruling TestCardWithOneWOTCRuling 1", "1900-01-01: Print Me"},
-		{"ruling", "ruling TestCardWithOneWOTCRuling", "1900-01-01: Print Me"},
+		{"ruling", "ruling TestCardWithOneWOTCRuling", "[1] 1900-01-01: Print Me"},
+		{"ruling", "ruling TestCardWithTwoWOTCRulings page 2", "Page 2 not found, there are 1"},
 		{"ruling", "ruling TestCardWithOneNonWOTCRuling 1", "Ruling not found"},
 		{"ruling", "ruling TestCardWithOneNonWOTCRuling", "Ruling not found"},
 		{"reminder", "reminder Ponder", "Reminder text not found"},
@@ -226,7 +287,7 @@ func TestCardMetadata(t *testing.T) {
 		{"flavor", "flavor Bushi Tenderfoot", "Flavour text not found"},
 	}
 	for _, table := range tables {
-		got := handleCardMetadataQuery(&fryatogParams{message: table.message, cardGetFunction: fakeGetCard}, table.command)
+		got := handleCardMetadataQuery(&fryatogParams{message: table.message, source: fakeCardSource{}}, table.command)
 		if got != table.output {
 			t.Errorf("Incorrect output -- got %s - want %s", got, table.output)
 		}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+)
+
+// How many cards Jhoira offers to choose from
+const jhoiraChoices = 3
+
+// How many times to roll for each of Jhoira's choices before we give up on them all being different
+const jhoiraRollsPerChoice = 3
+
+// A game that's gone quiet for this long is over
+const mojhostoSessionTTL = 2 * time.Hour
+
+// The avatars, as they're shown
+const (
+	momirAvatar      = "Momir"
+	jhoiraAvatar     = "Jhoira"
+	stonehewerAvatar = "Stonehewer"
+)
+
+// What Jhoira can copy
+var jhoiraTypes = map[string]string{
+	"instant":   "type:instant",
+	"instants":  "type:instant",
+	"sorcery":   "type:sorcery",
+	"sorceries": "type:sorcery",
+}
+
+// mojhostoSession is one player's game, and which avatars they've used this turn
+type mojhostoSession struct {
+	turn int
+	used map[string]int
+}
+
+var (
+	mojhostoSessions     = cache.New(mojhostoSessionTTL, 10*time.Minute)
+	mojhostoSessionsLock sync.Mutex
+)
+
+// mojhostoKey is who's playing, and where, since the same nick might be playing in two channels
+func (params *fryatogParams) mojhostoKey() string {
+	return params.channel + "|" + params.user
+}
+
+// useAvatar records an activation if there's a game going, and says why not if it isn't allowed.
+// Momir can only be activated once each turn.
+func (params *fryatogParams) useAvatar(avatar string) error {
+	mojhostoSessionsLock.Lock()
+	defer mojhostoSessionsLock.Unlock()
+	cached, ok := mojhostoSessions.Get(params.mojhostoKey())
+	if !ok {
+		return nil
+	}
+	session := cached.(*mojhostoSession)
+	if avatar == momirAvatar && session.used[avatar] > 0 {
+		return fmt.Errorf("You've already activated %s this turn (!mojhosto turn for the next one)", avatar)
+	}
+	session.used[avatar]++
+	mojhostoSessions.Set(params.mojhostoKey(), session, cache.DefaultExpiration)
+	return nil
+}
+
+// unuseAvatar takes back an activation that didn't find a card
+func (params *fryatogParams) unuseAvatar(avatar string) {
+	mojhostoSessionsLock.Lock()
+	defer mojhostoSessionsLock.Unlock()
+	if cached, ok := mojhostoSessions.Get(params.mojhostoKey()); ok {
+		session := cached.(*mojhostoSession)
+		if session.used[avatar] > 0 {
+			session.used[avatar]--
+		}
+	}
+}
+
+func (s *mojhostoSession) String() string {
+	var used []string
+	for avatar, n := range s.used {
+		if n > 0 {
+			used = append(used, fmt.Sprintf("%s ×%d", avatar, n))
+		}
+	}
+	if len(used) == 0 {
+		return fmt.Sprintf("Turn %d · Nothing used yet", s.turn)
+	}
+	sort.Strings(used)
+	return fmt.Sprintf("Turn %d · Used: %s", s.turn, strings.Join(used, ", "))
+}
+
+// handleMojhostoQuery deals with !mojhosto [status|start|turn|end]
+func handleMojhostoQuery(params *fryatogParams, tokens []string) string {
+	momirRequests.Add(1)
+	// Just !mojhosto shows how the game's going, so nobody loses one by asking
+	action := "status"
+	if len(tokens) > 0 {
+		action = strings.ToLower(tokens[0])
+	}
+	mojhostoSessionsLock.Lock()
+	defer mojhostoSessionsLock.Unlock()
+	key := params.mojhostoKey()
+	cached, ok := mojhostoSessions.Get(key)
+	switch action {
+	case "start", "new":
+		mojhostoSessions.Set(key, &mojhostoSession{turn: 1, used: make(map[string]int)}, cache.DefaultExpiration)
+		return "MoJhoSto started, turn 1 · !momir <mv>, !jhoira instant/sorcery and !stonehewer <mv> are tracked each turn · !mojhosto turn for the next turn, !mojhosto end to stop"
+	case "turn", "next":
+		if !ok {
+			return "No MoJhoSto game going, !mojhosto start to begin one"
+		}
+		session := cached.(*mojhostoSession)
+		session.turn++
+		session.used = make(map[string]int)
+		mojhostoSessions.Set(key, session, cache.DefaultExpiration)
+		return fmt.Sprintf("Turn %d", session.turn)
+	case "end", "stop":
+		if !ok {
+			return "No MoJhoSto game going"
+		}
+		mojhostoSessions.Delete(key)
+		return fmt.Sprintf("MoJhoSto over after %d turns", cached.(*mojhostoSession).turn)
+	case "status":
+		if !ok {
+			return "No MoJhoSto game going, !mojhosto start to begin one"
+		}
+		return cached.(*mojhostoSession).String()
+	}
+	return "!mojhosto start, turn, status or end"
+}
+
+// handleMomirQuery makes a random creature with the given mana value
+func handleMomirQuery(params *fryatogParams, mv string) string {
+	momirRequests.Add(1)
+	if _, err := strconv.Atoi(mv); err != nil {
+		return ""
+	}
+	if err := params.useAvatar(momirAvatar); err != nil {
+		return err.Error()
+	}
+	card, err := getRandomCard([]string{"type:creature", "mv=" + mv}, params.source)
+	if err != nil {
+		params.unuseAvatar(momirAvatar)
+		return ""
+	}
+	return params.formatCardFace(&card, -1)
+}
+
+// handleStonehewerQuery makes a random Equipment with mana value at most the given one
+func handleStonehewerQuery(params *fryatogParams, mv string) string {
+	momirRequests.Add(1)
+	if _, err := strconv.Atoi(mv); err != nil {
+		return "!stonehewer <mana value>"
+	}
+	if err := params.useAvatar(stonehewerAvatar); err != nil {
+		return err.Error()
+	}
+	card, err := getRandomCard([]string{"type:equipment", "mv<=" + mv}, params.source)
+	if err != nil {
+		params.unuseAvatar(stonehewerAvatar)
+		return fmt.Sprintf("No Equipment found with mana value %s or less", mv)
+	}
+	return params.formatCardFace(&card, -1)
+}
+
+// handleJhoiraQuery offers three different random instants or sorceries
+func handleJhoiraQuery(params *fryatogParams, kind string) string {
+	momirRequests.Add(1)
+	query, ok := jhoiraTypes[strings.ToLower(kind)]
+	if !ok {
+		return "!jhoira instant or !jhoira sorcery"
+	}
+	if err := params.useAvatar(jhoiraAvatar); err != nil {
+		return err.Error()
+	}
+	var choices []Card
+	seen := make(map[string]bool)
+	for i := 0; i < jhoiraChoices*jhoiraRollsPerChoice && len(choices) < jhoiraChoices; i++ {
+		card, err := getRandomCard([]string{query}, params.source)
+		if err != nil || seen[card.Name] {
+			continue
+		}
+		seen[card.Name] = true
+		choices = append(choices, card)
+	}
+	if len(choices) == 0 {
+		params.unuseAvatar(jhoiraAvatar)
+		return fmt.Sprintf("No %s cards found", strings.TrimPrefix(query, "type:"))
+	}
+	var ret []string
+	for i := range choices {
+		ret = append(ret, params.formatCardFace(&choices[i], -1))
+	}
+	return strings.Join(ret, "\n")
+}
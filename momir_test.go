@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// cyclingRandomSource hands out its cards in turn as random cards, and remembers what it was asked for
+type cyclingRandomSource struct {
+	fakeCardSource
+	cards   []string
+	next    int
+	queries []string
+}
+
+func (s *cyclingRandomSource) Random(tokens []string) (Card, error) {
+	s.queries = append(s.queries, strings.Join(tokens, " "))
+	if len(s.cards) == 0 {
+		return Card{}, fmt.Errorf("No cards")
+	}
+	name := s.cards[s.next%len(s.cards)]
+	s.next++
+	return Card{Name: name, Set: "tst", Rarity: "common"}, nil
+}
+
+func TestJhoira(t *testing.T) {
+	source := &cyclingRandomSource{cards: []string{"Opt", "Opt", "Shock", "Duress", "Ponder"}}
+	params := &fryatogParams{isIRC: true, source: source}
+	got := handleJhoiraQuery(params, "instant")
+	want := "\x02Opt\x0F ·  · · TST-C · \n\x02Shock\x0F ·  · · TST-C · \n\x02Duress\x0F ·  · · TST-C · "
+	if got != want {
+		t.Errorf("Incorrect Jhoira choices -- got %q -- want %q", got, want)
+	}
+	if source.queries[0] != "type:instant" || len(source.queries) != 4 {
+		t.Errorf("Incorrect queries -- got %v", source.queries)
+	}
+	if got := handleJhoiraQuery(params, "creature"); got != "!jhoira instant or !jhoira sorcery" {
+		t.Errorf("Incorrect output for a creature -- got %q", got)
+	}
+	if got := handleJhoiraQuery(&fryatogParams{isIRC: true, source: &cyclingRandomSource{}}, "sorcery"); got != "No sorcery cards found" {
+		t.Errorf("Incorrect output with no cards -- got %q", got)
+	}
+}
+
+func TestStonehewer(t *testing.T) {
+	source := &cyclingRandomSource{cards: []string{"Bonesplitter"}}
+	params := &fryatogParams{isIRC: true, source: source}
+	if got := handleStonehewerQuery(params, "3"); !strings.HasPrefix(got, "\x02Bonesplitter\x0F") {
+		t.Errorf("Incorrect Stonehewer card -- got %q", got)
+	}
+	if source.queries[0] != "type:equipment mv<=3" {
+		t.Errorf("Incorrect query -- got %v", source.queries)
+	}
+	if got := handleStonehewerQuery(params, "x"); got != "!stonehewer <mana value>" {
+		t.Errorf("Incorrect output for a bad mana value -- got %q", got)
+	}
+}
+
+func TestMojhostoSession(t *testing.T) {
+	source := &cyclingRandomSource{cards: []string{"Grizzly Bears", "Opt", "Shock", "Duress", "Bonesplitter"}}
+	params := &fryatogParams{isIRC: true, source: source, channel: "#mojhosto", user: "fry"}
+	other := &fryatogParams{isIRC: true, source: source, channel: "#mojhosto", user: "volo"}
+	defer mojhostoSessions.Flush()
+
+	steps := []struct {
+		params *fryatogParams
+		run    func(*fryatogParams) string
+		want   string
+	}{
+		{params, func(p *fryatogParams) string { return handleMojhostoQuery(p, []string{"status"}) }, "No MoJhoSto game going, !mojhosto start to begin one"},
+		{params, func(p *fryatogParams) string { return handleMojhostoQuery(p, nil) }, "No MoJhoSto game going, !mojhosto start to begin one"},
+		{params, func(p *fryatogParams) string { return handleMojhostoQuery(p, []string{"start"}) }, "MoJhoSto started, turn 1 · !momir <mv>, !jhoira instant/sorcery and !stonehewer <mv> are tracked each turn · !mojhosto turn for the next turn, !mojhosto end to stop"},
+		{params, func(p *fryatogParams) string { return handleMomirQuery(p, "2") }, "\x02Grizzly Bears\x0F ·  · · TST-C · "},
+		{params, func(p *fryatogParams) string { return handleMomirQuery(p, "2") }, "You've already activated Momir this turn (!mojhosto turn for the next one)"},
+		{other, func(p *fryatogParams) string { return handleMomirQuery(p, "2") }, "\x02Opt\x0F ·  · · TST-C · "},
+		{params, func(p *fryatogParams) string { return handleMojhostoQuery(p, []string{"status"}) }, "Turn 1 · Used: Momir ×1"},
+		// Asking again doesn't start the game over
+		{params, func(p *fryatogParams) string { return handleMojhostoQuery(p, nil) }, "Turn 1 · Used: Momir ×1"},
+		{params, func(p *fryatogParams) string { return handleMojhostoQuery(p, []string{"turn"}) }, "Turn 2"},
+		{params, func(p *fryatogParams) string { return handleMomirQuery(p, "1") }, "\x02Shock\x0F ·  · · TST-C · "},
+		{params, func(p *fryatogParams) string { return handleStonehewerQuery(p, "1") }, "\x02Duress\x0F ·  · · TST-C · "},
+		{params, func(p *fryatogParams) string { return handleMojhostoQuery(p, []string{"status"}) }, "Turn 2 · Used: Momir ×1, Stonehewer ×1"},
+		{params, func(p *fryatogParams) string { return handleMojhostoQuery(p, []string{"end"}) }, "MoJhoSto over after 2 turns"},
+		{params, func(p *fryatogParams) string { return handleMomirQuery(p, "1") }, "\x02Bonesplitter\x0F ·  · · TST-C · "},
+	}
+	for i, step := range steps {
+		if got := step.run(step.params); got != step.want {
+			t.Errorf("Incorrect output at step %d -- got %q -- want %q", i, got, step.want)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	raven "github.com/getsentry/raven-go"
+	log "gopkg.in/inconshreveable/log15.v2"
+)
+
+const boosterSlotsFile = "booster_slots.json"
+
+// How many packs make a sealed pool
+const sealedPacks = 6
+
+// boosterSlot is one part of a pack, like its rare or its commons
+type boosterSlot struct {
+	Name     string   `json:"name"`
+	Count    int      `json:"count"`
+	Rarities []string `json:"rarities"`
+	// Only cards with this in their type line, or without it
+	TypeLine        string `json:"type_line,omitempty"`
+	ExcludeTypeLine string `json:"exclude_type_line,omitempty"`
+	// A rarity that sometimes takes the slot's place, like mythics in the rare slot.
+	// If there's no rate, it's how the print sheet works it out: each rare twice, each mythic once.
+	Upgrade     string  `json:"upgrade,omitempty"`
+	UpgradeRate float64 `json:"upgrade_rate,omitempty"`
+}
+
+// boosterLayout is what's in a pack
+type boosterLayout struct {
+	Slots []boosterSlot `json:"slots"`
+}
+
+// boosterSlots are the pack layouts, for a set if it has its own, otherwise for its type of set
+type boosterSlots struct {
+	Default  boosterLayout            `json:"default"`
+	SetTypes map[string]boosterLayout `json:"set_types"`
+	Sets     map[string]boosterLayout `json:"sets"`
+}
+
+var (
+	boosterLayouts boosterSlots
+
+	packRand     = rand.New(rand.NewSource(time.Now().UnixNano()))
+	packRandLock sync.Mutex
+)
+
+func importBoosterSlots() error {
+	log.Debug("In importBoosterSlots")
+	content, err := os.ReadFile(boosterSlotsFile)
+	if err != nil {
+		raven.CaptureError(err, nil)
+		log.Warn("Error opening booster slots file", "Error", err)
+		return err
+	}
+	var bs boosterSlots
+	if err := json.Unmarshal(content, &bs); err != nil {
+		raven.CaptureError(err, nil)
+		log.Warn("Unable to parse booster slots file", "Error", err)
+		return err
+	}
+	boosterLayouts = bs
+	log.Debug("Populated booster slots", "Sets", len(bs.Sets), "Set types", len(bs.SetTypes))
+	return nil
+}
+
+// layoutFor picks the layout for a set
+func (bs *boosterSlots) layoutFor(set string, setType string) boosterLayout {
+	if l, ok := bs.Sets[strings.ToLower(set)]; ok {
+		return l
+	}
+	if l, ok := bs.SetTypes[setType]; ok {
+		return l
+	}
+	return bs.Default
+}
+
+// boosterCards drops the cards that don't come in packs, if Scryfall has told us which those are
+func boosterCards(corpus []Card) []Card {
+	var ret []Card
+	for _, c := range corpus {
+		if c.Booster {
+			ret = append(ret, c)
+		}
+	}
+	if len(ret) == 0 {
+		return corpus
+	}
+	return ret
+}
+
+// candidates are the cards that can go in the slot, at one of the given rarities
+func (slot *boosterSlot) candidates(corpus []Card, rarities []string) []Card {
+	var ret []Card
+	for _, c := range corpus {
+		if !stringSliceContains(rarities, c.Rarity) {
+			continue
+		}
+		if slot.TypeLine != "" && !strings.Contains(c.TypeLine, slot.TypeLine) {
+			continue
+		}
+		if slot.ExcludeTypeLine != "" && strings.Contains(c.TypeLine, slot.ExcludeTypeLine) {
+			continue
+		}
+		ret = append(ret, c)
+	}
+	return ret
+}
+
+// upgradeRate is how often the slot's upgrade turns up instead
+func (slot *boosterSlot) upgradeRate(base int, upgraded int) float64 {
+	switch {
+	case slot.UpgradeRate > 0:
+		return slot.UpgradeRate
+	case base == 0:
+		return 1
+	}
+	return float64(upgraded) / float64(2*base+upgraded)
+}
+
+// pickCard picks a random card that isn't already in the pack
+func pickCard(pool []Card, inPack map[string]bool, rng *rand.Rand) (Card, bool) {
+	if len(pool) == 0 {
+		return Card{}, false
+	}
+	start := rng.Intn(len(pool))
+	for i := range pool {
+		c := pool[(start+i)%len(pool)]
+		if !inPack[c.ID] {
+			return c, true
+		}
+	}
+	return Card{}, false
+}
+
+// packCard is a card in a pack, and the slot it came in
+type packCard struct {
+	slot string
+	card Card
+}
+
+// label is what the card is shown as, which is the slot unless it's been upgraded
+func (pc packCard) label(layout boosterLayout) string {
+	for _, slot := range layout.Slots {
+		if slot.Name == pc.slot && slot.Upgrade != "" && pc.card.Rarity == slot.Upgrade {
+			return strings.ToUpper(slot.Upgrade[:1]) + slot.Upgrade[1:]
+		}
+	}
+	return pc.slot
+}
+
+// openPack makes a pack out of a set's cards. Slots with nothing to fill them are left out.
+func openPack(corpus []Card, layout boosterLayout, rng *rand.Rand) []packCard {
+	corpus = boosterCards(corpus)
+	var ret []packCard
+	inPack := make(map[string]bool)
+	for _, slot := range layout.Slots {
+		base := slot.candidates(corpus, slot.Rarities)
+		var upgraded []Card
+		var rate float64
+		if slot.Upgrade != "" {
+			upgraded = slot.candidates(corpus, []string{slot.Upgrade})
+			rate = slot.upgradeRate(len(base), len(upgraded))
+		}
+		for i := 0; i < slot.Count; i++ {
+			pool := base
+			if len(upgraded) > 0 && rng.Float64() < rate {
+				pool = upgraded
+			}
+			card, ok := pickCard(pool, inPack, rng)
+			if !ok {
+				break
+			}
+			inPack[card.ID] = true
+			ret = append(ret, packCard{slot: slot.Name, card: card})
+		}
+	}
+	return ret
+}
+
+// formatPack lists the pack a slot at a time, rares first
+func formatPack(setName string, pack []packCard, layout boosterLayout, isIRC bool) string {
+	var labels []string
+	names := make(map[string][]string)
+	for _, pc := range pack {
+		l := pc.label(layout)
+		if _, ok := names[l]; !ok {
+			labels = append(labels, l)
+		}
+		names[l] = append(names[l], pc.card.Name)
+	}
+	var ret []string
+	if isIRC {
+		ret = append(ret, fmt.Sprintf("\x02%s\x0F pack", setName))
+	} else {
+		ret = append(ret, fmt.Sprintf("*%s* pack", setName))
+	}
+	for _, l := range labels {
+		ret = append(ret, fmt.Sprintf("%s: %s", l, strings.Join(names[l], ", ")))
+	}
+	return strings.Join(ret, " · ")
+}
+
+// Which order a pool is listed in
+var rarityOrder = map[string]int{"mythic": 0, "rare": 1, "uncommon": 2, "common": 3}
+
+func rarityRank(rarity string) int {
+	if r, ok := rarityOrder[rarity]; ok {
+		return r
+	}
+	return len(rarityOrder)
+}
+
+// sealedPool is every card in a set of packs
+type sealedPool struct {
+	setName string
+	packs   int
+	cards   []Card
+	counts  map[string]int
+}
+
+func newSealedPool(setName string, packs [][]packCard) sealedPool {
+	sp := sealedPool{setName: setName, packs: len(packs), counts: make(map[string]int)}
+	for _, pack := range packs {
+		for _, pc := range pack {
+			if sp.counts[pc.card.Name] == 0 {
+				sp.cards = append(sp.cards, pc.card)
+			}
+			sp.counts[pc.card.Name]++
+		}
+	}
+	sort.SliceStable(sp.cards, func(i, j int) bool {
+		if ri, rj := rarityRank(sp.cards[i].Rarity), rarityRank(sp.cards[j].Rarity); ri != rj {
+			return ri < rj
+		}
+		return sp.cards[i].Name < sp.cards[j].Name
+	})
+	return sp
+}
+
+func (sp sealedPool) total() int {
+	var ret int
+	for _, n := range sp.counts {
+		ret += n
+	}
+	return ret
+}
+
+// decklist is the pool written out like a decklist, so it can go straight into !deck or a client
+func (sp sealedPool) decklist() []string {
+	var ret []string
+	for _, c := range sp.cards {
+		ret = append(ret, fmt.Sprintf("%d %s", sp.counts[c.Name], c.Name))
+	}
+	return ret
+}
+
+// String shows the rares in the channel, and sends the whole pool separately
+func (sp sealedPool) String() string {
+	ret := []string{fmt.Sprintf("%s sealed pool", sp.setName), fmt.Sprintf("%d packs, %d cards", sp.packs, sp.total())}
+	var rares []string
+	for _, c := range sp.cards {
+		if rarityRank(c.Rarity) > rarityOrder["rare"] {
+			break
+		}
+		for i := 0; i < sp.counts[c.Name]; i++ {
+			rares = append(rares, c.Name)
+		}
+	}
+	if len(rares) > 0 {
+		ret = append(ret, "Rares: "+strings.Join(rares, ", "))
+	}
+	ret = append(ret, "the full pool is coming separately")
+	return strings.Join(ret, " · ") + overflowMarker + strings.Join(sp.decklist(), "\n")
+}
+
+// openPacks fetches a set's cards and opens some packs of it
+func openPacks(source CardSource, set string, n int) (string, boosterLayout, [][]packCard, error) {
+	if !setCodeRegex.MatchString(set) {
+		return "", boosterLayout{}, nil, fmt.Errorf("That doesn't look like a set code")
+	}
+	corpus, err := source.SetCards(strings.ToLower(set))
+	if err != nil {
+		return "", boosterLayout{}, nil, err
+	}
+	if len(corpus) == 0 {
+		return "", boosterLayout{}, nil, fmt.Errorf("No cards found in %s", strings.ToUpper(set))
+	}
+	layout := boosterLayouts.layoutFor(corpus[0].Set, corpus[0].SetType)
+	var packs [][]packCard
+	packRandLock.Lock()
+	defer packRandLock.Unlock()
+	for i := 0; i < n; i++ {
+		pack := openPack(corpus, layout, packRand)
+		if len(pack) == 0 {
+			return "", layout, nil, fmt.Errorf("Couldn't make a pack out of %s", strings.ToUpper(set))
+		}
+		packs = append(packs, pack)
+	}
+	return corpus[0].SetName, layout, packs, nil
+}
+
+// handlePackQuery deals with !pack <set>
+func handlePackQuery(params *fryatogParams, tokens []string) string {
+	packRequests.Add(1)
+	if len(tokens) != 1 {
+		return "!pack <set code>"
+	}
+	setName, layout, packs, err := openPacks(params.source, tokens[0], 1)
+	if err != nil {
+		return err.Error()
+	}
+	return formatPack(setName, packs[0], layout, params.isIRC)
+}
+
+// handleSealedQuery deals with !sealed <set>
+func handleSealedQuery(params *fryatogParams, tokens []string) string {
+	packRequests.Add(1)
+	if len(tokens) != 1 {
+		return "!sealed <set code>"
+	}
+	setName, _, packs, err := openPacks(params.source, tokens[0], sealedPacks)
+	if err != nil {
+		return err.Error()
+	}
+	return newSealedPool(setName, packs).String()
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLayoutFor(t *testing.T) {
+	if err := importBoosterSlots(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	tables := []struct {
+		set     string
+		setType string
+		slots   int
+		special string
+	}{
+		{"WAR", "expansion", 5, "Planeswalker"},
+		{"2xm", "masters", 4, "Foil"},
+		{"tst", "expansion", 4, "Land"},
+	}
+	for _, table := range tables {
+		layout := boosterLayouts.layoutFor(table.set, table.setType)
+		if len(layout.Slots) != table.slots {
+			t.Errorf("Incorrect layout for %s -- got %d slots -- want %d", table.set, len(layout.Slots), table.slots)
+			continue
+		}
+		var found bool
+		for _, slot := range layout.Slots {
+			found = found || slot.Name == table.special
+		}
+		if !found {
+			t.Errorf("Incorrect layout for %s -- no %s slot", table.set, table.special)
+		}
+	}
+}
+
+func TestOpenPack(t *testing.T) {
+	if err := importBoosterSlots(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	oldDir := setCardsDir
+	setCardsDir = t.TempDir()
+	defer func() { setCardsDir = oldDir }()
+	source := newFakeScryfall(t)
+	corpus, err := source.SetCards("tst")
+	if err != nil || len(corpus) != 23 {
+		t.Fatalf("Incorrect set cards -- got %d %v", len(corpus), err)
+	}
+	// They're saved, so there's no going back to Scryfall for them
+	saved, err := newScryfallSource("http://127.0.0.1:0").SetCards("TST")
+	if err != nil || len(saved) != 23 {
+		t.Fatalf("Incorrect saved set cards -- got %d %v", len(saved), err)
+	}
+	layout := boosterLayouts.layoutFor("tst", "expansion")
+
+	// A few packs, each from its own seed, are put together properly
+	for seed := int64(1); seed <= 5; seed++ {
+		pack := openPack(corpus, layout, rand.New(rand.NewSource(seed)))
+		if len(pack) != 15 {
+			t.Fatalf("Incorrect pack size -- got %d -- want 15", len(pack))
+		}
+		rarities := make(map[string]int)
+		seen := make(map[string]bool)
+		for _, pc := range pack {
+			if seen[pc.card.Name] {
+				t.Fatalf("%s is in the pack twice", pc.card.Name)
+			}
+			seen[pc.card.Name] = true
+			rarities[pc.card.Rarity]++
+			switch {
+			case pc.card.Name == "Victor Promo":
+				t.Fatalf("Got a card that isn't in boosters")
+			case pc.slot == "Common" && pc.card.Name == "Forest":
+				t.Fatalf("Got a basic land in a common slot")
+			case pc.slot == "Land" && pc.card.Name != "Forest":
+				t.Fatalf("Got %s in the land slot", pc.card.Name)
+			}
+		}
+		if rarities["rare"]+rarities["mythic"] != 1 || rarities["uncommon"] != 3 || rarities["common"] != 11 {
+			t.Fatalf("Incorrect rarities -- got %v", rarities)
+		}
+	}
+
+	// One mythic to three rares is one pack in seven
+	rng := rand.New(rand.NewSource(1))
+	var mythics int
+	const packs = 700
+	for i := 0; i < packs; i++ {
+		for _, pc := range openPack(corpus, layout, rng) {
+			if pc.card.Rarity == "mythic" {
+				mythics++
+			}
+		}
+	}
+	if mythics < packs/7*7/10 || mythics > packs/7*13/10 {
+		t.Errorf("Incorrect mythic rate -- got %d in %d packs", mythics, packs)
+	}
+
+	// The same seed opens the same pack
+	a := openPack(corpus, layout, rand.New(rand.NewSource(42)))
+	b := openPack(corpus, layout, rand.New(rand.NewSource(42)))
+	if formatPack("Test Set", a, layout, true) != formatPack("Test Set", b, layout, true) {
+		t.Errorf("Packs from the same seed differ")
+	}
+}
+
+func TestPackQuery(t *testing.T) {
+	if err := importBoosterSlots(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	oldDir := setCardsDir
+	setCardsDir = t.TempDir()
+	defer func() { setCardsDir = oldDir }()
+	packRand = rand.New(rand.NewSource(1))
+	params := &fryatogParams{isIRC: true, source: newFakeScryfall(t)}
+
+	got := handlePackQuery(params, []string{"TST"})
+	if !strings.HasPrefix(got, "\x02Test Set\x0F pack · ") || !strings.Contains(got, " · Land: Forest") {
+		t.Errorf("Incorrect pack -- got %q", got)
+	}
+	if got := handlePackQuery(params, []string{"not-a-set!"}); got != "That doesn't look like a set code" {
+		t.Errorf("Incorrect output for a bad set -- got %q", got)
+	}
+	if got := handlePackQuery(params, []string{"xyz"}); got != "No cards found in XYZ" {
+		t.Errorf("Incorrect output for a set Scryfall doesn't have -- got %q", got)
+	}
+	if got := handlePackQuery(&fryatogParams{isIRC: true, source: fakeCardSource{}}, []string{"xyz"}); got != "No cards found in XYZ" {
+		t.Errorf("Incorrect output for a missing set -- got %q", got)
+	}
+
+	got = handleSealedQuery(params, []string{"tst"})
+	public, pool := splitOverflow(got)
+	if !strings.HasPrefix(public, "Test Set sealed pool · 6 packs, 90 cards · Rares: ") {
+		t.Errorf("Incorrect sealed pool -- got %q", public)
+	}
+	var total int
+	for _, line := range strings.Split(pool, "\n") {
+		n, err := strconv.Atoi(strings.Fields(line)[0])
+		if err != nil {
+			t.Fatalf("Incorrect pool line %q", line)
+		}
+		total += n
+	}
+	if total != 90 {
+		t.Errorf("Incorrect pool size -- got %d -- want 90", total)
+	}
+	if entries := parseDecklist(strings.Split(pool, "\n")); len(entries) != len(strings.Split(pool, "\n")) {
+		t.Errorf("The pool doesn't read back as a decklist -- got %d entries", len(entries))
+	}
+}
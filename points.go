@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	raven "github.com/getsentry/raven-go"
+	log "gopkg.in/inconshreveable/log15.v2"
+)
+
+// Each list is saved to its own file, named by its key
+const pointsFileFormat = "points-%s.txt"
+
+// The list shown on card lines, unless a channel has asked for something else
+const defaultPointsListKey = "canadian"
+
+// How often to fetch a list again, unless the configuration says otherwise
+const defaultPointsRefresh = 24 * time.Hour
+
+// pointsList is a points-based Highlander format's list of what each card costs
+type pointsList struct {
+	key     string
+	short   string
+	long    string
+	url     string
+	cap     int // 0 if the format has no cap
+	refresh time.Duration
+
+	lock   sync.RWMutex
+	points map[string]int
+}
+
+// pointsListConfig is how a points list is set up in the configuration, by its key
+type pointsListConfig struct {
+	Name         string `json:"Name"`
+	URL          string `json:"URL"`
+	Cap          int    `json:"Cap"`
+	RefreshHours int    `json:"RefreshHours"`
+}
+
+// pointsLists are the lists we know about, in the order we list them.
+// Only the Canadian list has a source we know of, the others need a URL in the configuration.
+var pointsLists = []*pointsList{
+	{key: "canadian", short: "CHL", long: "Canadian Highlander", url: highlanderPointsURL, cap: 10, refresh: defaultPointsRefresh},
+	{key: "7point", short: "7PT", long: "Australian 7-Point", cap: 7, refresh: defaultPointsRefresh},
+	{key: "european", short: "EHL", long: "European Highlander", refresh: defaultPointsRefresh},
+}
+
+// Other things people call the lists
+var pointsListAliases = map[string]string{
+	"chl":        "canadian",
+	"canlander":  "canadian",
+	"highlander": "canadian",
+	"7pt":        "7point",
+	"7-point":    "7point",
+	"australian": "7point",
+	"aus":        "7point",
+	"ehl":        "european",
+	"euro":       "european",
+}
+
+// lookupPointsList works out which list was meant, by its key, short name, long name or alias
+func lookupPointsList(input string) (*pointsList, bool) {
+	input = strings.ToLower(strings.TrimSpace(input))
+	if alias, ok := pointsListAliases[input]; ok {
+		input = alias
+	}
+	for _, pl := range pointsLists {
+		if input == pl.key || input == strings.ToLower(pl.short) || input == strings.ToLower(pl.long) {
+			return pl, true
+		}
+	}
+	return nil, false
+}
+
+// defaultPointsList is the list shown wherever a channel hasn't picked one
+func defaultPointsList() *pointsList {
+	pl, _ := lookupPointsList(defaultPointsListKey)
+	return pl
+}
+
+// configurePointsLists applies the configuration to the known lists, and adds any new ones
+func configurePointsLists(conf *configuration) {
+	for key, plc := range conf.PointsLists {
+		pl, ok := lookupPointsList(key)
+		if !ok {
+			pl = &pointsList{key: strings.ToLower(key), short: strings.ToUpper(key), long: nco(plc.Name, key), refresh: defaultPointsRefresh}
+			pointsLists = append(pointsLists, pl)
+		}
+		if plc.Name != "" {
+			pl.long = plc.Name
+		}
+		if plc.URL != "" {
+			pl.url = plc.URL
+		}
+		if plc.Cap > 0 {
+			pl.cap = plc.Cap
+		}
+		if plc.RefreshHours > 0 {
+			pl.refresh = time.Duration(plc.RefreshHours) * time.Hour
+		}
+	}
+}
+
+// channelPointsList is the list a channel has picked, or the default
+func channelPointsList(channel string) *pointsList {
+	if key, ok := conf.ChannelPointsLists[channel]; ok {
+		if pl, ok := lookupPointsList(key); ok {
+			return pl
+		}
+	}
+	return defaultPointsList()
+}
+
+// pointsList is the list to show points from wherever this message came from
+func (params *fryatogParams) pointsList() *pointsList {
+	return channelPointsList(params.channel)
+}
+
+// cardPoints is how many points a card costs in the list, and whether it's on it at all
+func (pl *pointsList) cardPoints(cardName string) (int, bool) {
+	pl.lock.RLock()
+	defer pl.lock.RUnlock()
+	points, ok := pl.points[normaliseCardName(cardName)]
+	return points, ok
+}
+
+// setPoints replaces the whole list at once
+func (pl *pointsList) setPoints(points map[string]int) {
+	pl.lock.Lock()
+	defer pl.lock.Unlock()
+	pl.points = points
+}
+
+// formatPoints shows what a card costs in the list, or nothing if it's free
+func (pl *pointsList) formatPoints(cardName string, isIRC bool) string {
+	points, ok := pl.cardPoints(cardName)
+	if !ok {
+		return ""
+	}
+	if isIRC {
+		return fmt.Sprintf("· [%d %s points]", points, pl.short)
+	}
+	if pl.key != defaultPointsListKey {
+		return fmt.Sprintf("[:point_right: %d %s :point_left:]", points, pl.short)
+	}
+	return fmt.Sprintf("[:point_right: %d :point_left:]", points)
+}
+
+// withPoints adds the card's points to the end of a card line, if it has any
+func (pl *pointsList) withPoints(line string, cardName string, isIRC bool) string {
+	if p := pl.formatPoints(cardName, isIRC); p != "" {
+		return line + " " + p
+	}
+	return line
+}
+
+func (pl *pointsList) file() string {
+	return fmt.Sprintf(pointsFileFormat, pl.key)
+}
+
+func (pl *pointsList) fetch() error {
+	log.Debug("FetchPoints: Attempting to fetch", "List", pl.key, "URL", pl.url)
+	resp, err := upstream.Get(pl.url)
+	if err != nil {
+		raven.CaptureError(err, nil)
+		log.Warn("FetchPoints: The HTTP request failed", "List", pl.key, "Error", err)
+		return fmt.Errorf("Something went wrong fetching the points")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		log.Warn("FetchPoints: The site returned a non-200", "List", pl.key, "Status Code", resp.StatusCode)
+		return fmt.Errorf("Points file returned a non-200")
+	}
+	out, err := os.Create(pl.file())
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if _, err = io.Copy(out, resp.Body); err != nil {
+		raven.CaptureError(err, nil)
+		log.Warn("FetchPoints: Error writing to points file", "List", pl.key, "Error", err)
+		return err
+	}
+	return nil
+}
+
+// parsePoints reads a list of "Card Name N" lines
+func parsePoints(r io.Reader) (map[string]int, error) {
+	ret := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lineFields := strings.Fields(scanner.Text())
+		if len(lineFields) < 2 {
+			continue
+		}
+		cardName := normaliseCardName(strings.Join(lineFields[0:len(lineFields)-1], " "))
+		points, err := strconv.Atoi(lineFields[len(lineFields)-1])
+		if err != nil {
+			log.Warn("Unable to convert points line", "Error", err)
+			continue
+		}
+		if conf.DevMode {
+			log.Debug("ImportPoints", "Cardname", cardName, "Points", points)
+		}
+		ret[cardName] = points
+	}
+	return ret, scanner.Err()
+}
+
+func (pl *pointsList) importPoints(forceFetch bool) error {
+	log.Debug("In importPoints", "List", pl.key, "Forced?", forceFetch)
+	if pl.url == "" {
+		log.Debug("No source for points list", "List", pl.key)
+		return nil
+	}
+	if _, err := os.Stat(pl.file()); forceFetch || err != nil {
+		if err := pl.fetch(); err != nil {
+			raven.CaptureError(err, nil)
+			log.Warn("Error fetching points", "List", pl.key, "Error", err)
+			return err
+		}
+	}
+	f, err := os.Open(pl.file())
+	if err != nil {
+		raven.CaptureError(err, nil)
+		log.Warn("Error opening points file", "List", pl.key, "Error", err)
+		return err
+	}
+	defer f.Close()
+	points, err := parsePoints(f)
+	if err != nil {
+		raven.CaptureError(err, nil)
+		log.Warn("Error reading points file", "List", pl.key, "Error", err)
+		return err
+	}
+	pl.setPoints(points)
+	return nil
+}
+
+// importPointsLists loads every list that has a source, and says whether any went wrong
+func importPointsLists(forceFetch bool) error {
+	var ret error
+	for _, pl := range pointsLists {
+		if err := pl.importPoints(forceFetch); err != nil {
+			ret = err
+		}
+	}
+	return ret
+}
+
+func refreshPointsListTimer(pl *pointsList) {
+	for {
+		time.Sleep(pl.refresh)
+		log.Debug("Refreshing points list", "List", pl.key)
+		if err := pl.importPoints(true); err != nil {
+			log.Warn("Refresh points list timer", "List", pl.key, "Error", err)
+		}
+	}
+}
+
+// pointsTotal is a list of cards added up
+type pointsTotal struct {
+	list     *pointsList
+	cards    []string
+	points   []int
+	notFound []string
+}
+
+func (pt pointsTotal) total() int {
+	var ret int
+	for _, p := range pt.points {
+		ret += p
+	}
+	return ret
+}
+
+func (pt pointsTotal) String() string {
+	ret := []string{pt.list.long}
+	for i, name := range pt.cards {
+		ret = append(ret, fmt.Sprintf("%s %d", name, pt.points[i]))
+	}
+	total := pt.total()
+	switch {
+	case pt.list.cap > 0 && total > pt.list.cap:
+		ret = append(ret, fmt.Sprintf("Total %d/%d, over the cap by %d", total, pt.list.cap, total-pt.list.cap))
+	case pt.list.cap > 0:
+		ret = append(ret, fmt.Sprintf("Total %d/%d", total, pt.list.cap))
+	default:
+		ret = append(ret, fmt.Sprintf("Total %d", total))
+	}
+	if len(pt.notFound) > 0 {
+		ret = append(ret, "Not found: "+strings.Join(pt.notFound, ", "))
+	}
+	return strings.Join(ret, " · ")
+}
+
+// totalPoints looks up each card and adds up what they cost in the list
+func totalPoints(source CardSource, pl *pointsList, names []string) pointsTotal {
+	pt := pointsTotal{list: pl}
+	for _, name := range names {
+		card, err := findCard(strings.Fields(name), false, source.Named)
+		if err != nil {
+			pt.notFound = append(pt.notFound, name)
+			continue
+		}
+		points, _ := pl.cardPoints(card.Name)
+		pt.cards = append(pt.cards, card.Name)
+		pt.points = append(pt.points, points)
+	}
+	return pt
+}
+
+// splitCardList breaks a comma separated list into card names.
+// Names have commas in too, so like splitCommanderCheck, a run of pieces that's a card's name is kept together.
+func splitCardList(source CardSource, input string) []string {
+	var pieces []string
+	for _, piece := range strings.Split(input, ",") {
+		if piece = strings.TrimSpace(piece); piece != "" {
+			pieces = append(pieces, piece)
+		}
+	}
+	var names []string
+	for i := 0; i < len(pieces); i++ {
+		name := pieces[i]
+		// No card's name has more than two commas, so there's no need to look further than three pieces
+		for j := i + 1; j <= min(i+3, len(pieces)); j++ {
+			candidate := strings.Join(pieces[i:j], ", ")
+			card, err := findCard(strings.Fields(candidate), false, source.Named)
+			if err == nil && card.isNamed(candidate) {
+				name = candidate
+				i = j - 1
+				break
+			}
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// handlePointsQuery deals with !points [list] <card, card, ...>
+func handlePointsQuery(params *fryatogParams, tokens []string) string {
+	pointsRequests.Add(1)
+	pl := params.pointsList()
+	if len(tokens) > 1 {
+		if named, ok := lookupPointsList(tokens[0]); ok {
+			pl = named
+			tokens = tokens[1:]
+		}
+	}
+	names := splitCardList(params.source, strings.Join(tokens, " "))
+	if len(names) == 0 {
+		return "!points [list] <card, card, ...>"
+	}
+	if pl.url == "" {
+		return fmt.Sprintf("There's no source for the %s points list", pl.long)
+	}
+	return totalPoints(params.source, pl, names).String()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+func TestParsePoints(t *testing.T) {
+	input := "Ancestral Recall 7\nTime Walk 6\n\nSol Ring 4\nBroken line x\n"
+	want := map[string]int{
+		normaliseCardName("Ancestral Recall"): 7,
+		normaliseCardName("Time Walk"):        6,
+		normaliseCardName("Sol Ring"):         4,
+	}
+	got, err := parsePoints(strings.NewReader(input))
+	if err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Incorrect points -- got %v -- want %v", got, want)
+	}
+}
+
+func TestLookupPointsList(t *testing.T) {
+	tables := []struct {
+		input string
+		want  string
+	}{
+		{"canadian", "canadian"},
+		{"CHL", "canadian"},
+		{"canlander", "canadian"},
+		{"Australian 7-Point", "7point"},
+		{"7pt", "7point"},
+		{"euro", "european"},
+		{"legacy", ""},
+	}
+	for _, table := range tables {
+		pl, ok := lookupPointsList(table.input)
+		var got string
+		if ok {
+			got = pl.key
+		}
+		if got != table.want {
+			t.Errorf("Incorrect list for %s -- got %q -- want %q", table.input, got, table.want)
+		}
+	}
+}
+
+func TestPointsQuery(t *testing.T) {
+	var err error
+	nameToCardCache, err = lru.NewARC(2048)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	source := newFakeScryfall(t)
+	canadian := defaultPointsList()
+	canadian.setPoints(map[string]int{normaliseCardName("Ancestral Recall"): 7, normaliseCardName("Ponder"): 4})
+	defer canadian.setPoints(nil)
+	sevenPoint, _ := lookupPointsList("7point")
+
+	tables := []struct {
+		input  string
+		output string
+	}{
+		{"Ancestral Recall", "Canadian Highlander · Ancestral Recall 7 · Total 7/10"},
+		{"Ancestral Recall, Tarmogoyf", "Canadian Highlander · Ancestral Recall 7 · Tarmogoyf 0 · Total 7/10"},
+		{"chl Ancestral Recall, Ponder, Lightning Bolt", "Canadian Highlander · Ancestral Recall 7 · Ponder 4 · Total 11/10, over the cap by 1 · Not found: Lightning Bolt"},
+		{"Jace, the Mind Sculptor, Ponder", "Canadian Highlander · Jace, the Mind Sculptor 0 · Ponder 4 · Total 4/10"},
+		{"7pt Ponder", "There's no source for the Australian 7-Point points list"},
+		{",", "!points [list] <card, card, ...>"},
+	}
+	for _, table := range tables {
+		got := handlePointsQuery(&fryatogParams{isIRC: true, source: source}, strings.Fields(table.input))
+		if got != table.output {
+			t.Errorf("Incorrect output for %s -- got %q -- want %q", table.input, got, table.output)
+		}
+	}
+
+	sevenPoint.setPoints(map[string]int{normaliseCardName("Ponder"): 1})
+	defer sevenPoint.setPoints(nil)
+	pointsTables := []struct {
+		pl     *pointsList
+		isIRC  bool
+		output string
+	}{
+		{canadian, true, "· [4 CHL points]"},
+		{canadian, false, "[:point_right: 4 :point_left:]"},
+		{sevenPoint, false, "[:point_right: 1 7PT :point_left:]"},
+	}
+	for _, table := range pointsTables {
+		if got := table.pl.formatPoints("Ponder", table.isIRC); got != table.output {
+			t.Errorf("Incorrect points for %s -- got %q -- want %q", table.pl.key, got, table.output)
+		}
+	}
+	if got := canadian.formatPoints("Tarmogoyf", true); got != "" {
+		t.Errorf("Unexpected points for Tarmogoyf -- got %q", got)
+	}
+}
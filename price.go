@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+	log "gopkg.in/inconshreveable/log15.v2"
+)
+
+// Scryfall only updates prices daily, but they're stale long before a card's text is
+const priceCacheTTL = 30 * time.Minute
+
+const noPricesFound = "No prices found"
+
+// Printings we've recently fetched prices for, keyed by card name and set
+var priceCache = cache.New(priceCacheTTL, 5*time.Minute)
+
+// priceQuery is a parsed !price request
+type priceQuery struct {
+	cardTokens []string
+	set        string
+	foil       bool
+}
+
+// parsePriceQuery pulls the set: and foil modifiers out of the card name.
+func parsePriceQuery(tokens []string) priceQuery {
+	var pq priceQuery
+	for _, t := range tokens {
+		lt := strings.ToLower(t)
+		switch {
+		case strings.HasPrefix(lt, "set:") && len(lt) > 4:
+			pq.set = lt[4:]
+		// A card called "Foil" exists, so only treat it as a modifier if there's something else to look up
+		case lt == "foil" && len(tokens) > 1:
+			pq.foil = true
+		default:
+			pq.cardTokens = append(pq.cardTokens, t)
+		}
+	}
+	return pq
+}
+
+// getPricedPrinting fetches a current copy of a card, in a specific set if asked, for its prices.
+func getPricedPrinting(source CardSource, card Card, set string) (Card, error) {
+	key := normaliseCardName(card.Name) + "|" + set
+	if c, ok := priceCache.Get(key); ok {
+		return c.(Card), nil
+	}
+	var printing Card
+	if set == "" {
+		// Go back to Scryfall for the default printing, as the one we have cached could be days old
+		p, err := source.Fuzzy(card.Name, false)
+		if err != nil {
+			return printing, err
+		}
+		printing = p
+	} else {
+		// Every page, as the set could be anywhere in a much reprinted card's printings.
+		// Not Printing, since the printings it caches keep their prices for good.
+		prints, err := source.Prints(&card, false, true)
+		if err != nil {
+			return printing, err
+		}
+		for _, p := range prints {
+			if p.Set == set {
+				printing = p
+				break
+			}
+		}
+		if printing.ID == "" {
+			return printing, fmt.Errorf("Not printed in %s", strings.ToUpper(set))
+		}
+	}
+	priceCache.Set(key, printing, cache.DefaultExpiration)
+	return printing, nil
+}
+
+// formatPrices lays out a printing's prices, each linked to somewhere selling it.
+func (card *Card) formatPrices(foil bool, isIRC bool) string {
+	var prices []string
+	addPrice := func(price, format, purchaseURI string) {
+		if price == "" {
+			return
+		}
+		price = fmt.Sprintf(format, price)
+		switch {
+		case purchaseURI == "":
+		case isIRC:
+			price = fmt.Sprintf("%s <%s>", price, purchaseURI)
+		default:
+			price = fmt.Sprintf("<%s|%s>", purchaseURI, price)
+		}
+		prices = append(prices, price)
+	}
+	// Some printings only come etched, so that's the only dollar price they have
+	addUsdPrice := func(price string) {
+		if price == "" {
+			addPrice(card.Prices.UsdEtched, "$%s etched", card.PurchaseUris.Tcgplayer)
+			return
+		}
+		addPrice(price, "$%s", card.PurchaseUris.Tcgplayer)
+	}
+	if foil {
+		addUsdPrice(card.Prices.UsdFoil)
+		addPrice(card.Prices.EurFoil, "€%s", card.PurchaseUris.Cardmarket)
+	} else {
+		addUsdPrice(card.Prices.Usd)
+		addPrice(card.Prices.Eur, "€%s", card.PurchaseUris.Cardmarket)
+		addPrice(card.Prices.Tix, "%s tix", card.PurchaseUris.Cardhoarder)
+	}
+
+	printing := strings.ToUpper(card.Set)
+	if foil {
+		printing += " foil"
+	}
+	var name string
+	if isIRC {
+		name = fmt.Sprintf("\x02%s\x0F [%s]", card.Name, printing)
+	} else {
+		name = fmt.Sprintf("*<%s|%s>* [%s]", card.ScryfallURI, card.Name, printing)
+	}
+	if len(prices) == 0 {
+		return fmt.Sprintf("%s for %s", noPricesFound, name)
+	}
+	return fmt.Sprintf("%s · %s", name, strings.Join(prices, " · "))
+}
+
+func handlePriceQuery(params *fryatogParams, cardTokens []string) string {
+	priceRequests.Add(1)
+	pq := parsePriceQuery(cardTokens)
+	card, err := findCard(pq.cardTokens, false, params.source.Named)
+	if err != nil {
+		return "Card not found"
+	}
+	printing, err := getPricedPrinting(params.source, card, pq.set)
+	if err != nil {
+		log.Debug("HandlePriceQuery: Unable to get printing", "Card", card.Name, "Set", pq.set, "Error", err)
+		if pq.set != "" {
+			return fmt.Sprintf("%s is not printed in %s", card.Name, strings.ToUpper(pq.set))
+		}
+		return noPricesFound
+	}
+	return printing.formatPrices(pq.foil, params.isIRC)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+func TestParsePriceQuery(t *testing.T) {
+	tables := []struct {
+		input  string
+		output priceQuery
+	}{
+		{"Ponder", priceQuery{cardTokens: []string{"Ponder"}}},
+		{"Faithless Looting set:DKA foil", priceQuery{cardTokens: []string{"Faithless", "Looting"}, set: "dka", foil: true}},
+		{"foil Tarmogoyf", priceQuery{cardTokens: []string{"Tarmogoyf"}, foil: true}},
+		{"Foil", priceQuery{cardTokens: []string{"Foil"}}},
+		{"Ponder set:", priceQuery{cardTokens: []string{"Ponder", "set:"}}},
+	}
+	for _, table := range tables {
+		got := parsePriceQuery(strings.Fields(table.input))
+		if !reflect.DeepEqual(got, table.output) {
+			t.Errorf("Incorrect output for %s -- got %+v -- want %+v", table.input, got, table.output)
+		}
+	}
+}
+
+func TestPriceQuery(t *testing.T) {
+	var err error
+	nameToCardCache, err = lru.NewARC(2048)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	priceCache.Flush()
+	defer priceCache.Flush()
+	source := newFakeScryfall(t)
+
+	tables := []struct {
+		input    string
+		isIRC    bool
+		prefix   string
+		contains []string
+	}{
+		{"Faithless Looting", true, "\x02Faithless Looting\x0F [UMA] · $0.80 <https://shop.tcgplayer.com/", []string{"€0.59 <https://www.cardmarket.com/", "0.50 tix <https://www.cardhoarder.com/"}},
+		{"Faithless Looting foil", true, "\x02Faithless Looting\x0F [UMA foil] · $2.56 <", nil},
+		{"Faithless Looting set:dka foil", true, "\x02Faithless Looting\x0F [DKA foil] · $17.33 <", nil},
+		{"Faithless Looting set:c15", false, "*<https://scryfall.com/card/c15/", []string{"[C15] · <https://shop.tcgplayer.com/", "|$1.51>"}},
+		{"Faithless Looting set:pidw foil", true, "No prices found for \x02Faithless Looting\x0F [PIDW foil]", nil},
+		{"Faithless Looting set:lea", true, "Faithless Looting is not printed in LEA", nil},
+		{"Lightning Bolt", true, "Card not found", nil},
+	}
+	for _, table := range tables {
+		got := handlePriceQuery(&fryatogParams{isIRC: table.isIRC, source: source}, strings.Fields(table.input))
+		if !strings.HasPrefix(got, table.prefix) {
+			t.Errorf("Incorrect output for %s -- got %q -- want prefix %q", table.input, got, table.prefix)
+		}
+		for _, c := range table.contains {
+			if !strings.Contains(got, c) {
+				t.Errorf("Incorrect output for %s -- got %q -- want it to contain %q", table.input, got, c)
+			}
+		}
+	}
+	if _, ok := priceCache.Get("faithlesslooting|dka"); !ok {
+		t.Errorf("Expected the DKA printing to be cached")
+	}
+
+	// The set can be on any page of the printings
+	if got, want := handlePriceQuery(&fryatogParams{isIRC: true, source: source}, []string{"Disenchant", "set:lea"}), "\x02Disenchant\x0F [LEA] · $72.47"; !strings.HasPrefix(got, want) {
+		t.Errorf("Incorrect output for Disenchant set:lea -- got %q -- want prefix %q", got, want)
+	}
+
+	etched := Card{Name: "Sol Ring", Set: "cmr"}
+	etched.Prices.UsdEtched = "12.34"
+	for _, foil := range []bool{false, true} {
+		if got, want := etched.formatPrices(foil, true), " · $12.34 etched"; !strings.HasSuffix(got, want) {
+			t.Errorf("Incorrect etched price -- got %q -- want suffix %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// How many printings fit in one message
+const ircPrintingsPerPage = 8
+const slackPrintingsPerPage = 40
+
+// How many single printings we remember, separately from the cards themselves
+const printingCacheSize = 512
+
+var printingsYearRegex = regexp.MustCompile(`^(\d{4})(?:-(\d{4}))?$`)
+
+// "card SET 123", the collector number needs a digit in it so that "card Fire Ice" is still a card,
+// and the set has to be one Scryfall knows so that "card Opt 2" is too
+var cardPrintingRegex = regexp.MustCompile(`(?i)^card\s+([a-z0-9]{2,6})\s+(\S*\d\S*)$`)
+var setCodeRegex = regexp.MustCompile(`(?i)^[a-z0-9]{2,6}$`)
+
+// printingCache holds single printings by set and collector number, and by set and name,
+// so that they don't get mixed up with the canonical cards in nameToCardCache
+var printingCache, _ = lru.NewARC(printingCacheSize)
+
+// printingsQuery is a parsed !printings request
+type printingsQuery struct {
+	cardTokens []string
+	paperOnly  bool
+	fromYear   int
+	toYear     int
+	page       int
+}
+
+// parsePrintingsQuery pulls the filters and page number off the end of the card name.
+// They're only looked for at the end, so that card names with numbers in them still work.
+func parsePrintingsQuery(tokens []string) printingsQuery {
+	pq := printingsQuery{page: 1}
+	for len(tokens) > 1 {
+		last := strings.ToLower(tokens[len(tokens)-1])
+		if len(tokens) > 2 && strings.ToLower(tokens[len(tokens)-2]) == "page" {
+			if n, err := strconv.Atoi(last); err == nil && n > 0 {
+				pq.page = n
+				tokens = tokens[:len(tokens)-2]
+				continue
+			}
+		}
+		if last == "paper" || last == "paper-only" {
+			pq.paperOnly = true
+			tokens = tokens[:len(tokens)-1]
+			continue
+		}
+		if m := printingsYearRegex.FindStringSubmatch(last); m != nil {
+			pq.fromYear, _ = strconv.Atoi(m[1])
+			pq.toYear = pq.fromYear
+			if m[2] != "" {
+				pq.toYear, _ = strconv.Atoi(m[2])
+			}
+			if pq.toYear < pq.fromYear {
+				pq.fromYear, pq.toYear = pq.toYear, pq.fromYear
+			}
+			tokens = tokens[:len(tokens)-1]
+			continue
+		}
+		break
+	}
+	pq.cardTokens = tokens
+	return pq
+}
+
+// matches says whether a printing makes it through the query's filters.
+func (pq printingsQuery) matches(c *Card) bool {
+	if pq.paperOnly && (c.Digital || (len(c.Games) > 0 && !stringSliceContains(c.Games, "paper"))) {
+		return false
+	}
+	if pq.fromYear > 0 {
+		if len(c.ReleasedAt) < 4 {
+			return false
+		}
+		year, err := strconv.Atoi(c.ReleasedAt[:4])
+		if err != nil || year < pq.fromYear || year > pq.toYear {
+			return false
+		}
+	}
+	return true
+}
+
+// formatPrinting describes a single printing, e.g. "TSB Time Spiral Timeshifted (2006-10-06) R #6 [promo, digital]"
+func (card *Card) formatPrinting() string {
+	var flags []string
+	if card.Promo {
+		flags = append(flags, "promo")
+	}
+	if card.Digital {
+		flags = append(flags, "digital")
+	}
+	if card.FullArt {
+		flags = append(flags, "full-art")
+	}
+	if card.Timeshifted {
+		flags = append(flags, "timeshifted")
+	}
+	var rarity string
+	if card.Rarity != "" {
+		rarity = strings.ToUpper(card.Rarity[0:1])
+	}
+	ret := fmt.Sprintf("%s %s (%s) %s #%s", strings.ToUpper(card.Set), card.SetName, card.ReleasedAt, rarity, card.CollectorNumber)
+	if len(flags) > 0 {
+		ret += fmt.Sprintf(" [%s]", strings.Join(flags, ", "))
+	}
+	return ret
+}
+
+func handlePrintingsQuery(params *fryatogParams, cardTokens []string) string {
+	printingsRequests.Add(1)
+	pq := parsePrintingsQuery(cardTokens)
+	card, err := findCard(pq.cardTokens, false, params.source.Named)
+	if err != nil {
+		return "Card not found"
+	}
+	prints, err := params.source.Prints(&card, false, true)
+	if err != nil {
+		return "Problem fetching the printings"
+	}
+	var entries []string
+	for i := range prints {
+		if pq.matches(&prints[i]) {
+			entries = append(entries, prints[i].formatPrinting())
+		}
+	}
+	if len(entries) == 0 {
+		return fmt.Sprintf("No printings of %s found", card.Name)
+	}
+
+	perPage := slackPrintingsPerPage
+	if params.isIRC {
+		perPage = ircPrintingsPerPage
+	}
+	pages := (len(entries) + perPage - 1) / perPage
+	if pq.page > pages {
+		return fmt.Sprintf("There's no page %d of printings for %s", pq.page, card.Name)
+	}
+	start := (pq.page - 1) * perPage
+	end := min(start+perPage, len(entries))
+
+	var header string
+	if params.isIRC {
+		header = fmt.Sprintf("\x02%s\x0F: %d printings", card.Name, len(entries))
+	} else {
+		header = fmt.Sprintf("*<%s|%s>*: %d printings", card.ScryfallURI, card.Name, len(entries))
+	}
+	if pages > 1 {
+		header += fmt.Sprintf(" (page %d/%d)", pq.page, pages)
+	}
+	var ret string
+	if params.isIRC {
+		ret = header + " · " + strings.Join(entries[start:end], " · ")
+	} else {
+		ret = header + "\n" + strings.Join(entries[start:end], "\n")
+	}
+	if pq.page < pages {
+		ret += fmt.Sprintf("\nAsk for !printings %s page %d for more", pq.String(), pq.page+1)
+	}
+	return ret
+}
+
+// String gives back the query, without its page, so it can be asked again.
+func (pq printingsQuery) String() string {
+	ret := strings.Join(pq.cardTokens, " ")
+	if pq.paperOnly {
+		ret += " paper-only"
+	}
+	if pq.fromYear > 0 {
+		if pq.fromYear == pq.toYear {
+			ret += fmt.Sprintf(" %d", pq.fromYear)
+		} else {
+			ret += fmt.Sprintf(" %d-%d", pq.fromYear, pq.toYear)
+		}
+	}
+	return ret
+}
+
+// printingLookup is a request for one particular printing of a card
+type printingLookup struct {
+	cardName string
+	set      string
+	number   string
+}
+
+// parsePrintingLookup understands "Name|SET", "Name|SET|123" and "card SET 123"
+func parsePrintingLookup(message string) (printingLookup, bool) {
+	if m := cardPrintingRegex.FindStringSubmatch(message); m != nil && isKnownSetCode(m[1]) {
+		return printingLookup{set: strings.ToLower(m[1]), number: strings.ToLower(m[2])}, true
+	}
+	parts := strings.Split(message, "|")
+	if len(parts) < 2 || len(parts) > 3 {
+		return printingLookup{}, false
+	}
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	if parts[0] == "" || !setCodeRegex.MatchString(parts[1]) {
+		return printingLookup{}, false
+	}
+	pl := printingLookup{cardName: parts[0], set: strings.ToLower(parts[1])}
+	if len(parts) == 3 {
+		if parts[2] == "" || strings.ContainsAny(parts[2], " \t") {
+			return printingLookup{}, false
+		}
+		pl.number = strings.ToLower(parts[2])
+	}
+	return pl, true
+}
+
+func isPrintingLookup(message string) bool {
+	_, ok := parsePrintingLookup(message)
+	return ok
+}
+
+// printingCacheKey is the set and collector number, or the set and card name if there's no number
+func printingCacheKey(cardName string, set string, number string) string {
+	if number != "" {
+		return strings.ToLower(set) + "|" + strings.ToLower(number)
+	}
+	return strings.ToLower(set) + "|" + normaliseCardName(cardName)
+}
+
+// printedDifferences is what was actually on the card, where that's not what Oracle says now
+func (cc CommonCard) printedDifferences(name string, printedName string) string {
+	var r []string
+	if printedName != "" && printedName != name {
+		r = append(r, printedName)
+	}
+	if cc.PrintedTypeLine != "" && cc.PrintedTypeLine != cc.TypeLine {
+		r = append(r, cc.PrintedTypeLine)
+	}
+	if cc.PrintedText != "" && cc.PrintedText != cc.OracleText {
+		r = append(r, strings.Replace(cc.PrintedText, "\n", " \\ ", -1))
+	}
+	return strings.Join(r, " · ")
+}
+
+// formatPrintingLookup shows a printing with its Oracle text, followed by the printed text if it's different
+func (card *Card) formatPrintingLookup(isIRC bool, formats []string) string {
+	oracle := *card
+	oracle.PrintedName, oracle.PrintedText, oracle.PrintedTypeLine = "", "", ""
+	oracle.CardFaces = make([]CardFace, len(card.CardFaces))
+	copy(oracle.CardFaces, card.CardFaces)
+	var printed []string
+	if len(card.CardFaces) > 0 {
+		for i, cf := range card.CardFaces {
+			if p := cf.CommonCard.printedDifferences(cf.Name, cf.PrintedName); p != "" {
+				printed = append(printed, p)
+			}
+			oracle.CardFaces[i].PrintedName, oracle.CardFaces[i].PrintedText, oracle.CardFaces[i].PrintedTypeLine = "", "", ""
+		}
+	} else if p := card.CommonCard.printedDifferences(card.Name, card.PrintedName); p != "" {
+		printed = append(printed, p)
+	}
+
+	var ret string
+	if isIRC {
+		ret = oracle.formatCardForIRCWithFormats(formats)
+	} else {
+		ret = oracle.formatCardForSlack()
+	}
+	if len(printed) > 0 {
+		if isIRC {
+			ret += "\n\x1DPrinted as:\x0F " + strings.Join(printed, " // ")
+		} else {
+			ret += "\n_Printed as:_ " + strings.Join(printed, " // ")
+		}
+	}
+	return ret
+}
+
+func handlePrintingLookup(params *fryatogParams, message string) string {
+	pl, ok := parsePrintingLookup(message)
+	if !ok {
+		return ""
+	}
+	card, err := params.source.Printing(pl.cardName, pl.set, pl.number)
+	if err != nil {
+		if pl.number != "" {
+			return fmt.Sprintf("No printing found at %s #%s", strings.ToUpper(pl.set), pl.number)
+		}
+		return fmt.Sprintf("No printing of %s found in %s", pl.cardName, strings.ToUpper(pl.set))
+	}
+	return card.formatPrintingLookup(params.isIRC, params.legalityFormats())
+}
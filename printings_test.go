@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+func TestParsePrintingsQuery(t *testing.T) {
+	tables := []struct {
+		input  string
+		output printingsQuery
+	}{
+		{"Disenchant", printingsQuery{cardTokens: []string{"Disenchant"}, page: 1}},
+		{"Disenchant paper-only", printingsQuery{cardTokens: []string{"Disenchant"}, paperOnly: true, page: 1}},
+		{"Disenchant 1996 page 2", printingsQuery{cardTokens: []string{"Disenchant"}, fromYear: 1996, toYear: 1996, page: 2}},
+		{"Disenchant 2005-1995 paper", printingsQuery{cardTokens: []string{"Disenchant"}, paperOnly: true, fromYear: 1995, toYear: 2005, page: 1}},
+		{"1996 World Champion", printingsQuery{cardTokens: []string{"1996", "World", "Champion"}, page: 1}},
+		{"Page", printingsQuery{cardTokens: []string{"Page"}, page: 1}},
+	}
+	for _, table := range tables {
+		got := parsePrintingsQuery(strings.Fields(table.input))
+		if !reflect.DeepEqual(got, table.output) {
+			t.Errorf("Incorrect output for %s -- got %+v -- want %+v", table.input, got, table.output)
+		}
+	}
+}
+
+func TestPrintingsQuery(t *testing.T) {
+	var err error
+	nameToCardCache, err = lru.NewARC(2048)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	source := newFakeScryfall(t)
+
+	tables := []struct {
+		input  string
+		isIRC  bool
+		output string
+	}{
+		{"Disenchant", true, "\x02Disenchant\x0F: 43 printings (page 1/6) · A25 Masters 25 (2018-03-16) C #12 · IMA Iconic Masters (2017-11-17) C #15 · PRM Magic Online Promos (2017-06-17) C #36184 [promo, digital] · CN2 Conspiracy: Take the Crown (2016-08-26) C #82 · TPR Tempest Remastered (2015-05-06) C #11 [digital] · PRM Magic Online Promos (2013-01-11) C #31397 [promo, digital, full-art] · ME3 Masters Edition III (2009-09-07) C #7 [digital] · ME2 Masters Edition II (2008-09-22) C #10 [digital]\nAsk for !printings Disenchant page 2 for more"},
+		{"Disenchant 1993-1994 paper-only", true, "\x02Disenchant\x0F: 7 printings · SUM Summer Magic / Edgar (1994-06-21) C #17 · 3ED Revised Edition (1994-04-01) C #17 · CEI Intl. Collectors’ Edition (1993-12-10) C #204 · CED Collectors’ Edition (1993-12-10) C #204 · 2ED Unlimited Edition (1993-12-01) C #19 · LEB Limited Edition Beta (1993-10-04) C #19 · LEA Limited Edition Alpha (1993-08-05) C #18"},
+		{"Disenchant 1996", true, "\x02Disenchant\x0F: 8 printings · MIR Mirage (1996-10-08) C #10 · PARL Arena League 1996 (1996-08-02) R #6 [promo] · PTC Pro Tour Collector Set (1996-05-01) C #sr22 · PTC Pro Tour Collector Set (1996-05-01) C #pp22sb · PTC Pro Tour Collector Set (1996-05-01) C #pp22 · PTC Pro Tour Collector Set (1996-05-01) C #ml22 · PTC Pro Tour Collector Set (1996-05-01) C #et22 · PTC Pro Tour Collector Set (1996-05-01) C #bl22"},
+		{"Disenchant page 7", true, "There's no page 7 of printings for Disenchant"},
+		{"Disenchant 1996 page 2", true, "There's no page 2 of printings for Disenchant"},
+		{"Disenchant 2030", true, "No printings of Disenchant found"},
+		{"Ponder", true, "Problem fetching the printings"},
+		{"Lightning Bolt", true, "Card not found"},
+	}
+	for _, table := range tables {
+		got := handlePrintingsQuery(&fryatogParams{isIRC: table.isIRC, source: source}, strings.Fields(table.input))
+		if got != table.output {
+			t.Errorf("Incorrect output for %s -- got %q -- want %q", table.input, got, table.output)
+		}
+	}
+
+	got := handlePrintingsQuery(&fryatogParams{source: source}, []string{"Disenchant", "paper-only"})
+	lines := strings.Split(got, "\n")
+	if lines[0] != "*<https://scryfall.com/card/a25/12/disenchant?utm_source=api|Disenchant>*: 38 printings" || len(lines) != 39 {
+		t.Errorf("Incorrect Slack output -- got %q", got)
+	}
+}
+
+func TestParsePrintingLookup(t *testing.T) {
+	setKnownSets([]CardSet{{Code: "lea"}, {Code: "ptc"}})
+	defer setKnownSets(nil)
+	tables := []struct {
+		input  string
+		output printingLookup
+		ok     bool
+	}{
+		{"Disenchant|LEA", printingLookup{cardName: "Disenchant", set: "lea"}, true},
+		{"Disenchant | lea | 18", printingLookup{cardName: "Disenchant", set: "lea", number: "18"}, true},
+		{"card LEA 18", printingLookup{set: "lea", number: "18"}, true},
+		{"card PTC pp22sb", printingLookup{set: "ptc", number: "pp22sb"}, true},
+		{"card Fire Ice", printingLookup{}, false},
+		// Not a set, so it's a card name with a number on the end
+		{"card Ugin 8", printingLookup{}, false},
+		{"Disenchant", printingLookup{}, false},
+		{"|LEA", printingLookup{}, false},
+		{"Disenchant|Limited Edition Alpha", printingLookup{}, false},
+		{"Disenchant|LEA|18|2", printingLookup{}, false},
+	}
+	for _, table := range tables {
+		got, ok := parsePrintingLookup(table.input)
+		if ok != table.ok || !reflect.DeepEqual(got, table.output) {
+			t.Errorf("Incorrect output for %s -- got %+v %v -- want %+v %v", table.input, got, ok, table.output, table.ok)
+		}
+	}
+}
+
+func TestPrintingLookup(t *testing.T) {
+	printingCache.Purge()
+	defer printingCache.Purge()
+	setKnownSets([]CardSet{{Code: "lea"}, {Code: "4ed"}})
+	defer setKnownSets(nil)
+	source := newFakeScryfall(t)
+
+	tables := []struct {
+		input  string
+		output string
+	}{
+		{"Disenchant|LEA", "\x02Disenchant\x0F {1}{W} · Instant · Destroy target artifact or enchantment. · LEA-C · Vin,Cmr,Leg,Mod"},
+		{"Disenchant|LEA|18", "\x02Disenchant\x0F {1}{W} · Instant · Destroy target artifact or enchantment. · LEA-C · Vin,Cmr,Leg,Mod"},
+		{"card 4ed 22", "\x02Disenchant\x0F {1}{W} · Instant · Destroy target artifact or enchantment. · 4ED-C · Vin,Cmr,Leg,Mod"},
+		{"Faithless Looting|DKA", "\x02Faithless Looting\x0F {R} · Sorcery · Draw two cards, then discard two cards. \\ Flashback {2}{R} \x1D(You may cast this card from your graveyard for its flashback cost. Then exile it.)\x0F · DKA-C · Vin,Cmr,Leg,Mod"},
+		{"Disenchant|FBB", "\x02Disenchant\x0F {1}{W} · Instant · Destroy target artifact or enchantment. · FBB-C · Vin,Cmr,Leg,Mod\n\x1DPrinted as:\x0F Désenchantement"},
+		{"Disenchant|XLN", "No printing of Disenchant found in XLN"},
+		{"card LEA 999", "No printing found at LEA #999"},
+	}
+	for _, table := range tables {
+		got := handlePrintingLookup(&fryatogParams{isIRC: true, source: source}, table.input)
+		if got != table.output {
+			t.Errorf("Incorrect output for %s -- got %q -- want %q", table.input, got, table.output)
+		}
+	}
+	if _, ok := printingCache.Get("lea|18"); !ok {
+		t.Errorf("Expected the LEA printing to be cached by collector number")
+	}
+	if _, ok := printingCache.Get("lea|999"); ok {
+		t.Errorf("Cached a printing that wasn't found")
+	}
+}
+
+func TestFormatPrintingLookup(t *testing.T) {
+	fi, err := os.Open(RealCards["Erebos' Titan"])
+	if err != nil {
+		t.Fatalf("Unable to open %v", RealCards["Erebos' Titan"])
+	}
+	var c Card
+	if err := json.NewDecoder(fi).Decode(&c); err != nil {
+		t.Fatalf("Something went wrong parsing the card: %s", err)
+	}
+	got := strings.Split(c.formatPrintingLookup(true, []string{"vintage"}), "\n")
+	if len(got) != 2 {
+		t.Fatalf("Expected the Oracle and printed text on separate lines -- got %q", got)
+	}
+	if !strings.HasPrefix(got[0], "\x02Erebos's Titan\x0F {1}{B}{B}{B} · Creature — Giant · 5/5 · As long as your opponents control no creatures") {
+		t.Errorf("Incorrect Oracle line -- got %q", got[0])
+	}
+	if !strings.HasPrefix(got[1], "\x1DPrinted as:\x0F Erebos' Titan · Kreatur — Riese · Solange deine Gegner") {
+		t.Errorf("Incorrect printed line -- got %q", got[1])
+	}
+	if c.PrintedText == "" {
+		t.Errorf("Formatting the Oracle text shouldn't touch the card")
+	}
+}
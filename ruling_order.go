@@ -0,0 +1,201 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+
+	raven "github.com/getsentry/raven-go"
+	log "gopkg.in/inconshreveable/log15.v2"
+)
+
+var rulingOrderFile = "ruling_order.json"
+
+// rulingOrder is Gatherer's order for a card's rulings, where Scryfall's dates don't give it.
+// The order is of each ruling's key, and any it leaves out come after, in date order.
+type rulingOrder struct {
+	// Only there for whoever's reading the file
+	Name  string   `json:"name"`
+	Order []string `json:"order"`
+}
+
+// key names a ruling by its date and what it says, which unlike where Scryfall has it, stays put when rulings are added
+func (ruling CardRuling) key() string {
+	sum := sha1.Sum([]byte(ruling.Comment))
+	return ruling.PublishedAt + "-" + hex.EncodeToString(sum[:4])
+}
+
+// The ruling order overrides, by oracle ID
+var (
+	rulingOrders     map[string]rulingOrder
+	rulingOrdersLock sync.RWMutex
+)
+
+func importRulingOrders() error {
+	log.Debug("In importRulingOrders")
+	var ro map[string]rulingOrder
+	if err := readJSONFile(rulingOrderFile, &ro); err != nil {
+		raven.CaptureError(err, nil)
+		log.Warn("Error reading ruling order file", "Error", err)
+		return err
+	}
+	rulingOrdersLock.Lock()
+	rulingOrders = ro
+	rulingOrdersLock.Unlock()
+	log.Debug("Populated ruling orders", "Length", len(ro))
+	checkRulingOrders()
+	return nil
+}
+
+// checkRulingOrders logs any override that doesn't fit the card's stored rulings any more, as they're only checked once both are loaded
+func checkRulingOrders() {
+	if !haveStoredRulings() {
+		return
+	}
+	rulingOrdersLock.RLock()
+	defer rulingOrdersLock.RUnlock()
+	for oracleID, ro := range rulingOrders {
+		rulings, _ := lookupStoredRulings(oracleID)
+		keys := make(map[string]bool)
+		for _, r := range rulings {
+			keys[r.key()] = true
+		}
+		for _, k := range ro.Order {
+			if !keys[k] {
+				log.Warn("Ruling order doesn't match the rulings", "Card", ro.Name, "Order", len(ro.Order), "Rulings", len(rulings), "Missing", k)
+			}
+		}
+	}
+}
+
+func lookupRulingOrder(oracleID string) ([]string, bool) {
+	rulingOrdersLock.RLock()
+	defer rulingOrdersLock.RUnlock()
+	ro, ok := rulingOrders[oracleID]
+	return ro.Order, ok && oracleID != ""
+}
+
+// setRulingOrder changes or, with no order, removes a card's override, and saves them all
+func setRulingOrder(card *Card, order []string) error {
+	rulingOrdersLock.Lock()
+	defer rulingOrdersLock.Unlock()
+	if rulingOrders == nil {
+		rulingOrders = make(map[string]rulingOrder)
+	}
+	if len(order) == 0 {
+		delete(rulingOrders, card.OracleID)
+	} else {
+		rulingOrders[card.OracleID] = rulingOrder{Name: card.Name, Order: order}
+	}
+	return writeJSONFile(rulingOrderFile, rulingOrders)
+}
+
+// orderRulings puts rulings in the override's order, then the rest in the order they were in
+func orderRulings(rulings []CardRuling, order []string) []CardRuling {
+	byKey := make(map[string]int)
+	for i, r := range rulings {
+		byKey[r.key()] = i
+	}
+	var ret []CardRuling
+	used := make(map[int]bool)
+	for _, k := range order {
+		if i, ok := byKey[k]; ok && !used[i] {
+			used[i] = true
+			ret = append(ret, rulings[i])
+		}
+	}
+	for i, r := range rulings {
+		if !used[i] {
+			ret = append(ret, r)
+		}
+	}
+	return ret
+}
+
+// forgetCachedRulings drops every cached copy of a card, under whatever name, so its rulings are sorted again
+func forgetCachedRulings(oracleID string) {
+	if nameToCardCache == nil {
+		return
+	}
+	for _, k := range nameToCardCache.Keys() {
+		if v, ok := nameToCardCache.Peek(k); ok {
+			if c, ok := v.(Card); ok && c.OracleID == oracleID {
+				nameToCardCache.Remove(k)
+			}
+		}
+	}
+}
+
+// handleRulingOrderCommand lets ops put a card's rulings in Gatherer's order, using the numbers !ruling shows now.
+// !rulingorder <card> 11 1 2 puts ruling 11 first, then 1 and 2, then the rest.
+func handleRulingOrderCommand(params *fryatogParams, tokens []string) string {
+	usage := "!rulingorder reload, !rulingorder clear <card>, or !rulingorder <card> <ruling numbers in Gatherer order>"
+	if len(tokens) == 1 && tokens[0] == "reload" {
+		if err := importRulingOrders(); err != nil {
+			return "Problem reloading the ruling orders"
+		}
+		// The cached cards could be in any order now
+		nameToCardCache.Purge()
+		return "Done!"
+	}
+	clearing := len(tokens) > 0 && tokens[0] == "clear"
+	if clearing {
+		tokens = tokens[1:]
+	}
+	// The numbers are on the end, and the card name is the rest
+	var numbers []int
+	end := len(tokens)
+	for ; end > 0 && !clearing; end-- {
+		n, err := strconv.Atoi(tokens[end-1])
+		if err != nil {
+			break
+		}
+		numbers = append([]int{n}, numbers...)
+	}
+	if end == 0 || (!clearing && len(numbers) == 0) {
+		return usage
+	}
+	card, err := findCard(tokens[:end], false, params.source.Named)
+	if err != nil || card.OracleID == "" {
+		return "Card not found"
+	}
+	if clearing {
+		if _, ok := lookupRulingOrder(card.OracleID); !ok {
+			return fmt.Sprintf("%s has no ruling order", card.Name)
+		}
+		if err := setRulingOrder(&card, nil); err != nil {
+			log.Warn("Error saving ruling orders", "Error", err)
+			return "Problem saving the ruling orders"
+		}
+		forgetCachedRulings(card.OracleID)
+		return fmt.Sprintf("%s's rulings are back in date order", card.Name)
+	}
+
+	// Work out where Scryfall has each of the rulings we were given the numbers of
+	card.Rulings = nil
+	if err := card.fetchRulings(params.source); err != nil {
+		return "Problem fetching the rulings"
+	}
+	byNumber := make(map[int]string)
+	for _, r := range card.officialRulings(-1) {
+		byNumber[r.Number] = r.key()
+	}
+	var order []string
+	seen := make(map[int]bool)
+	for _, n := range numbers {
+		k, ok := byNumber[n]
+		if !ok || seen[n] {
+			return fmt.Sprintf("%s has no ruling %d, or it's there twice", card.Name, n)
+		}
+		seen[n] = true
+		order = append(order, k)
+	}
+	if err := setRulingOrder(&card, order); err != nil {
+		log.Warn("Error saving ruling orders", "Error", err)
+		return "Problem saving the ruling orders"
+	}
+	forgetCachedRulings(card.OracleID)
+	return fmt.Sprintf("Saved the ruling order for %s", card.Name)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+func TestRulingOrderCommand(t *testing.T) {
+	oldFile := rulingOrderFile
+	rulingOrderFile = filepath.Join(t.TempDir(), "ruling_order.json")
+	defer func() {
+		rulingOrderFile = oldFile
+		importRulingOrders()
+	}()
+	var err error
+	nameToCardCache, err = lru.NewARC(2048)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	source := newFakeScryfall(t)
+	params := &fryatogParams{source: source}
+	ruling := func(n int) string {
+		c, err := findCard([]string{"Tawnos's", "Coffin"}, false, source.Named)
+		if err != nil {
+			t.Fatalf("Unable to fetch Tawnos's Coffin: %v", err)
+		}
+		return c.getRulings(source, n)
+	}
+	tapped, token, types := ruling(1), ruling(2), ruling(6)
+
+	tables := []struct {
+		input  string
+		output string
+	}{
+		{"Tawnos's Coffin", "!rulingorder reload, !rulingorder clear <card>, or !rulingorder <card> <ruling numbers in Gatherer order>"},
+		{"Tawnos's Coffin 9", "Tawnos's Coffin has no ruling 9, or it's there twice"},
+		{"Tawnos's Coffin 6 6", "Tawnos's Coffin has no ruling 6, or it's there twice"},
+		{"clear Tawnos's Coffin", "Tawnos's Coffin has no ruling order"},
+		// It's in use straight away, even though the card is cached
+		{"Tawnos's Coffin 6 2", "Saved the ruling order for Tawnos's Coffin"},
+	}
+	for _, table := range tables {
+		if got := handleRulingOrderCommand(params, strings.Fields(table.input)); got != table.output {
+			t.Errorf("Incorrect output for %s -- got %q -- want %q", table.input, got, table.output)
+		}
+	}
+	if got := []string{ruling(1), ruling(2), ruling(3)}; got[0] != types || got[1] != token || got[2] != tapped {
+		t.Errorf("Incorrect ruling order -- got %q", got)
+	}
+
+	// It survives a reload, and can be taken away again
+	if got := handleRulingOrderCommand(params, []string{"reload"}); got != "Done!" {
+		t.Errorf("Incorrect reload -- got %q", got)
+	}
+	if got := ruling(1); got != types {
+		t.Errorf("Incorrect ruling after a reload -- got %q -- want %q", got, types)
+	}
+	if got := handleRulingOrderCommand(params, strings.Fields("clear Tawnos's Coffin")); got != "Tawnos's Coffin's rulings are back in date order" {
+		t.Errorf("Incorrect clear -- got %q", got)
+	}
+	if got := ruling(1); got != tapped {
+		t.Errorf("Incorrect ruling after clearing -- got %q -- want %q", got, tapped)
+	}
+}
+
+func TestOrderRulings(t *testing.T) {
+	first := CardRuling{PublishedAt: "2017-08-25", Comment: "Gatherer has this first."}
+	second := CardRuling{PublishedAt: "2017-08-25", Comment: "And this second."}
+	later := CardRuling{PublishedAt: "2019-01-01", Comment: "A later ruling."}
+	order := []string{first.key(), second.key()}
+
+	// The keys still find the rulings after Scryfall adds one in front of them
+	for _, rulings := range [][]CardRuling{{second, first}, {later, second, first}} {
+		got := orderRulings(rulings, order)
+		if got[0] != first || got[1] != second {
+			t.Errorf("Incorrect ruling order -- got %v", got)
+		}
+	}
+	if first.key() == second.key() {
+		t.Errorf("Rulings on the same day have the same key %q", first.key())
+	}
+}
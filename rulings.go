@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	raven "github.com/getsentry/raven-go"
+	log "gopkg.in/inconshreveable/log15.v2"
+)
+
+const rulingsBulkDataType = "rulings"
+const rulingsBulkDataFile = "bulkrulings.json"
+
+// How often to re-download the bulk rulings
+var rulingsRefreshTimer = 24 * time.Hour
+
+// How many cards !rulings-mentioning lists before giving up
+const rulingMentionsShown = 10
+
+// The official rulings for every card, by oracle ID, so that every printing and language shares one copy.
+// Each card's rulings are in the order Scryfall has them, and are sorted as they're handed out.
+var (
+	storedRulings     map[string][]CardRuling
+	storedRulingsLock sync.RWMutex
+	// Worked out from storedRulings when it's first needed, and under the same lock
+	storedRulingMentions *rulingMentionIndex
+)
+
+// buildRulingsStore reads Scryfall's bulk rulings, one at a time, grouping them by card
+func buildRulingsStore(r io.Reader) (map[string][]CardRuling, error) {
+	dec := json.NewDecoder(r)
+	// Opening [
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("Something went wrong parsing the rulings")
+	}
+	ret := make(map[string][]CardRuling)
+	for dec.More() {
+		var cr CardRuling
+		if err := dec.Decode(&cr); err != nil {
+			return nil, fmt.Errorf("Something went wrong parsing the rulings")
+		}
+		if cr.OracleID == "" {
+			continue
+		}
+		ret[cr.OracleID] = append(ret[cr.OracleID], cr)
+	}
+	return ret, nil
+}
+
+// importRulings loads the bulk rulings, fetching them first if there aren't any or we're told to
+func importRulings(forceFetch bool) error {
+	log.Debug("In importRulings", "Forced?", forceFetch)
+	if _, err := os.Stat(rulingsBulkDataFile); forceFetch || err != nil {
+		if err := downloadBulkData(rulingsBulkDataType, rulingsBulkDataFile); err != nil {
+			log.Warn("Error fetching bulk rulings", "Error", err)
+			return err
+		}
+	}
+	f, err := os.Open(rulingsBulkDataFile)
+	if err != nil {
+		raven.CaptureError(err, nil)
+		log.Warn("Error opening bulk rulings file", "Error", err)
+		return err
+	}
+	defer f.Close()
+	rulings, err := buildRulingsStore(f)
+	if err != nil {
+		raven.CaptureError(err, nil)
+		log.Warn("Error parsing bulk rulings file", "Error", err)
+		return err
+	}
+	setStoredRulings(rulings)
+	log.Debug("Populated rulings", "Cards", len(rulings))
+	checkRulingOrders()
+	// Reading every ruling for names takes a while, so do it now rather than on someone's !rulings-mentioning
+	currentRulingMentions()
+	return nil
+}
+
+func setStoredRulings(rulings map[string][]CardRuling) {
+	storedRulingsLock.Lock()
+	defer storedRulingsLock.Unlock()
+	storedRulings = rulings
+	storedRulingMentions = nil
+}
+
+// haveStoredRulings says whether the bulk rulings are loaded, in which case they're the last word on every card
+func haveStoredRulings() bool {
+	storedRulingsLock.RLock()
+	defer storedRulingsLock.RUnlock()
+	return storedRulings != nil
+}
+
+// lookupStoredRulings gives a copy of a card's rulings, which is empty if it hasn't got any
+func lookupStoredRulings(oracleID string) ([]CardRuling, bool) {
+	storedRulingsLock.RLock()
+	defer storedRulingsLock.RUnlock()
+	if storedRulings == nil || oracleID == "" {
+		return nil, false
+	}
+	return append([]CardRuling{}, storedRulings[oracleID]...), true
+}
+
+func refreshRulingsTimer() {
+	for {
+		time.Sleep(rulingsRefreshTimer)
+		log.Debug("Refreshing bulk rulings")
+		if err := importRulings(true); err != nil {
+			log.Warn("Refresh bulk rulings timer", "Error", err)
+		}
+	}
+}
+
+// rulingMention is another card whose rulings talk about the card we asked about
+type rulingMention struct {
+	card    string
+	numbers []int
+}
+
+func (rm rulingMention) String() string {
+	var numbers []string
+	for _, n := range rm.numbers {
+		numbers = append(numbers, fmt.Sprint(n))
+	}
+	return fmt.Sprintf("%s (%s)", rm.card, strings.Join(numbers, ", "))
+}
+
+// mentionRegex matches any of a card's names as a whole name, and not part of a longer word
+func mentionRegex(card *Card) *regexp.Regexp {
+	names := []string{regexp.QuoteMeta(card.Name)}
+	for _, cf := range card.CardFaces {
+		names = append(names, regexp.QuoteMeta(cf.Name))
+	}
+	return regexp.MustCompile(`(?:^|[^\pL\pN])(?:` + strings.Join(names, "|") + `)(?:$|[^\pL\pN])`)
+}
+
+// rulingMentionIndex is which cards' official rulings name each card
+type rulingMentionIndex struct {
+	// The oracle IDs of the cards whose rulings name a card, by the named card's oracle ID
+	mentionedBy map[string][]string
+	// Every card's name, by oracle ID
+	names map[string]string
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+// buildRulingMentions reads every official ruling for the names of cards, as whole names and not part of a longer word
+func buildRulingMentions(rulings map[string][]CardRuling, cards []Card) *rulingMentionIndex {
+	rmi := &rulingMentionIndex{mentionedBy: make(map[string][]string), names: make(map[string]string)}
+	byName := make(map[string][]string)
+	// Only worth looking for a name where one could start, and no further than the longest
+	firstRunes := make(map[rune]bool)
+	longest := 0
+	addName := func(name string, oracleID string) {
+		if name == "" || stringSliceContains(byName[name], oracleID) {
+			return
+		}
+		byName[name] = append(byName[name], oracleID)
+		r, _ := utf8.DecodeRuneInString(name)
+		firstRunes[r] = true
+		longest = max(longest, len(name))
+	}
+	for _, c := range cards {
+		if c.OracleID == "" {
+			continue
+		}
+		rmi.names[c.OracleID] = c.Name
+		addName(c.Name, c.OracleID)
+		for _, cf := range c.CardFaces {
+			addName(cf.Name, c.OracleID)
+		}
+	}
+
+	for oracleID, rs := range rulings {
+		found := make(map[string]bool)
+		for _, r := range rs {
+			if r.Source != "wotc" {
+				continue
+			}
+			// Wizards writes the apostrophes in names the fancy way
+			text := strings.ReplaceAll(r.Comment, "’", "'")
+			for i, first := range text {
+				if !firstRunes[first] {
+					continue
+				}
+				if before, _ := utf8.DecodeLastRuneInString(text[:i]); i > 0 && isWordRune(before) {
+					continue
+				}
+				for j := i + utf8.RuneLen(first); j <= min(len(text), i+longest); j++ {
+					if j < len(text) {
+						if after, _ := utf8.DecodeRuneInString(text[j:]); !utf8.RuneStart(text[j]) || isWordRune(after) {
+							continue
+						}
+					}
+					for _, named := range byName[text[i:j]] {
+						if named != oracleID && !found[named] {
+							found[named] = true
+							rmi.mentionedBy[named] = append(rmi.mentionedBy[named], oracleID)
+						}
+					}
+				}
+			}
+		}
+	}
+	return rmi
+}
+
+// currentRulingMentions gives the mentions in the stored rulings, working them out if the rulings are new.
+// There's nothing to name until the card index is loaded, so that's nil.
+func currentRulingMentions() *rulingMentionIndex {
+	storedRulingsLock.RLock()
+	rmi := storedRulingMentions
+	storedRulingsLock.RUnlock()
+	if rmi != nil {
+		return rmi
+	}
+	ci := cardIndex.Load()
+	if ci.Len() == 0 {
+		return nil
+	}
+	storedRulingsLock.Lock()
+	defer storedRulingsLock.Unlock()
+	if storedRulings == nil {
+		return nil
+	}
+	if storedRulingMentions == nil {
+		storedRulingMentions = buildRulingMentions(storedRulings, ci.Cards())
+		log.Debug("Found the cards named in rulings", "Cards", len(storedRulingMentions.mentionedBy))
+	}
+	return storedRulingMentions
+}
+
+// rulingsMentioning finds the official rulings on other cards that name the card, numbered as !ruling would show them
+func rulingsMentioning(card *Card) []rulingMention {
+	rmi := currentRulingMentions()
+	if rmi == nil {
+		return nil
+	}
+	mentions := mentionRegex(card)
+	var ret []rulingMention
+	for _, oracleID := range rmi.mentionedBy[card.OracleID] {
+		// Numbered the same way they are for the card itself
+		other := Card{Name: nco(rmi.names[oracleID], "Unknown card"), OracleID: oracleID}
+		other.Rulings, _ = lookupStoredRulings(oracleID)
+		if err := other.sortRulings(); err != nil {
+			continue
+		}
+		rm := rulingMention{card: other.Name}
+		for _, r := range other.officialRulings(-1) {
+			if mentions.MatchString(strings.ReplaceAll(r.Comment, "’", "'")) {
+				rm.numbers = append(rm.numbers, r.Number)
+			}
+		}
+		ret = append(ret, rm)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].card < ret[j].card })
+	return ret
+}
+
+// handleRulingsMentioningQuery lists the cards with rulings that talk about a card, and which of their rulings do
+func handleRulingsMentioningQuery(params *fryatogParams, cardTokens []string) string {
+	rulingMentionRequests.Add(1)
+	if !haveStoredRulings() {
+		return "Rulings aren't loaded yet"
+	}
+	// The other cards' names come from the index
+	if cardIndex.Load().Len() == 0 {
+		return "Card index not loaded yet"
+	}
+	if len(cardTokens) == 0 {
+		return "!rulings-mentioning <cardname>"
+	}
+	card, err := findCard(cardTokens, false, params.source.Named)
+	if err != nil {
+		return "Card not found"
+	}
+	mentions := rulingsMentioning(&card)
+	if len(mentions) == 0 {
+		return fmt.Sprintf("No rulings on other cards mention %s", card.Name)
+	}
+	var ret []string
+	for _, rm := range mentions[:min(len(mentions), rulingMentionsShown)] {
+		ret = append(ret, rm.String())
+	}
+	if len(mentions) > rulingMentionsShown {
+		ret = append(ret, fmt.Sprintf("and %d more", len(mentions)-rulingMentionsShown))
+	}
+	return fmt.Sprintf("Rulings mentioning %s · %s", card.Name, strings.Join(ret, " · "))
+}
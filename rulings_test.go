@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const testBulkRulings = `[
+	{"object": "ruling", "oracle_id": "02090581-61aa-4348-ad57-451be8ee91c2", "source": "wotc", "published_at": "2010-01-01", "comment": "Ponder lets you shuffle."},
+	{"object": "ruling", "oracle_id": "o-brainstorm", "source": "wotc", "published_at": "2009-01-01", "comment": "This isn’t Ponder’s second cousin."},
+	{"object": "ruling", "oracle_id": "o-brainstorm", "source": "wotc", "published_at": "2004-01-01", "comment": "Put back two cards."},
+	{"object": "ruling", "oracle_id": "o-brainstorm", "source": "scryfall", "published_at": "2004-01-01", "comment": "Better than Ponder."},
+	{"object": "ruling", "oracle_id": "o-brainstorm", "source": "wotc", "published_at": "2009-01-01", "comment": "Unlike Ponder, you can't shuffle."},
+	{"object": "ruling", "oracle_id": "o-pondering", "source": "wotc", "published_at": "2009-01-01", "comment": "Pondering isn't a card."}
+]`
+
+func TestStoredRulings(t *testing.T) {
+	rulings, err := buildRulingsStore(strings.NewReader(testBulkRulings))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := len(rulings["o-brainstorm"]); got != 4 {
+		t.Errorf("Incorrect number of rulings -- got %d -- want 4", got)
+	}
+	setStoredRulings(rulings)
+	defer setStoredRulings(nil)
+	oldIndex := cardIndex.Load()
+	cardIndex.Store(nil)
+	defer cardIndex.Store(oldIndex)
+	if got := handleRulingsMentioningQuery(&fryatogParams{source: fakeCardSource{}}, []string{"Ponder"}); got != "Card index not loaded yet" {
+		t.Errorf("Incorrect output without the card index -- got %q", got)
+	}
+	cardIndex.Store(newCardIndex([]Card{{Name: "Brainstorm", OracleID: "o-brainstorm"}, {Name: "Pondering", OracleID: "o-pondering"}, {Name: "Ponder", OracleID: "02090581-61aa-4348-ad57-451be8ee91c2"}}))
+
+	// Every printing gets the stored rulings, without going to the source for them
+	for _, set := range []string{"ICE", "A25"} {
+		c := Card{Name: "Brainstorm", Set: set, OracleID: "o-brainstorm", Rulings: []CardRuling{{Source: "wotc", Comment: "Stale"}}}
+		if got, want := c.getRulings(fakeCardSource{}, 0), "[1] 2004-01-01: Put back two cards.\n[2] 2009-01-01: Unlike Ponder, you can't shuffle.\n[3] 2009-01-01: This isn’t Ponder’s second cousin."; got != want {
+			t.Errorf("Incorrect rulings for %s -- got %q -- want %q", set, got, want)
+		}
+	}
+	noRulings := Card{Name: "Island", OracleID: "o-island"}
+	if got := noRulings.getRulings(fakeCardSource{}, 0); got != "Ruling not found" {
+		t.Errorf("Incorrect output for a card without rulings -- got %q", got)
+	}
+
+	tables := []struct {
+		input  string
+		output string
+	}{
+		{"Ponder", "Rulings mentioning Ponder · Brainstorm (2, 3)"},
+		{"Faithless Looting", "No rulings on other cards mention Faithless Looting"},
+	}
+	for _, table := range tables {
+		if got := handleRulingsMentioningQuery(&fryatogParams{source: fakeCardSource{}}, strings.Fields(table.input)); got != table.output {
+			t.Errorf("Incorrect output for %s -- got %q -- want %q", table.input, got, table.output)
+		}
+	}
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+	log "gopkg.in/inconshreveable/log15.v2"
+)
+
+// How many random cards to try before giving up on finding one with a ruling
+const scheduledRulingTries = 5
+
+// What a schedule can post
+const (
+	scheduleKindCard   = "card"
+	scheduleKindRuling = "ruling"
+)
+
+// scheduleConfig is how a recurring post is set up in the configuration.
+// Spec is a cron line, minute hour day-of-month month day-of-week, in UTC.
+type scheduleConfig struct {
+	Name     string   `json:"Name"`
+	Spec     string   `json:"Spec"`
+	Kind     string   `json:"Kind"`
+	Query    string   `json:"Query"`
+	Title    string   `json:"Title"`
+	Channels []string `json:"Channels"`
+}
+
+// Shorthands for the usual schedules
+var cronAliases = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// The values each cron field can take. Sunday is 0, or 7.
+var cronFieldRanges = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+
+// cronField is the set of values a field matches, one bit each
+type cronField uint64
+
+func (f cronField) has(n int) bool {
+	return f&(1<<uint(n)) != 0
+}
+
+// cronSpec is a parsed cron line
+type cronSpec struct {
+	fields [5]cronField
+	// If both days are restricted, either one matching will do
+	anyDayOfMonth bool
+	anyDayOfWeek  bool
+}
+
+func parseCronField(field string, lo int, hi int) (cronField, error) {
+	var ret cronField
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s < 1 {
+				return 0, fmt.Errorf("Bad step in %q", field)
+			}
+			step = s
+			part = part[:i]
+		}
+		from, to := lo, hi
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			a, errA := strconv.Atoi(part[:strings.Index(part, "-")])
+			b, errB := strconv.Atoi(part[strings.Index(part, "-")+1:])
+			if errA != nil || errB != nil {
+				return 0, fmt.Errorf("Bad range in %q", field)
+			}
+			from, to = a, b
+		default:
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("Bad value in %q", field)
+			}
+			from = n
+			if step == 1 {
+				to = n
+			}
+		}
+		if from < lo || to > hi || from > to {
+			return 0, fmt.Errorf("%q is out of range", field)
+		}
+		for n := from; n <= to; n += step {
+			ret |= 1 << uint(n)
+		}
+	}
+	return ret, nil
+}
+
+// parseCron reads a five field cron line, or one of the @ shorthands
+func parseCron(spec string) (cronSpec, error) {
+	if alias, ok := cronAliases[strings.TrimSpace(spec)]; ok {
+		spec = alias
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return cronSpec{}, fmt.Errorf("A schedule needs five fields, got %q", spec)
+	}
+	var ret cronSpec
+	for i, field := range fields {
+		f, err := parseCronField(field, cronFieldRanges[i][0], cronFieldRanges[i][1])
+		if err != nil {
+			return cronSpec{}, err
+		}
+		ret.fields[i] = f
+	}
+	if ret.fields[4].has(7) {
+		ret.fields[4] |= 1
+	}
+	ret.anyDayOfMonth = fields[2] == "*"
+	ret.anyDayOfWeek = fields[4] == "*"
+	return ret, nil
+}
+
+// matches says whether the schedule is due in the minute t is in
+func (c cronSpec) matches(t time.Time) bool {
+	if !c.fields[0].has(t.Minute()) || !c.fields[1].has(t.Hour()) || !c.fields[3].has(int(t.Month())) {
+		return false
+	}
+	dom := c.fields[2].has(t.Day())
+	dow := c.fields[4].has(int(t.Weekday()))
+	switch {
+	case c.anyDayOfMonth && c.anyDayOfWeek:
+		return true
+	case c.anyDayOfMonth:
+		return dow
+	case c.anyDayOfWeek:
+		return dom
+	}
+	return dom || dow
+}
+
+// next is the first minute after t that the schedule is due, or the zero time if that's more than a year away
+func (c cronSpec) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for end := t.AddDate(1, 0, 1); t.Before(end); t = t.Add(time.Minute) {
+		if c.matches(t) {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// schedule is a recurring post
+type schedule struct {
+	name     string
+	kind     string
+	query    string
+	title    string
+	channels []string
+	spec     cronSpec
+	paused   bool
+	lastRun  time.Time
+}
+
+// scheduler runs the recurring posts
+type scheduler struct {
+	lock      sync.Mutex
+	schedules []*schedule
+	source    CardSource
+	post      func(channel string, message string) error
+	rng       *rand.Rand
+}
+
+func newScheduler(configs []scheduleConfig, source CardSource, post func(channel string, message string) error) (*scheduler, error) {
+	s := &scheduler{source: source, post: post, rng: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	for _, sc := range configs {
+		spec, err := parseCron(sc.Spec)
+		if err != nil {
+			return nil, fmt.Errorf("Schedule %s: %s", sc.Name, err)
+		}
+		kind := strings.ToLower(nco(sc.Kind, scheduleKindCard))
+		if kind != scheduleKindCard && kind != scheduleKindRuling {
+			return nil, fmt.Errorf("Schedule %s: unknown kind %q", sc.Name, sc.Kind)
+		}
+		s.schedules = append(s.schedules, &schedule{name: sc.Name, kind: kind, query: sc.Query, title: sc.Title, channels: sc.Channels, spec: spec})
+	}
+	return s, nil
+}
+
+// find looks a schedule up by name
+func (s *scheduler) find(name string) (*schedule, bool) {
+	for _, sched := range s.schedules {
+		if strings.EqualFold(sched.name, name) {
+			return sched, true
+		}
+	}
+	return nil, false
+}
+
+// due are the schedules that should run now, and haven't already this minute
+func (s *scheduler) due(now time.Time) []*schedule {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	minute := now.Truncate(time.Minute)
+	var ret []*schedule
+	for _, sched := range s.schedules {
+		if sched.paused || !sched.spec.matches(now) || !sched.lastRun.Before(minute) {
+			continue
+		}
+		sched.lastRun = minute
+		ret = append(ret, sched)
+	}
+	return ret
+}
+
+// tick runs whatever's due
+func (s *scheduler) tick(now time.Time) {
+	for _, sched := range s.due(now) {
+		if err := s.run(sched); err != nil {
+			log.Warn("Scheduled post failed", "Schedule", sched.name, "Error", err)
+		}
+	}
+}
+
+// run posts once to each of the schedule's channels, the same card everywhere but formatted for each
+func (s *scheduler) run(sched *schedule) error {
+	scheduledPosts.Add(1)
+	var format func(params *fryatogParams) string
+	switch sched.kind {
+	case scheduleKindRuling:
+		card, ruling, err := s.randomRuling(sched.query)
+		if err != nil {
+			return err
+		}
+		format = func(params *fryatogParams) string {
+			return fmt.Sprintf("%s · %s · %s", sched.heading(params.isIRC), params.bold(card.Name), ruling.formatRuling())
+		}
+	default:
+		card, err := getRandomCard(strings.Fields(sched.query), s.source)
+		if err != nil {
+			return err
+		}
+		format = func(params *fryatogParams) string {
+			return sched.heading(params.isIRC) + " · " + params.formatCardFace(&card, -1)
+		}
+	}
+	var ret error
+	for _, channel := range sched.channels {
+		params := &fryatogParams{isIRC: isIRCChannel(channel), channel: channel, source: s.source}
+		message, _ := splitOverflow(format(params))
+		if err := s.post(channel, message); err != nil {
+			ret = err
+		}
+	}
+	return ret
+}
+
+// randomRuling finds a random card with an official ruling, and picks one of them
+func (s *scheduler) randomRuling(query string) (Card, CardRuling, error) {
+	for i := 0; i < scheduledRulingTries; i++ {
+		card, err := getRandomCard(strings.Fields(query), s.source)
+		if err != nil {
+			return Card{}, CardRuling{}, err
+		}
+		if err := card.fetchRulings(s.source); err != nil {
+			continue
+		}
+		var wotc []CardRuling
+		for _, r := range card.Rulings {
+			if r.Source == "wotc" {
+				wotc = append(wotc, r)
+			}
+		}
+		if len(wotc) > 0 {
+			s.lock.Lock()
+			r := wotc[s.rng.Intn(len(wotc))]
+			s.lock.Unlock()
+			return card, r, nil
+		}
+	}
+	return Card{}, CardRuling{}, fmt.Errorf("No rulings found after %d cards", scheduledRulingTries)
+}
+
+func (sched *schedule) heading(isIRC bool) string {
+	title := sched.title
+	if title == "" {
+		title = "Card of the day"
+		if sched.kind == scheduleKindRuling {
+			title = "Ruling of the week"
+		}
+	}
+	if isIRC {
+		return "\x02" + title + "\x0F"
+	}
+	return "*" + title + "*"
+}
+
+func (sched *schedule) String() string {
+	ret := []string{sched.name, sched.kind, strings.Join(sched.channels, " ")}
+	if sched.paused {
+		ret = append(ret, "paused")
+	} else if next := sched.spec.next(time.Now().UTC()); !next.IsZero() {
+		ret = append(ret, "next "+next.Format("2006-01-02 15:04 UTC"))
+	}
+	return strings.Join(ret, " · ")
+}
+
+// bold is how names are shown in the message's formatting
+func (params *fryatogParams) bold(s string) string {
+	if params.isIRC {
+		return "\x02" + s + "\x0F"
+	}
+	return "*" + s + "*"
+}
+
+// isIRCChannel tells IRC channels from Slack ones by the #
+func isIRCChannel(channel string) bool {
+	return strings.HasPrefix(channel, "#")
+}
+
+// postToChannel sends a scheduled post wherever the channel is
+func postToChannel(channel string, message string) error {
+	if isIRCChannel(channel) {
+		if bot == nil {
+			return fmt.Errorf("Not connected to IRC")
+		}
+		for _, line := range strings.Split(message, "\n") {
+			for _, wrapped := range strings.Split(wordWrap(line, 390), "\n") {
+				bot.Msg(channel, wrapped)
+			}
+		}
+		return nil
+	}
+	// We don't know which workspace the channel is in, so try them all
+	for _, api := range slackClients {
+		if _, _, err := api.PostMessage(channel, slack.MsgOptionText(message, false)); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("Couldn't post to %s", channel)
+}
+
+// handleScheduleCommand deals with the operator commands !schedule list, pause, resume and trigger
+func (s *scheduler) handleScheduleCommand(tokens []string) string {
+	if len(tokens) == 0 || strings.ToLower(tokens[0]) == "list" {
+		if len(s.schedules) == 0 {
+			return "No schedules"
+		}
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		var ret []string
+		for _, sched := range s.schedules {
+			ret = append(ret, sched.String())
+		}
+		return strings.Join(ret, "\n")
+	}
+	if len(tokens) != 2 {
+		return "!schedule [list], or !schedule pause/resume/trigger <name>"
+	}
+	sched, ok := s.find(tokens[1])
+	if !ok {
+		return fmt.Sprintf("No schedule named %s", tokens[1])
+	}
+	switch strings.ToLower(tokens[0]) {
+	case "pause":
+		s.lock.Lock()
+		sched.paused = true
+		s.lock.Unlock()
+		return fmt.Sprintf("Paused %s", sched.name)
+	case "resume", "unpause":
+		s.lock.Lock()
+		sched.paused = false
+		s.lock.Unlock()
+		return fmt.Sprintf("Resumed %s", sched.name)
+	case "trigger", "run":
+		if err := s.run(sched); err != nil {
+			log.Warn("Triggered post failed", "Schedule", sched.name, "Error", err)
+			return fmt.Sprintf("Problem posting %s", sched.name)
+		}
+		return "Done!"
+	}
+	return "!schedule [list], or !schedule pause/resume/trigger <name>"
+}
+
+// runScheduler checks the schedules at the start of each minute
+func runScheduler(s *scheduler) {
+	for {
+		now := time.Now().UTC()
+		time.Sleep(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+		s.tick(time.Now().UTC())
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// rulingsSource is the fake card source, but the random card has a ruling
+type rulingsSource struct {
+	fakeCardSource
+}
+
+func (rulingsSource) Rulings(card *Card) ([]CardRuling, error) {
+	return []CardRuling{
+		{Source: "scryfall", PublishedAt: "2020-01-01", Comment: "Not this one"},
+		{Source: "wotc", PublishedAt: "2021-02-03", Comment: "This one"},
+	}, nil
+}
+
+func TestParseCron(t *testing.T) {
+	// A Wednesday
+	wednesday := time.Date(2026, time.October, 14, 9, 0, 0, 0, time.UTC)
+	tables := []struct {
+		spec    string
+		at      time.Time
+		matches bool
+		next    time.Time
+	}{
+		{"0 9 * * *", wednesday, true, wednesday.AddDate(0, 0, 1)},
+		{"@daily", wednesday, false, time.Date(2026, time.October, 15, 0, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", wednesday, true, wednesday.Add(15 * time.Minute)},
+		{"0 16 * * 1", wednesday, false, time.Date(2026, time.October, 19, 16, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", wednesday, false, time.Date(2026, time.October, 18, 9, 0, 0, 0, time.UTC)},
+		{"0 9 1 * 3", wednesday, true, time.Date(2026, time.October, 21, 9, 0, 0, 0, time.UTC)},
+		{"30 8-10 14,15 10 *", wednesday, false, wednesday.Add(30 * time.Minute)},
+	}
+	for _, table := range tables {
+		spec, err := parseCron(table.spec)
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %v", table.spec, err)
+			continue
+		}
+		if got := spec.matches(table.at); got != table.matches {
+			t.Errorf("Incorrect match for %s -- got %v -- want %v", table.spec, got, table.matches)
+		}
+		if got := spec.next(table.at); !got.Equal(table.next) {
+			t.Errorf("Incorrect next run for %s -- got %v -- want %v", table.spec, got, table.next)
+		}
+	}
+	for _, bad := range []string{"", "* * * *", "60 * * * *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		if _, err := parseCron(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	posted := make(map[string][]string)
+	post := func(channel string, message string) error {
+		posted[channel] = append(posted[channel], message)
+		return nil
+	}
+	s, err := newScheduler([]scheduleConfig{
+		{Name: "cotd", Spec: "0 9 * * *", Channels: []string{"#judges", "C12345"}},
+		{Name: "study", Spec: "0 16 * * 1", Kind: "ruling", Channels: []string{"#judges"}},
+	}, fakeCardSource{}, post)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	nine := time.Date(2026, time.October, 14, 9, 0, 0, 0, time.UTC)
+	s.tick(nine)
+	s.tick(nine.Add(30 * time.Second))
+	if len(posted["#judges"]) != 1 || len(posted["C12345"]) != 1 {
+		t.Fatalf("Incorrect posts -- got %v", posted)
+	}
+	if got, want := posted["#judges"][0], "\x02Card of the day\x0F · \x02RANDOMCARD\x0F ·  · · RANDOMTESTSET-R · "; got != want {
+		t.Errorf("Incorrect IRC post -- got %q -- want %q", got, want)
+	}
+	if got, want := posted["C12345"][0][:len("*Card of the day* · ")], "*Card of the day* · "; got != want {
+		t.Errorf("Incorrect Slack post -- got %q", posted["C12345"][0])
+	}
+
+	if got := s.handleScheduleCommand([]string{"pause", "COTD"}); got != "Paused cotd" {
+		t.Errorf("Incorrect pause -- got %q", got)
+	}
+	s.tick(nine.AddDate(0, 0, 1))
+	if len(posted["#judges"]) != 1 {
+		t.Errorf("A paused schedule posted -- got %v", posted["#judges"])
+	}
+	if got := s.handleScheduleCommand([]string{"resume", "cotd"}); got != "Resumed cotd" {
+		t.Errorf("Incorrect resume -- got %q", got)
+	}
+	if got := s.handleScheduleCommand([]string{"trigger", "cotd"}); got != "Done!" || len(posted["#judges"]) != 2 {
+		t.Errorf("Incorrect trigger -- got %q and %d posts", got, len(posted["#judges"]))
+	}
+	// The fake source has no rulings
+	if got := s.handleScheduleCommand([]string{"trigger", "study"}); got != "Problem posting study" {
+		t.Errorf("Incorrect trigger -- got %q", got)
+	}
+	if got := s.handleScheduleCommand([]string{"trigger", "nope"}); got != "No schedule named nope" {
+		t.Errorf("Incorrect output for a missing schedule -- got %q", got)
+	}
+	if got := s.handleScheduleCommand(nil); got != fmt.Sprintf("cotd · card · #judges C12345 · next %s\nstudy · ruling · #judges · next %s",
+		s.schedules[0].spec.next(time.Now().UTC()).Format("2006-01-02 15:04 UTC"), s.schedules[1].spec.next(time.Now().UTC()).Format("2006-01-02 15:04 UTC")) {
+		t.Errorf("Incorrect list -- got %q", got)
+	}
+
+	s.source = rulingsSource{}
+	if got := s.handleScheduleCommand([]string{"trigger", "study"}); got != "Done!" {
+		t.Errorf("Incorrect trigger -- got %q", got)
+	}
+	if got, want := posted["#judges"][2], "\x02Ruling of the week\x0F · \x02RANDOMCARD\x0F · 2021-02-03: This one"; got != want {
+		t.Errorf("Incorrect ruling post -- got %q -- want %q", got, want)
+	}
+
+	if _, err := newScheduler([]scheduleConfig{{Name: "bad", Spec: "0 9 * * *", Kind: "meme"}}, fakeCardSource{}, post); err == nil {
+		t.Errorf("Expected an error for an unknown kind")
+	}
+}
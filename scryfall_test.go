@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// newFakeScryfall starts a stand-in for api.scryfall.com that serves the cards in test_data,
+// and returns a CardSource pointed at it.
+// Links back to Scryfall in anything it serves are rewritten to point at the stand-in instead.
+func newFakeScryfall(t *testing.T) *scryfallSource {
+	t.Helper()
+	byID := make(map[string]string)
+	byName := make(map[string]string)
+	byOracleID := make(map[string]string)
+	for _, path := range RealCards {
+		fi, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("Unable to open %v", path)
+		}
+		var c Card
+		err = json.NewDecoder(fi).Decode(&c)
+		fi.Close()
+		if err != nil {
+			t.Fatalf("Something went wrong parsing %v: %s", path, err)
+		}
+		byID[c.ID] = normaliseCardName(c.Name)
+		byOracleID[c.OracleID] = normaliseCardName(c.Name)
+		if c.Lang == "en" {
+			byName[normaliseCardName(c.Name)] = path
+			for _, cf := range c.CardFaces {
+				byName[normaliseCardName(cf.Name)] = path
+			}
+		}
+	}
+
+	// Every printing we know about, as Scryfall sent it, by set and collector number
+	byPrinting := make(map[string][]byte)
+	printingFiles, _ := filepath.Glob("test_data/*-printings*.json")
+	for _, path := range printingFiles {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Unable to open %v", path)
+		}
+		var page struct {
+			Data []json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(b, &page); err != nil {
+			t.Fatalf("Something went wrong parsing %v: %s", path, err)
+		}
+		for _, raw := range page.Data {
+			var c Card
+			if err := json.Unmarshal(raw, &c); err != nil {
+				t.Fatalf("Something went wrong parsing %v: %s", path, err)
+			}
+			byPrinting[c.Set+"|"+c.CollectorNumber] = raw
+			if _, ok := byPrinting[c.Set+"|"+normaliseCardName(c.Name)]; !ok {
+				byPrinting[c.Set+"|"+normaliseCardName(c.Name)] = raw
+			}
+		}
+	}
+
+	// Tokens, by ID and as they'd turn up in a search
+	tokenFiles, _ := filepath.Glob("test_data/*-token.json")
+	tokensByID := make(map[string]string)
+	var tokens []Card
+	for _, path := range tokenFiles {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Unable to open %v", path)
+		}
+		var c Card
+		if err := json.Unmarshal(b, &c); err != nil {
+			t.Fatalf("Something went wrong parsing %v: %s", path, err)
+		}
+		tokensByID[c.ID] = path
+		tokens = append(tokens, c)
+	}
+
+	var ts *httptest.Server
+	serveFile := func(w http.ResponseWriter, path string) {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"object":"error","status":404,"details":"%s"}`, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(strings.ReplaceAll(string(b), scryfallAPIURL, ts.URL)))
+	}
+	servePrinting := func(w http.ResponseWriter, key string) {
+		b, ok := byPrinting[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"object":"error","status":404,"details":"No card found for %s"}`, key)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(strings.ReplaceAll(string(b), scryfallAPIURL, ts.URL)))
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /cards/named", func(w http.ResponseWriter, r *http.Request) {
+		fuzzy := r.URL.Query().Get("fuzzy")
+		if set := r.URL.Query().Get("set"); set != "" {
+			servePrinting(w, set+"|"+normaliseCardName(fuzzy))
+			return
+		}
+		if path, ok := RealCards[fuzzy]; ok {
+			serveFile(w, path)
+			return
+		}
+		serveFile(w, byName[normaliseCardName(fuzzy)])
+	})
+	mux.HandleFunc("GET /cards/search", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query().Get("q")
+		if strings.HasPrefix(q, "t:token ") {
+			var csr CardSearchResult
+			for _, c := range tokens {
+				if strings.Contains(q, `!"`+c.Name+`"`) && (!strings.Contains(q, "pow=") || strings.HasSuffix(q, fmt.Sprintf("pow=%s tou=%s", c.Power, c.Toughness))) {
+					csr.Data = append(csr.Data, c)
+				}
+			}
+			if len(csr.Data) == 0 {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"object":"error","status":404,"details":"Your query didn't match any cards."}`)
+				return
+			}
+			csr.TotalCards = len(csr.Data)
+			json.NewEncoder(w).Encode(csr)
+			return
+		}
+		if set, ok := strings.CutPrefix(q, "e:"); ok {
+			if page := r.URL.Query().Get("page"); page != "" && page != "1" {
+				serveFile(w, "test_data/"+set+"-set-"+page+".json")
+				return
+			}
+			serveFile(w, "test_data/"+set+"-set.json")
+			return
+		}
+		if oracleID, ok := strings.CutPrefix(q, "oracleid:"); ok {
+			if r.URL.Query().Get("include_multilingual") == "true" {
+				serveFile(w, "test_data/"+byOracleID[oracleID]+"-langs.json")
+				return
+			}
+			if page := r.URL.Query().Get("page"); page != "" && page != "1" {
+				serveFile(w, "test_data/"+byOracleID[oracleID]+"-printings-"+page+".json")
+				return
+			}
+			serveFile(w, "test_data/"+byOracleID[oracleID]+"-printings.json")
+			return
+		}
+		serveFile(w, "test_data/"+normaliseCardName(q)+"-searchresult.json")
+	})
+	mux.HandleFunc("GET /sets/{code}", func(w http.ResponseWriter, r *http.Request) {
+		serveFile(w, "test_data/"+r.PathValue("code")+"-setinfo.json")
+	})
+	mux.HandleFunc("GET /cards/random", func(w http.ResponseWriter, r *http.Request) {
+		serveFile(w, RealCards["Ponder"])
+	})
+	mux.HandleFunc("GET /cards/{id}/rulings", func(w http.ResponseWriter, r *http.Request) {
+		serveFile(w, "test_data/"+byID[r.PathValue("id")]+"-rulings.json")
+	})
+	mux.HandleFunc("GET /cards/{id}", func(w http.ResponseWriter, r *http.Request) {
+		serveFile(w, tokensByID[r.PathValue("id")])
+	})
+	mux.HandleFunc("GET /cards/{set}/{number}", func(w http.ResponseWriter, r *http.Request) {
+		servePrinting(w, r.PathValue("set")+"|"+r.PathValue("number"))
+	})
+	ts = httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return newScryfallSource(ts.URL)
+}
+
+func TestScryfallSource(t *testing.T) {
+	var err error
+	nameToCardCache, err = lru.NewARC(2048)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	source := newFakeScryfall(t)
+
+	card, err := source.Named("Faithless Looting", false)
+	if err != nil || card.Name != "Faithless Looting" {
+		t.Fatalf("Incorrect card -- got %v %v", card.Name, err)
+	}
+	if len(card.Metadata.PreviousPrintings) != 8 {
+		t.Errorf("Incorrect previous printings -- got %v", card.Metadata.PreviousPrintings)
+	}
+	cached, err := checkCacheForCard("faithlesslooting")
+	if err != nil || !reflect.DeepEqual(cached, card) {
+		t.Errorf("Card wasn't cached -- got %v %v", cached.Name, err)
+	}
+	// Every page of printings counts, not just the first
+	if card, err = source.Named("Disenchant", false); err != nil || len(card.Metadata.PreviousPrintings) != 42 {
+		t.Errorf("Incorrect previous printings -- got %d %v", len(card.Metadata.PreviousPrintings), err)
+	}
+
+	if card, err = source.Named("Erebos' Titan", false); err != nil || card.Lang != "en" {
+		t.Errorf("Expected the English card -- got %v %v", card.Lang, err)
+	}
+	if card, err = source.Fuzzy("Erebos' Titan", true); err != nil || card.Lang != "de" {
+		t.Errorf("Expected the German card -- got %v %v", card.Lang, err)
+	}
+	if _, err = source.Named("Lightning Bolt", false); err == nil {
+		t.Errorf("Expected no card to be found")
+	}
+
+	card, err = source.Fuzzy("Ixidron", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rulings, err := source.Rulings(&card)
+	if err != nil || len(rulings) != 5 {
+		t.Errorf("Incorrect rulings -- got %d %v", len(rulings), err)
+	}
+	if _, err = source.Rulings(&TestCardWithNoRulings); err == nil {
+		t.Errorf("Expected an error for a card without rulings")
+	}
+
+	cards, err := source.Search([]string{"Search", "for", "Azcanta"})
+	if err != nil || len(cards) != 1 || cards[0].Name != "Search for Azcanta // Azcanta, the Sunken Ruin" {
+		t.Errorf("Incorrect search results -- got %v %v", cards, err)
+	}
+	if _, err = source.Search([]string{"o:nothing"}); err == nil {
+		t.Errorf("Expected no search results")
+	}
+
+	if card, err = source.Random(nil); err != nil || card.Name != "Ponder" {
+		t.Errorf("Incorrect random card -- got %v %v", card.Name, err)
+	}
+}
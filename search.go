@@ -0,0 +1,603 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// cardPredicate decides whether a card matches one clause of a search query
+type cardPredicate func(c *Card) bool
+
+// searchToken is a single lexical item of a search query
+type searchToken struct {
+	text string
+	// Started with a quote, so never a keyword or operator
+	quoted bool
+}
+
+// searchQueryError describes an invalid expression in a search query
+type searchQueryError struct {
+	expression string
+	reason     string
+}
+
+func (e *searchQueryError) Error() string {
+	return fmt.Sprintf("Invalid expression “%s” was ignored. %s", e.expression, e.reason)
+}
+
+// errUnsupportedQuery means the query is probably fine, but we can't evaluate it locally
+var errUnsupportedQuery = errors.New("Query not supported locally")
+
+var (
+	searchTermRegex = regexp.MustCompile(`^([a-zA-Z]+)(:|!=|<=|>=|=|<|>)(.*)$`)
+
+	searchColors = map[rune]int{'w': 1, 'u': 2, 'b': 4, 'r': 8, 'g': 16}
+	// Colour words and nicknames Scryfall understands
+	searchColorNames = map[string]string{
+		"white": "w", "blue": "u", "black": "b", "red": "r", "green": "g",
+		"azorius": "wu", "dimir": "ub", "rakdos": "br", "gruul": "rg", "selesnya": "gw",
+		"orzhov": "wb", "izzet": "ur", "golgari": "bg", "boros": "rw", "simic": "gu",
+		"bant": "gwu", "esper": "wub", "grixis": "ubr", "jund": "brg", "naya": "rgw",
+		"abzan": "wbg", "jeskai": "urw", "sultai": "bgu", "mardu": "rwb", "temur": "gur",
+	}
+	searchRarities = map[string]int{
+		"c": 0, "common": 0, "u": 1, "uncommon": 1, "r": 2, "rare": 2,
+		"s": 3, "special": 3, "m": 4, "mythic": 4, "b": 5, "bonus": 5,
+	}
+	// Layouts Scryfall leaves out of searches unless asked for extras
+	searchExtraLayouts = []string{"token", "double_faced_token", "emblem", "art_series", "planar", "scheme", "vanguard"}
+)
+
+func tokeniseSearchQuery(query string) []searchToken {
+	var tokens []searchToken
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, searchToken{text: string(r)})
+			i++
+		case r == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]):
+			tokens = append(tokens, searchToken{text: "-"})
+			i++
+		default:
+			var sb strings.Builder
+			quoted := r == '"'
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '(' && runes[i] != ')' {
+				if runes[i] == '"' {
+					// Read up to the closing quote, spaces and all
+					i++
+					for i < len(runes) && runes[i] != '"' {
+						sb.WriteRune(runes[i])
+						i++
+					}
+					i++
+					continue
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			tokens = append(tokens, searchToken{text: sb.String(), quoted: quoted})
+		}
+	}
+	return tokens
+}
+
+// searchParser is a recursive descent parser over Scryfall search syntax:
+//
+//	or    := and ("or" and)*
+//	and   := unary ("and"? unary)*
+//	unary := "-" unary | "(" or ")" | term
+//
+// Like Scryfall, a term that doesn't make sense is left out with a warning, rather than failing the whole query.
+// A clause whose terms were all left out parses to a nil predicate.
+type searchParser struct {
+	tokens   []searchToken
+	pos      int
+	warnings []string
+}
+
+func (p *searchParser) peek() (searchToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return searchToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *searchParser) peekKeyword(keyword string) bool {
+	t, ok := p.peek()
+	return ok && !t.quoted && strings.EqualFold(t.text, keyword)
+}
+
+func (p *searchParser) parseOr() (cardPredicate, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	var clauses []cardPredicate
+	if left != nil {
+		clauses = append(clauses, left)
+	}
+	for p.peekKeyword("or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if right != nil {
+			clauses = append(clauses, right)
+		}
+	}
+	switch len(clauses) {
+	case 0:
+		return nil, nil
+	case 1:
+		return clauses[0], nil
+	}
+	return func(c *Card) bool {
+		for _, f := range clauses {
+			if f(c) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+func (p *searchParser) parseAnd() (cardPredicate, error) {
+	var clauses []cardPredicate
+	parsed := 0
+	for {
+		t, ok := p.peek()
+		if !ok || (t.text == ")" && !t.quoted) || p.peekKeyword("or") {
+			break
+		}
+		if p.peekKeyword("and") {
+			p.pos++
+			continue
+		}
+		f, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		parsed++
+		if f != nil {
+			clauses = append(clauses, f)
+		}
+	}
+	if parsed == 0 {
+		return nil, &searchQueryError{p.context(), "Expected an expression."}
+	}
+	switch len(clauses) {
+	case 0:
+		return nil, nil
+	case 1:
+		return clauses[0], nil
+	}
+	return func(c *Card) bool {
+		for _, f := range clauses {
+			if !f(c) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func (p *searchParser) parseUnary() (cardPredicate, error) {
+	t, _ := p.peek()
+	if !t.quoted {
+		switch t.text {
+		case "-":
+			p.pos++
+			if _, ok := p.peek(); !ok {
+				return nil, &searchQueryError{"-", "Nothing to negate."}
+			}
+			f, err := p.parseUnary()
+			if err != nil || f == nil {
+				return nil, err
+			}
+			return func(c *Card) bool { return !f(c) }, nil
+		case "(":
+			p.pos++
+			f, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if t, ok := p.peek(); !ok || t.text != ")" {
+				return nil, &searchQueryError{"(", "Unbalanced parentheses."}
+			}
+			p.pos++
+			return f, nil
+		}
+	}
+	p.pos++
+	f, err := parseSearchTerm(t)
+	var qe *searchQueryError
+	if errors.As(err, &qe) {
+		p.warnings = append(p.warnings, qe.Error())
+		return nil, nil
+	}
+	return f, err
+}
+
+// context gives a readable snippet of where the parser gave up
+func (p *searchParser) context() string {
+	if t, ok := p.peek(); ok {
+		return t.text
+	}
+	if len(p.tokens) > 0 {
+		return p.tokens[len(p.tokens)-1].text
+	}
+	return ""
+}
+
+// parseSearchQuery turns a Scryfall-syntax query into a predicate over cards, and warnings about any terms it left out.
+// An empty query matches everything, and a query whose terms were all left out has a nil predicate.
+func parseSearchQuery(query string) (cardPredicate, []string, error) {
+	p := &searchParser{tokens: tokeniseSearchQuery(query)}
+	if len(p.tokens) == 0 {
+		return func(c *Card) bool { return true }, nil, nil
+	}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, nil, &searchQueryError{p.context(), "Unbalanced parentheses."}
+	}
+	return f, p.warnings, nil
+}
+
+func parseSearchTerm(t searchToken) (cardPredicate, error) {
+	// Exact name
+	if !t.quoted && strings.HasPrefix(t.text, "!") {
+		want := normaliseCardName(t.text[1:])
+		return func(c *Card) bool {
+			return normaliseCardName(c.Name) == want || faceMatches(c, func(cf CardFace) bool { return normaliseCardName(cf.Name) == want })
+		}, nil
+	}
+	m := searchTermRegex.FindStringSubmatch(t.text)
+	if t.quoted || m == nil {
+		// Bare words are a name search
+		want := strings.ToLower(t.text)
+		return func(c *Card) bool { return strings.Contains(strings.ToLower(c.Name), want) }, nil
+	}
+	key, op, value := strings.ToLower(m[1]), m[2], m[3]
+	lvalue := strings.ToLower(value)
+	if value == "" {
+		return nil, &searchQueryError{t.text, "Expected a value."}
+	}
+	switch key {
+	case "t", "type":
+		return textPredicate(op, t.text, lvalue, func(cc CommonCard, _ string) string { return cc.TypeLine })
+	case "o", "oracle":
+		return textPredicate(op, t.text, lvalue, func(cc CommonCard, _ string) string { return cc.OracleText })
+	case "name":
+		return textPredicate(op, t.text, lvalue, func(_ CommonCard, name string) string { return name })
+	case "c", "color":
+		return colorPredicate(op, ">=", t.text, lvalue, func(c *Card) []string { return cardColors(c) })
+	case "id", "identity", "ci":
+		return colorPredicate(op, "<=", t.text, lvalue, func(c *Card) []string { return c.ColorIdentity })
+	case "mv", "cmc", "manavalue":
+		if lvalue == "even" || lvalue == "odd" {
+			rem := 0.0
+			if lvalue == "odd" {
+				rem = 1
+			}
+			return func(c *Card) bool { return math.Mod(float64(c.Cmc), 2) == rem }, nil
+		}
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, &searchQueryError{t.text, fmt.Sprintf("“%s” is not a number.", value)}
+		}
+		return func(c *Card) bool { return compareNumbers(float64(c.Cmc), op, n) }, nil
+	case "pow", "power", "tou", "toughness", "loy", "loyalty":
+		return statPredicate(key, op, t.text, lvalue)
+	case "r", "rarity":
+		want, ok := searchRarities[lvalue]
+		if !ok {
+			return nil, &searchQueryError{t.text, fmt.Sprintf("Unknown rarity “%s”.", value)}
+		}
+		// One printing of each card has one of its rarities, so only Scryfall knows the rest
+		if !bulkDataHasEveryPrinting() {
+			return nil, errUnsupportedQuery
+		}
+		return func(c *Card) bool {
+			got, ok := searchRarities[c.Rarity]
+			return ok && compareNumbers(float64(got), op, float64(want))
+		}, nil
+	case "s", "set", "e", "edition":
+		if op != ":" && op != "=" {
+			return nil, &searchQueryError{t.text, "Sets can only be compared with : or =."}
+		}
+		if !bulkDataHasEveryPrinting() {
+			return nil, errUnsupportedQuery
+		}
+		return func(c *Card) bool { return c.Set == lvalue }, nil
+	case "f", "format", "legal", "banned", "restricted":
+		if op != ":" && op != "=" {
+			return nil, &searchQueryError{t.text, "Formats can only be compared with : or =."}
+		}
+		return func(c *Card) bool {
+			l := c.legality(lvalue)
+			switch key {
+			case "banned":
+				return l == "banned"
+			case "restricted":
+				return l == "restricted"
+			}
+			return l == "legal" || l == "restricted"
+		}, nil
+	case "is", "not":
+		if op != ":" && op != "=" {
+			return nil, &searchQueryError{t.text, "Properties can only be checked with :."}
+		}
+		f, err := isPredicate(lvalue)
+		if err != nil {
+			return nil, err
+		}
+		if key == "not" {
+			return func(c *Card) bool { return !f(c) }, nil
+		}
+		return f, nil
+	}
+	return nil, errUnsupportedQuery
+}
+
+// faceMatches checks each face of a multi-faced card
+func faceMatches(c *Card, f func(cf CardFace) bool) bool {
+	for _, cf := range c.CardFaces {
+		if f(cf) {
+			return true
+		}
+	}
+	return false
+}
+
+func textPredicate(op, expression, value string, field func(cc CommonCard, name string) string) (cardPredicate, error) {
+	if op != ":" && op != "=" {
+		return nil, &searchQueryError{expression, "Text can only be searched with :."}
+	}
+	// ~ stands in for the card's own name
+	matches := func(cc CommonCard, name string) bool {
+		return strings.Contains(strings.ToLower(field(cc, name)), strings.Replace(value, "~", strings.ToLower(name), -1))
+	}
+	return func(c *Card) bool {
+		return matches(c.CommonCard, c.Name) || faceMatches(c, func(cf CardFace) bool { return matches(cf.CommonCard, cf.Name) })
+	}, nil
+}
+
+func cardColors(c *Card) []string {
+	if len(c.Colors) > 0 || len(c.CardFaces) == 0 {
+		return c.Colors
+	}
+	// DFCs only have colours on their faces
+	return c.CardFaces[0].Colors
+}
+
+func colorMask(colors []string) int {
+	var mask int
+	for _, s := range colors {
+		for _, r := range strings.ToLower(s) {
+			mask |= searchColors[r]
+		}
+	}
+	return mask
+}
+
+func colorPredicate(op, defaultOp, expression, value string, colors func(c *Card) []string) (cardPredicate, error) {
+	if op == ":" {
+		op = defaultOp
+	}
+	if value == "m" || value == "multicolor" {
+		return func(c *Card) bool { return len(colors(c)) > 1 }, nil
+	}
+	if value == "c" || value == "colorless" {
+		// Colourless is the empty set, so "at least no colours" would match everything
+		if op == ">=" {
+			op = "="
+		}
+		value = ""
+	}
+	if name, ok := searchColorNames[value]; ok {
+		value = name
+	}
+	var want int
+	for _, r := range value {
+		bit, ok := searchColors[r]
+		if !ok {
+			return nil, &searchQueryError{expression, fmt.Sprintf("Unknown color “%s”.", value)}
+		}
+		want |= bit
+	}
+	return func(c *Card) bool {
+		got := colorMask(colors(c))
+		switch op {
+		case "=":
+			return got == want
+		case "!=":
+			return got != want
+		case ">=":
+			return got&want == want
+		case ">":
+			return got&want == want && got != want
+		case "<=":
+			return got&^want == 0
+		case "<":
+			return got&^want == 0 && got != want
+		}
+		return false
+	}, nil
+}
+
+// parseStat reads a power/toughness/loyalty value, where * counts as zero
+func parseStat(s string) (float64, bool) {
+	if s == "" {
+		return 0, false
+	}
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "*"), "+")
+	if s == "" {
+		return 0, true
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	return n, err == nil
+}
+
+func statOf(cc CommonCard, key string) string {
+	switch key {
+	case "pow", "power":
+		return cc.Power
+	case "tou", "toughness":
+		return cc.Toughness
+	}
+	return cc.Loyalty
+}
+
+func statPredicate(key, op, expression, value string) (cardPredicate, error) {
+	// Compare against another stat of the same card, e.g. pow>tou
+	switch value {
+	case "pow", "power", "tou", "toughness", "loy", "loyalty":
+		other := value
+		cmp := func(cc CommonCard) bool {
+			a, ok := parseStat(statOf(cc, key))
+			b, ok2 := parseStat(statOf(cc, other))
+			return ok && ok2 && compareNumbers(a, op, b)
+		}
+		return func(c *Card) bool {
+			return cmp(c.CommonCard) || faceMatches(c, func(cf CardFace) bool { return cmp(cf.CommonCard) })
+		}, nil
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, &searchQueryError{expression, fmt.Sprintf("“%s” is not a number.", value)}
+	}
+	cmp := func(cc CommonCard) bool {
+		got, ok := parseStat(statOf(cc, key))
+		return ok && compareNumbers(got, op, n)
+	}
+	return func(c *Card) bool {
+		return cmp(c.CommonCard) || faceMatches(c, func(cf CardFace) bool { return cmp(cf.CommonCard) })
+	}, nil
+}
+
+func compareNumbers(got float64, op string, want float64) bool {
+	switch op {
+	case ":", "=":
+		return got == want
+	case "!=":
+		return got != want
+	case "<":
+		return got < want
+	case "<=":
+		return got <= want
+	case ">":
+		return got > want
+	case ">=":
+		return got >= want
+	}
+	return false
+}
+
+func frontTypeLine(c *Card) string {
+	return strings.SplitN(c.TypeLine, " // ", 2)[0]
+}
+
+func isPredicate(value string) (cardPredicate, error) {
+	switch value {
+	case "reserved":
+		return func(c *Card) bool { return c.Reserved }, nil
+	case "promo":
+		return func(c *Card) bool { return c.Promo }, nil
+	case "digital":
+		return func(c *Card) bool { return c.Digital }, nil
+	case "reprint":
+		return func(c *Card) bool { return c.Reprint }, nil
+	case "fullart", "full":
+		return func(c *Card) bool { return c.FullArt }, nil
+	case "funny":
+		return func(c *Card) bool { return c.SetType == "funny" }, nil
+	case "split", "flip", "transform", "meld", "adventure", "leveler", "saga":
+		return func(c *Card) bool { return c.Layout == value }, nil
+	case "mdfc":
+		return func(c *Card) bool { return c.Layout == "modal_dfc" }, nil
+	case "dfc":
+		return func(c *Card) bool { return isDfc(c) || c.Layout == "meld" }, nil
+	case "permanent":
+		return func(c *Card) bool {
+			tl := frontTypeLine(c)
+			return !strings.Contains(tl, "Instant") && !strings.Contains(tl, "Sorcery")
+		}, nil
+	case "spell":
+		return func(c *Card) bool { return !strings.Contains(frontTypeLine(c), "Land") }, nil
+	case "historic":
+		return func(c *Card) bool {
+			tl := frontTypeLine(c)
+			return strings.Contains(tl, "Legendary") || strings.Contains(tl, "Artifact") || strings.Contains(tl, "Saga")
+		}, nil
+	case "vanilla":
+		return func(c *Card) bool {
+			return strings.Contains(c.TypeLine, "Creature") && c.OracleText == "" && len(c.CardFaces) == 0
+		}, nil
+	case "commander":
+		return func(c *Card) bool {
+			tl := frontTypeLine(c)
+			return (strings.Contains(tl, "Legendary") && strings.Contains(tl, "Creature")) || strings.Contains(c.OracleText, "can be your commander")
+		}, nil
+	}
+	return nil, errUnsupportedQuery
+}
+
+// legality returns the card's status in the given format, e.g. "legal" or "banned"
+func (card *Card) legality(format string) string {
+	return card.Legalities[format]
+}
+
+func isExtraCard(c *Card) bool {
+	return stringSliceContains(searchExtraLayouts, c.Layout)
+}
+
+// searchLocalCards evaluates a Scryfall query against the local card index,
+// returning the result in the same shape as the /cards/search API.
+// Invalid queries come back as a 400-style result; queries we can't evaluate return errUnsupportedQuery.
+func searchLocalCards(query string) (CardSearchResult, error) {
+	localSearchRequests.Add(1)
+	ci := cardIndex.Load()
+	if ci.Len() == 0 {
+		return CardSearchResult{}, fmt.Errorf("Card index is empty")
+	}
+	matches, warnings, err := parseSearchQuery(query)
+	if err != nil {
+		var qe *searchQueryError
+		if errors.As(err, &qe) {
+			return CardSearchResult{Object: "error", Status: 400, Details: "All of your terms were ignored.", Warnings: []string{qe.Error()}}, nil
+		}
+		return CardSearchResult{}, err
+	}
+	if matches == nil {
+		return CardSearchResult{Object: "error", Status: 400, Details: "All of your terms were ignored.", Warnings: warnings}, nil
+	}
+	csr := CardSearchResult{Object: "list", Warnings: warnings}
+	for _, c := range ci.Cards() {
+		if isExtraCard(&c) || !matches(&c) {
+			continue
+		}
+		csr.Data = append(csr.Data, c)
+	}
+	sort.Slice(csr.Data, func(i, j int) bool { return csr.Data[i].Name < csr.Data[j].Name })
+	csr.TotalCards = len(csr.Data)
+	return csr, nil
+}
+
+// searchResultError turns an unsuccessful search response into a message for the user
+func searchResultError(csr CardSearchResult) error {
+	return fmt.Errorf("%v (%v)", csr.Details, strings.Join(csr.Warnings, " "))
+}
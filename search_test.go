@@ -0,0 +1,158 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+func TestLocalSearch(t *testing.T) {
+	ci, err := loadCardIndex(testBulkDataFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cardIndex.Store(ci)
+	defer cardIndex.Store(nil)
+	// Pretend the test data has every printing, so sets and rarities are searched too
+	conf.BulkDataType = "default-cards"
+	defer func() { conf.BulkDataType = "" }()
+	tables := []struct {
+		query  string
+		output []string
+	}{
+		{"t:planeswalker", []string{"Arlinn Kord // Arlinn, Embraced by the Moon", "Jace, Vryn's Prodigy // Jace, Telepath Unbound", "Jace, the Mind Sculptor", "Nicol Bolas, the Ravager // Nicol Bolas, the Arisen", "Ral, Storm Conduit"}},
+		{"type:sorcery c:u", []string{"Consign // Oblivion", "Ponder"}},
+		{"(t:instant OR t:sorcery) c:r", []string{"Claim // Fame", "Expansion // Explosion", "Faithless Looting"}},
+		{"id<=g mv=0", []string{"Crashing Footfalls", "Dryad Arbor"}},
+		{"id:g mv=0", []string{"Crashing Footfalls", "Dryad Arbor"}},
+		{"c=ub", []string{"Consign // Oblivion"}},
+		{"c:c t:artifact", []string{"Fleetwheel Cruiser", "Handy Dandy Clone Machine", "Tawnos's Coffin"}},
+		{"c:m c:rakdos", []string{"Claim // Fame", "Mairsil, the Pretender", "Nicol Bolas, the Ravager // Nicol Bolas, the Arisen"}},
+		{"pow>tou", []string{"Fleetwheel Cruiser", "Wicked Guardian"}},
+		{"pow>=5 -t:vehicle", []string{"Erebos's Titan"}},
+		{"r:mythic s:uma", []string{"Tarmogoyf"}},
+		{"r>rare t:instant", []string{"Ancestral Recall"}},
+		{"f:vintage -f:legacy", []string{"Ancestral Recall"}},
+		{"banned:vintage", []string{"Shahrazad"}},
+		{"is:reserved", []string{"Ancestral Recall", "Shahrazad", "Tawnos's Coffin"}},
+		{"is:split o:aftermath", []string{"Claim // Fame", "Consign // Oblivion"}},
+		{"o:\"draw a card\" is:transform", []string{"Jace, Vryn's Prodigy // Jace, Telepath Unbound"}},
+		{"o:\"~ deals 1 damage\"", []string{"Ral, Storm Conduit", "Thermo-Alchemist"}},
+		{"!\"ponder\"", []string{"Ponder"}},
+		{"!explosion", []string{"Expansion // Explosion"}},
+		{"jace", []string{"Jace, Vryn's Prodigy // Jace, Telepath Unbound", "Jace, the Mind Sculptor"}},
+		{"\"kord\"", []string{"Arlinn Kord // Arlinn, Embraced by the Moon"}},
+		{"mv=3 t:creature", []string{"Kindly Ancestor // Ancestor's Embrace"}},
+		{"t:creature mv>5", nil},
+	}
+	for _, table := range tables {
+		csr, err := searchLocalCards(table.query)
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %v", table.query, err)
+			continue
+		}
+		if csr.Status != 0 {
+			t.Errorf("Unexpected bad request for %s: %v", table.query, searchResultError(csr))
+		}
+		var got []string
+		for _, c := range csr.Data {
+			got = append(got, c.Name)
+		}
+		if !reflect.DeepEqual(got, table.output) || csr.TotalCards != len(table.output) {
+			t.Errorf("Incorrect output for %s -- got %q -- want %q", table.query, got, table.output)
+		}
+	}
+}
+
+func TestLocalSearchErrors(t *testing.T) {
+	ci, err := loadCardIndex(testBulkDataFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cardIndex.Store(ci)
+	defer cardIndex.Store(nil)
+	tables := []struct {
+		query       string
+		unsupported bool
+		output      string
+	}{
+		{"t:creature (c:u", false, "All of your terms were ignored. (Invalid expression “(” was ignored. Unbalanced parentheses.)"},
+		{"t:creature)", false, "All of your terms were ignored. (Invalid expression “)” was ignored. Unbalanced parentheses.)"},
+		{"mv>abc", false, "All of your terms were ignored. (Invalid expression “mv>abc” was ignored. “abc” is not a number.)"},
+		{"c:xyz", false, "All of your terms were ignored. (Invalid expression “c:xyz” was ignored. Unknown color “xyz”.)"},
+		{"r:shiny", false, "All of your terms were ignored. (Invalid expression “r:shiny” was ignored. Unknown rarity “shiny”.)"},
+		{"t:", false, "All of your terms were ignored. (Invalid expression “t:” was ignored. Expected a value.)"},
+		{"or t:creature", false, "All of your terms were ignored. (Invalid expression “or” was ignored. Expected an expression.)"},
+		{"mv>abc -c:xyz", false, "All of your terms were ignored. (Invalid expression “mv>abc” was ignored. “abc” is not a number. Invalid expression “c:xyz” was ignored. Unknown color “xyz”.)"},
+		{"a:rebecca", true, ""},
+		// Just one printing of each card is no good for these
+		{"s:lea", true, ""},
+		{"r:common t:instant", true, ""},
+		{"is:slick", true, ""},
+	}
+	for _, table := range tables {
+		csr, err := searchLocalCards(table.query)
+		if table.unsupported {
+			if err != errUnsupportedQuery {
+				t.Errorf("Expected %s to be unsupported, got %v", table.query, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %v", table.query, err)
+			continue
+		}
+		if csr.Status != 400 {
+			t.Errorf("Expected a bad request for %s", table.query)
+			continue
+		}
+		if got := searchResultError(csr).Error(); got != table.output {
+			t.Errorf("Incorrect output for %s -- got %s -- want %s", table.query, got, table.output)
+		}
+	}
+
+	// One bad term is left out, and the rest of the query still runs
+	want, _ := searchLocalCards("t:creature")
+	csr, err := searchLocalCards("t:creature mv>abc")
+	if err != nil || csr.Status == 400 || csr.TotalCards != want.TotalCards {
+		t.Errorf("Incorrect output with one bad term -- got %d cards, %v -- want %d", csr.TotalCards, err, want.TotalCards)
+	}
+	if w := []string{"Invalid expression “mv>abc” was ignored. “abc” is not a number."}; !reflect.DeepEqual(csr.Warnings, w) {
+		t.Errorf("Incorrect warnings with one bad term -- got %q -- want %q", csr.Warnings, w)
+	}
+}
+
+func TestLocalSearchAndRandom(t *testing.T) {
+	ci, err := loadCardIndex(testBulkDataFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cardIndex.Store(ci)
+	defer cardIndex.Store(nil)
+	nameToCardCache, err = lru.NewARC(2048)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	source := newFakeScryfall(t)
+	conf.BulkDataType = "all_cards"
+	defer func() { conf.BulkDataType = "" }()
+
+	got, err := source.Search([]string{"r:mythic", "s:uma"})
+	if err != nil || len(got) != 1 || got[0].Name != "Tarmogoyf" {
+		t.Errorf("Incorrect search output -- got %v %v", got, err)
+	}
+	_, err = source.Search([]string{"mv>abc"})
+	if err == nil {
+		t.Errorf("Expected a syntax error")
+	}
+	for i := 0; i < 5; i++ {
+		card, err := source.Random([]string{"type:creature", "mv=3"})
+		if err != nil || card.Name != "Kindly Ancestor // Ancestor's Embrace" {
+			t.Errorf("Incorrect random card -- got %v %v", card.Name, err)
+		}
+	}
+	if _, err = source.Random([]string{"type:creature", "mv=15"}); err == nil {
+		t.Errorf("Expected no random card for an impossible query")
+	}
+}
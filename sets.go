@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	raven "github.com/getsentry/raven-go"
+	log "gopkg.in/inconshreveable/log15.v2"
+)
+
+var setsFile = "sets.json"
+
+const scryfallSetsAPIURL = scryfallAPIURL + "/sets"
+
+// How long the saved set list is used before fetching it again at startup
+var setsMaxAge = 24 * time.Hour
+
+// Where each set's cards are saved once they've been fetched, a file per set
+var setCardsDir = "sets"
+
+// How long a set's saved cards are used before fetching them again, since previews keep adding to new sets
+var setCardsMaxAge = 24 * time.Hour
+
+// CardSet represents one of the sets in the JSON returned by the /sets Scryfall API
+type CardSet struct {
+	Code       string `json:"code"`
+	Name       string `json:"name"`
+	SearchURI  string `json:"search_uri"`
+	ReleasedAt string `json:"released_at"`
+	CardCount  int    `json:"card_count"`
+}
+
+// SetList represents the JSON returned by the /sets Scryfall API
+type SetList struct {
+	Object string    `json:"object"`
+	Data   []CardSet `json:"data"`
+}
+
+// Every set Scryfall knows about, by lowercase set code
+var (
+	knownSets     map[string]CardSet
+	knownSetsLock sync.RWMutex
+)
+
+// fetchSets downloads the set list, going through a temporary file so a failed fetch doesn't clobber a good one
+func fetchSets(url string) error {
+	log.Debug("FetchSets: Attempting to fetch", "URL", url)
+	resp, err := upstream.Get(url)
+	if err != nil {
+		raven.CaptureError(err, nil)
+		log.Warn("FetchSets: The HTTP request failed", "Error", err)
+		return fmt.Errorf("Something went wrong fetching the set list")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		log.Warn("FetchSets: Scryfall returned a non-200", "Status Code", resp.StatusCode)
+		return fmt.Errorf("Scryfall returned a non-200")
+	}
+	out, err := os.Create(setsFile + ".tmp")
+	if err != nil {
+		raven.CaptureError(err, nil)
+		return err
+	}
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		out.Close()
+		log.Warn("FetchSets: Error writing to sets file", "Error", err)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(setsFile+".tmp", setsFile)
+}
+
+// importSets loads the set list, fetching it first if there isn't one, it's stale, or we're told to
+func importSets(forceFetch bool) error {
+	log.Debug("In importSets", "Forced?", forceFetch)
+	fi, statErr := os.Stat(setsFile)
+	if forceFetch || statErr != nil || time.Since(fi.ModTime()) > setsMaxAge {
+		if err := fetchSets(scryfallSetsAPIURL); err != nil {
+			log.Warn("Error fetching sets", "Error", err)
+			// A stale set list is better than none
+			if forceFetch || statErr != nil {
+				return err
+			}
+		}
+	}
+	f, err := os.Open(setsFile)
+	if err != nil {
+		raven.CaptureError(err, nil)
+		log.Warn("Error opening sets file", "Error", err)
+		return err
+	}
+	defer f.Close()
+	var list SetList
+	if err := json.NewDecoder(f).Decode(&list); err != nil {
+		raven.CaptureError(err, nil)
+		log.Warn("Error parsing sets file", "Error", err)
+		return fmt.Errorf("Something went wrong parsing the set list")
+	}
+	setKnownSets(list.Data)
+	log.Debug("Populated sets", "Length", len(list.Data))
+	return nil
+}
+
+func setKnownSets(sets []CardSet) {
+	ks := make(map[string]CardSet)
+	for _, s := range sets {
+		ks[strings.ToLower(s.Code)] = s
+	}
+	knownSetsLock.Lock()
+	defer knownSetsLock.Unlock()
+	knownSets = ks
+}
+
+// lookupSet finds a set by its code, in any case
+func lookupSet(code string) (CardSet, bool) {
+	knownSetsLock.RLock()
+	defer knownSetsLock.RUnlock()
+	s, ok := knownSets[strings.ToLower(code)]
+	return s, ok
+}
+
+// isKnownSetCode says whether Scryfall has a set with this code
+func isKnownSetCode(code string) bool {
+	_, ok := lookupSet(code)
+	return ok
+}
+
+func setCardsFile(set string) string {
+	return filepath.Join(setCardsDir, strings.ToLower(set)+".json")
+}
+
+// readSetCards loads a set's saved cards, and says whether they're recent enough to use without asking Scryfall
+func readSetCards(set string) ([]Card, bool, error) {
+	fi, err := os.Stat(setCardsFile(set))
+	if err != nil {
+		return nil, false, err
+	}
+	var cards []Card
+	if err := readJSONFile(setCardsFile(set), &cards); err != nil {
+		log.Warn("Error reading saved set cards", "Set", set, "Error", err)
+		return nil, false, err
+	}
+	return cards, time.Since(fi.ModTime()) < setCardsMaxAge, nil
+}
+
+// writeSetCards saves a set's cards, going through a temporary file so a failed write doesn't clobber a good one
+func writeSetCards(set string, cards []Card) error {
+	if err := os.MkdirAll(setCardsDir, 0755); err != nil {
+		return err
+	}
+	path := setCardsFile(set)
+	if err := writeJSONFile(path+".tmp", cards); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
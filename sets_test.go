@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFetchSets(t *testing.T) {
+	oldFile := setsFile
+	setsFile = filepath.Join(t.TempDir(), "sets.json")
+	defer func() { setsFile = oldFile }()
+	good := `{"object":"list","data":[{"code":"lea","name":"Limited Edition Alpha"}]}`
+	if err := os.WriteFile(setsFile, []byte(good), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	status := http.StatusInternalServerError
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(`{"object":"list","data":[{"code":"2ed","name":"Unlimited Edition"}]}`))
+	}))
+	defer ts.Close()
+
+	// A failed fetch leaves the set list we had alone
+	if err := fetchSets(ts.URL); err == nil {
+		t.Errorf("Expected an error from a non-200")
+	}
+	if b, _ := os.ReadFile(setsFile); string(b) != good {
+		t.Errorf("Set list was clobbered by a failed fetch -- got %q", b)
+	}
+
+	status = http.StatusOK
+	if err := fetchSets(ts.URL); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var list SetList
+	if err := readJSONFile(setsFile, &list); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(list.Data) != 1 || list.Data[0].Code != "2ed" {
+		t.Errorf("Incorrect set list after fetching -- got %v", list.Data)
+	}
+}
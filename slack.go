@@ -54,8 +54,16 @@ func runSlack(rtm *slack.RTM, api *slack.Client) {
 			if ev.ThreadTimestamp != "" {
 				options = append(options, slack.RTMsgOptionTS(ev.ThreadTimestamp))
 			}
-			toPrint := tokeniseAndDispatchInput(&fryatogParams{slackm: text}, getScryfallCard, getDumbScryfallCard, getRandomScryfallCard, searchScryfallCard)
+			images := &slackImages{}
+			toPrint := tokeniseAndDispatchInput(&fryatogParams{slackm: text, channel: ev.Msg.Channel, user: user.ID, images: images}, cardSource)
+			for _, img := range images.all() {
+				postSlackImage(api, ev.Msg.Channel, ev.ThreadTimestamp, user.ID, img)
+			}
 			for _, s := range sliceUniqMap(toPrint) {
+				s, overflow := splitOverflow(s)
+				if overflow != "" {
+					rtm.SendMessage(rtm.NewOutgoingMessage(overflow, ev.Msg.Channel, slack.RTMsgOptionTS(nco(ev.ThreadTimestamp, ev.Timestamp))))
+				}
 				if s != "" {
 					rtm.SendMessage(rtm.NewOutgoingMessage(fmt.Sprintf("<@%v>: %v", user.ID, s), ev.Msg.Channel, options...))
 				}
@@ -80,3 +88,30 @@ func runSlack(rtm *slack.RTM, api *slack.Client) {
 		}
 	}
 }
+
+// postSlackImage shows a card image as an image block, which the RTM connection can't send
+func postSlackImage(api *slack.Client, channel string, threadTimestamp string, userID string, img slackImage) {
+	blocks := []slack.Block{
+		slack.NewImageBlock(img.URL, img.AltText, "", slack.NewTextBlockObject(slack.PlainTextType, img.Title, false, false)),
+	}
+	var context []slack.MixedElement
+	if img.Credit != "" {
+		context = append(context, slack.NewTextBlockObject(slack.MarkdownType, img.Credit, false, false))
+	}
+	if img.Link != "" {
+		context = append(context, slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("<%s|Scryfall>", img.Link), false, false))
+	}
+	if len(context) > 0 {
+		blocks = append(blocks, slack.NewContextBlock("", context...))
+	}
+	options := []slack.MsgOption{
+		slack.MsgOptionText(fmt.Sprintf("<@%v>: %v", userID, img.Title), false),
+		slack.MsgOptionBlocks(blocks...),
+	}
+	if threadTimestamp != "" {
+		options = append(options, slack.MsgOptionTS(threadTimestamp))
+	}
+	if _, _, err := api.PostMessage(channel, options...); err != nil {
+		log.Warn("Slack image", "Error posting", err)
+	}
+}
@@ -37,7 +37,7 @@ type SnapResponse struct {
 
 func handleSnapQuery(cardTokens []string) string {
 	for _, rc := range reduceCardSentence(cardTokens) {
-		card, err := searchSnapCard(rc)
+		card, err := searchSnapCard(rc.name)
 		log.Debug("Snap Card Func gave us", "CardID", card, "Err", err)
 		if err == nil {
 			return card
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "gopkg.in/inconshreveable/log15.v2"
+)
+
+const spoilersFile = "spoilers.json"
+
+// How often to look for new cards, unless the configuration says otherwise
+const defaultSpoilerInterval = 15 * time.Minute
+
+// The most new cards posted from a set at once, so a big reveal doesn't flood the channel
+const spoilersPerPoll = 5
+
+// spoilerState is what's saved between restarts
+type spoilerState struct {
+	Subscriptions map[string][]string `json:"subscriptions"`
+	Seen          map[string][]string `json:"seen"`
+}
+
+// spoilerWatcher looks for newly revealed cards in the sets channels have asked about
+type spoilerWatcher struct {
+	source *scryfallSource
+	file   string
+	post   func(channel string, message string) error
+
+	lock sync.Mutex
+	// Channel to the set codes it's watching
+	subscriptions map[string][]string
+	// Set code to the IDs of the cards we already know about
+	seen map[string]map[string]bool
+}
+
+func newSpoilerWatcher(source *scryfallSource, file string, post func(channel string, message string) error) *spoilerWatcher {
+	return &spoilerWatcher{source: source, file: file, post: post, subscriptions: make(map[string][]string), seen: make(map[string]map[string]bool)}
+}
+
+// load reads what was saved last time, if anything was
+func (w *spoilerWatcher) load() error {
+	b, err := os.ReadFile(w.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var state spoilerState
+	if err := json.Unmarshal(b, &state); err != nil {
+		return err
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	for channel, sets := range state.Subscriptions {
+		w.subscriptions[channel] = sets
+	}
+	for set, ids := range state.Seen {
+		w.seen[set] = make(map[string]bool)
+		for _, id := range ids {
+			w.seen[set][id] = true
+		}
+	}
+	return nil
+}
+
+// save writes out the subscriptions and the cards we've seen. The lock must be held.
+func (w *spoilerWatcher) save() error {
+	state := spoilerState{Subscriptions: w.subscriptions, Seen: make(map[string][]string)}
+	for set, ids := range w.seen {
+		for id := range ids {
+			state.Seen[set] = append(state.Seen[set], id)
+		}
+		sort.Strings(state.Seen[set])
+	}
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(w.file, b)
+}
+
+// fetch gets every card in the set that's been revealed so far
+func (w *spoilerWatcher) fetch(set string) ([]Card, error) {
+	return w.source.searchAll(url.Values{"q": {"e:" + set}, "order": {"spoiled"}}, strings.ToUpper(set))
+}
+
+// prime remembers what's already out in a set, so that only what comes after gets posted.
+// If there's nothing out yet, everything will be new. If Scryfall didn't answer properly,
+// the set is left unprimed for the next poll to try again, rather than posting everything.
+func (w *spoilerWatcher) prime(set string) {
+	cards, err := w.fetch(set)
+	if err != nil && !errors.Is(err, errNoCardsFound) {
+		log.Info("Spoilers: Unable to prime", "Set", set, "Error", err)
+		return
+	}
+	if err != nil {
+		log.Info("Spoilers: Nothing to prime", "Set", set, "Error", err)
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if _, ok := w.seen[set]; ok {
+		return
+	}
+	w.seen[set] = make(map[string]bool)
+	for _, c := range cards {
+		w.seen[set][c.ID] = true
+	}
+}
+
+// sets are all the sets anyone is watching
+func (w *spoilerWatcher) sets() []string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	found := make(map[string]bool)
+	var ret []string
+	for _, sets := range w.subscriptions {
+		for _, set := range sets {
+			if !found[set] {
+				found[set] = true
+				ret = append(ret, set)
+			}
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// channels are the channels watching a set
+func (w *spoilerWatcher) channels(set string) []string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	var ret []string
+	for channel, sets := range w.subscriptions {
+		if stringSliceContains(sets, set) {
+			ret = append(ret, channel)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// poll looks at each set for cards we haven't seen, and posts them to the channels watching it
+func (w *spoilerWatcher) poll() {
+	for _, set := range w.sets() {
+		w.lock.Lock()
+		_, primed := w.seen[set]
+		w.lock.Unlock()
+		if !primed {
+			w.prime(set)
+			continue
+		}
+		cards, err := w.fetch(set)
+		if err != nil {
+			log.Debug("Spoilers: No cards", "Set", set, "Error", err)
+			continue
+		}
+		w.lock.Lock()
+		var revealed []Card
+		for _, c := range cards {
+			if !w.seen[set][c.ID] {
+				w.seen[set][c.ID] = true
+				revealed = append(revealed, c)
+			}
+		}
+		if len(revealed) > 0 {
+			if err := w.save(); err != nil {
+				log.Warn("Spoilers: Error saving", "Error", err)
+			}
+		}
+		w.lock.Unlock()
+		if len(revealed) > 0 {
+			spoilersPosted.Add(int64(len(revealed)))
+			w.announce(set, revealed)
+		}
+	}
+}
+
+// announce posts the new cards, formatted for each channel
+func (w *spoilerWatcher) announce(set string, revealed []Card) {
+	for _, channel := range w.channels(set) {
+		params := &fryatogParams{isIRC: isIRCChannel(channel), channel: channel, source: w.source}
+		var lines []string
+		for i := range revealed[:min(spoilersPerPoll, len(revealed))] {
+			line, _ := splitOverflow(params.formatCardFace(&revealed[i], -1))
+			lines = append(lines, params.bold("New preview")+" · "+line)
+		}
+		if extra := len(revealed) - spoilersPerPoll; extra > 0 {
+			lines = append(lines, fmt.Sprintf("…and %d more from %s", extra, strings.ToUpper(set)))
+		}
+		if err := w.post(channel, strings.Join(lines, "\n")); err != nil {
+			log.Warn("Spoilers: Error posting", "Channel", channel, "Error", err)
+		}
+	}
+}
+
+// subscribe starts a channel watching a set
+func (w *spoilerWatcher) subscribe(channel string, set string) string {
+	set = strings.ToLower(set)
+	if !setCodeRegex.MatchString(set) {
+		return "That doesn't look like a set code"
+	}
+	w.prime(set)
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if stringSliceContains(w.subscriptions[channel], set) {
+		return fmt.Sprintf("Already watching %s here", strings.ToUpper(set))
+	}
+	w.subscriptions[channel] = append(w.subscriptions[channel], set)
+	if err := w.save(); err != nil {
+		log.Warn("Spoilers: Error saving", "Error", err)
+	}
+	return fmt.Sprintf("Watching %s for previews here", strings.ToUpper(set))
+}
+
+// unsubscribe stops a channel watching a set
+func (w *spoilerWatcher) unsubscribe(channel string, set string) string {
+	set = strings.ToLower(set)
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	var sets []string
+	for _, s := range w.subscriptions[channel] {
+		if s != set {
+			sets = append(sets, s)
+		}
+	}
+	if len(sets) == len(w.subscriptions[channel]) {
+		return fmt.Sprintf("Not watching %s here", strings.ToUpper(set))
+	}
+	if len(sets) == 0 {
+		delete(w.subscriptions, channel)
+	} else {
+		w.subscriptions[channel] = sets
+	}
+	if err := w.save(); err != nil {
+		log.Warn("Spoilers: Error saving", "Error", err)
+	}
+	return fmt.Sprintf("No longer watching %s here", strings.ToUpper(set))
+}
+
+// watching says which sets a channel is watching
+func (w *spoilerWatcher) watching(channel string) string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	sets := w.subscriptions[channel]
+	if len(sets) == 0 {
+		return "Not watching any sets for previews here"
+	}
+	var codes []string
+	for _, s := range sets {
+		codes = append(codes, strings.ToUpper(s))
+	}
+	return fmt.Sprintf("Watching %s for previews here", strings.Join(codes, ", "))
+}
+
+// handleSpoilersCommand deals with the operator commands !spoilers watch and unwatch <set>
+func (w *spoilerWatcher) handleSpoilersCommand(channel string, tokens []string) string {
+	if len(tokens) != 2 {
+		return "!spoilers watch/unwatch <set>"
+	}
+	switch strings.ToLower(tokens[0]) {
+	case "watch", "subscribe":
+		return w.subscribe(channel, tokens[1])
+	case "unwatch", "unsubscribe":
+		return w.unsubscribe(channel, tokens[1])
+	}
+	return "!spoilers watch/unwatch <set>"
+}
+
+// runSpoilerWatcher polls forever
+func runSpoilerWatcher(w *spoilerWatcher, interval time.Duration) {
+	for {
+		w.poll()
+		time.Sleep(interval)
+	}
+}
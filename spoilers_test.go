@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakePreviews is a stand-in for Scryfall during preview season, where more of each set is revealed as we go
+type fakePreviews struct {
+	lock sync.Mutex
+	sets map[string][]Card
+	// Sets that Scryfall falls over on
+	broken map[string]bool
+}
+
+func (fp *fakePreviews) reveal(set string, names ...string) {
+	fp.lock.Lock()
+	defer fp.lock.Unlock()
+	for _, name := range names {
+		fp.sets[set] = append(fp.sets[set], Card{Name: name, ID: set + "-" + name, Set: set, Rarity: "rare"})
+	}
+}
+
+func (fp *fakePreviews) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fp.lock.Lock()
+	defer fp.lock.Unlock()
+	set := strings.TrimPrefix(r.URL.Query().Get("q"), "e:")
+	if fp.broken[set] {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	cards := fp.sets[set]
+	if len(cards) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(CardSearchResult{TotalCards: len(cards), Data: cards})
+}
+
+func TestSpoilerWatcher(t *testing.T) {
+	previews := &fakePreviews{sets: make(map[string][]Card), broken: make(map[string]bool)}
+	ts := httptest.NewServer(previews)
+	defer ts.Close()
+
+	posted := make(map[string][]string)
+	post := func(channel string, message string) error {
+		posted[channel] = append(posted[channel], message)
+		return nil
+	}
+	file := filepath.Join(t.TempDir(), "spoilers.json")
+	w := newSpoilerWatcher(newScryfallSource(ts.URL), file, post)
+
+	previews.reveal("abc", "Old One")
+	if got := w.subscribe("#judges", "ABC"); got != "Watching ABC for previews here" {
+		t.Errorf("Incorrect subscribe -- got %q", got)
+	}
+	if got := w.subscribe("#judges", "abc"); got != "Already watching ABC here" {
+		t.Errorf("Incorrect subscribe -- got %q", got)
+	}
+	// Nothing's out in this one yet
+	w.handleSpoilersCommand("#mtg", []string{"watch", "xyz"})
+	w.handleSpoilersCommand("#judges", []string{"watch", "xyz"})
+	if got := w.watching("#judges"); got != "Watching ABC, XYZ for previews here" {
+		t.Errorf("Incorrect subscriptions -- got %q", got)
+	}
+
+	// What was out already isn't news
+	w.poll()
+	if len(posted) != 0 {
+		t.Fatalf("Posted cards that were already out -- got %v", posted)
+	}
+
+	previews.reveal("abc", "New One")
+	previews.reveal("xyz", "First One")
+	w.poll()
+	w.poll()
+	if got, want := posted["#judges"], []string{"\x02New preview\x0F · \x02New One\x0F ·  · · ABC-R · ", "\x02New preview\x0F · \x02First One\x0F ·  · · XYZ-R · "}; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Incorrect posts -- got %q -- want %q", got, want)
+	}
+	if got := posted["#mtg"]; len(got) != 1 || !strings.Contains(got[0], "First One") {
+		t.Errorf("Incorrect posts -- got %q", got)
+	}
+
+	// A big reveal is cut short
+	previews.reveal("xyz", "A", "B", "C", "D", "E", "F", "G")
+	w.poll()
+	last := posted["#mtg"][len(posted["#mtg"])-1]
+	if lines := strings.Split(last, "\n"); len(lines) != spoilersPerPoll+1 || lines[spoilersPerPoll] != "…and 2 more from XYZ" {
+		t.Errorf("Incorrect big reveal -- got %q", last)
+	}
+
+	// Scryfall falling over isn't the same as nothing being out yet
+	previews.reveal("brk", "Already Out")
+	previews.lock.Lock()
+	previews.broken["brk"] = true
+	previews.lock.Unlock()
+	w.handleSpoilersCommand("#mtg", []string{"watch", "brk"})
+	w.poll()
+	previews.lock.Lock()
+	previews.broken["brk"] = false
+	previews.lock.Unlock()
+	before := len(posted["#mtg"])
+	w.poll()
+	w.poll()
+	if len(posted["#mtg"]) != before {
+		t.Errorf("Posted cards that were out before Scryfall answered -- got %q", posted["#mtg"][before:])
+	}
+	w.handleSpoilersCommand("#mtg", []string{"unwatch", "brk"})
+
+	// What we've seen survives a restart
+	again := newSpoilerWatcher(newScryfallSource(ts.URL), file, post)
+	if err := again.load(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	before = len(posted["#judges"])
+	again.poll()
+	if len(posted["#judges"]) != before {
+		t.Errorf("Posted cards that were seen before the restart -- got %q", posted["#judges"][before:])
+	}
+	if got := again.unsubscribe("#mtg", "xyz"); got != "No longer watching XYZ here" {
+		t.Errorf("Incorrect unsubscribe -- got %q", got)
+	}
+	if got := again.watching("#mtg"); got != "Not watching any sets for previews here" {
+		t.Errorf("Incorrect subscriptions -- got %q", got)
+	}
+	if _, err := os.Stat(file + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("Expected no leftover temp file -- got %v", err)
+	}
+
+	// A save that can't finish leaves the last good one behind
+	good, _ := os.ReadFile(file)
+	if err := os.Mkdir(file+".tmp", 0755); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	again.subscribe("#mtg", "abc")
+	if b, _ := os.ReadFile(file); string(b) != string(good) {
+		t.Errorf("Saved state was clobbered by a failed save -- got %q", b)
+	}
+	os.Remove(file + ".tmp")
+	restarted := newSpoilerWatcher(newScryfallSource(ts.URL), file, post)
+	if err := restarted.load(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := restarted.watching("#mtg"); got != "Not watching any sets for previews here" {
+		t.Errorf("Incorrect subscriptions after a failed save -- got %q", got)
+	}
+	if got := again.handleSpoilersCommand("#mtg", []string{"watch", "not-a-set!"}); got != "That doesn't look like a set code" {
+		t.Errorf("Incorrect output for a bad set -- got %q", got)
+	}
+	if got := again.handleSpoilersCommand("#mtg", []string{"watch"}); got != "!spoilers watch/unwatch <set>" {
+		t.Errorf("Incorrect output for a bad command -- got %q", got)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+)
+
+// How many names to suggest when a card isn't found
+const maxSuggestions = 3
+
+// Shorter than this and everything looks like everything else
+const suggestionMinLength = 4
+
+// How alike a name has to be to the input before we suggest it, from 0 to 1
+const suggestionThreshold = 0.45
+
+// How alike the best name has to be before we suggest anything, so that chatter that happens to start with a ! gets no reply
+const bestSuggestionThreshold = 0.6
+
+// Names that look like legends get this much of a leg up
+const legendaryishBonus = 0.1
+
+// How long a !1 or !2 still picks from the last suggestions someone was given
+const suggestionTTL = 5 * time.Minute
+
+var suggestionPickRegex = regexp.MustCompile(`^[1-9]$`)
+
+// suggestionName is a card name, normalised and cut into trigrams ready to compare
+type suggestionName struct {
+	name     string
+	ncn      string
+	trigrams []string
+}
+
+// The card names to suggest from, worked out once when the names are loaded rather than on every miss
+var (
+	suggestionNames     []suggestionName
+	suggestionNamesLock sync.RWMutex
+)
+
+func newSuggestionNames(names []string) []suggestionName {
+	ret := make([]suggestionName, 0, len(names))
+	for _, name := range names {
+		ncn := normaliseCardName(name)
+		sn := suggestionName{name: name, ncn: ncn}
+		for t := range trigrams(ncn) {
+			sn.trigrams = append(sn.trigrams, t)
+		}
+		ret = append(ret, sn)
+	}
+	return ret
+}
+
+func setSuggestionNames(names []string) {
+	sn := newSuggestionNames(names)
+	suggestionNamesLock.Lock()
+	defer suggestionNamesLock.Unlock()
+	suggestionNames = sn
+}
+
+func currentSuggestionNames() []suggestionName {
+	suggestionNamesLock.RLock()
+	defer suggestionNamesLock.RUnlock()
+	return suggestionNames
+}
+
+// suggestionCache holds the last names suggested to each person in each channel
+var suggestionCache = cache.New(suggestionTTL, time.Minute)
+
+// suggestionKey is who was given the suggestions, and where, so that everyone in a channel,
+// and everyone sending private messages, has their own
+func (params *fryatogParams) suggestionKey() string {
+	return params.channel + "|" + params.user
+}
+
+// trigrams are the three letter pieces of a normalised name, with the ends marked so that they count too
+func trigrams(ncn string) map[string]bool {
+	padded := []rune("$" + ncn + "$")
+	ret := make(map[string]bool)
+	for i := 0; i+3 <= len(padded); i++ {
+		ret[string(padded[i:i+3])] = true
+	}
+	return ret
+}
+
+// trigramSimilarity is how many trigrams two names share, out of all the trigrams in either
+func trigramSimilarity(a map[string]bool, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	var shared int
+	for _, t := range b {
+		if a[t] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// suggestionScore is how likely a name is to be the one that was meant, from 0 to 1
+func suggestionScore(ncn string, inputTrigrams map[string]bool, sn suggestionName) float64 {
+	nn := sn.ncn
+	score := trigramSimilarity(inputTrigrams, sn.trigrams)
+	// Typos are a few edits away
+	maxDistance := max(1, len(ncn)/4)
+	if abs(len(nn)-len(ncn)) <= maxDistance {
+		if d := levenshteinDistance(nn, ncn); d <= maxDistance {
+			score = max(score, 1-float64(d)/float64(len(ncn)))
+		}
+	}
+	// Or it's just the start of the name
+	if strings.HasPrefix(nn, ncn) {
+		score = max(score, suggestionThreshold+float64(len(ncn))/float64(len(nn))/2)
+	}
+	return score
+}
+
+// suggestCardNames finds the names closest to what was asked for, best first, if the best is close enough
+func suggestCardNames(input string, names []suggestionName) []string {
+	ncn := normaliseCardName(input)
+	if len(ncn) < suggestionMinLength {
+		return nil
+	}
+	inputTrigrams := trigrams(ncn)
+	type suggestion struct {
+		name  string
+		score float64
+	}
+	var found []suggestion
+	for _, sn := range names {
+		score := suggestionScore(ncn, inputTrigrams, sn)
+		if score < suggestionThreshold {
+			continue
+		}
+		if looksLegendaryish(sn.name) {
+			score += legendaryishBonus
+		}
+		found = append(found, suggestion{sn.name, score})
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].score != found[j].score {
+			return found[i].score > found[j].score
+		}
+		return found[i].name < found[j].name
+	})
+	if len(found) == 0 || found[0].score < bestSuggestionThreshold {
+		return nil
+	}
+	var ret []string
+	for _, s := range found[:min(maxSuggestions, len(found))] {
+		ret = append(ret, s.name)
+	}
+	return ret
+}
+
+// joinWithOr lists things like "X, Y or Z"
+func joinWithOr(items []string) string {
+	if len(items) < 2 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " or " + items[len(items)-1]
+}
+
+// suggestCards replies to a card that wasn't found with the names that might have been meant,
+// and remembers them so that !1, !2 and so on can pick one.
+// There's no reply at all if nothing is close, since it was probably just chatter.
+func (params *fryatogParams) suggestCards(input string) string {
+	suggestions := suggestCardNames(input, currentSuggestionNames())
+	if len(suggestions) == 0 {
+		return ""
+	}
+	suggestionRequests.Add(1)
+	suggestionCache.Set(params.suggestionKey(), suggestions, cache.DefaultExpiration)
+	var picks []string
+	for i := range suggestions {
+		picks = append(picks, fmt.Sprintf("!%d", i+1))
+	}
+	return fmt.Sprintf("Card not found — did you mean %s? (%s to pick)", joinWithOr(suggestions), joinWithOr(picks))
+}
+
+// pickSuggestion looks up one of the names last suggested to whoever is asking, in this channel
+func (params *fryatogParams) pickSuggestion(pick string) string {
+	n, err := strconv.Atoi(pick)
+	if err != nil {
+		return ""
+	}
+	cached, ok := suggestionCache.Get(params.suggestionKey())
+	if !ok {
+		return ""
+	}
+	suggestions := cached.([]string)
+	if n < 1 || n > len(suggestions) {
+		return ""
+	}
+	card, err := findCard([]string{suggestions[n-1]}, false, params.source.Named)
+	if err != nil {
+		return ""
+	}
+	suggestionPicks.Add(1)
+	return params.formatCardFace(&card, card.faceNamed(suggestions[n-1]))
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	lru "github.com/hashicorp/golang-lru"
+	hbot "github.com/whyrusleeping/hellabot"
+)
+
+var suggestionTestNames = []string{
+	"Tarmogoyf", "Tarmo Twin", "Ponder", "Pondering Mage", "Jace, the Mind Sculptor", "Jace, Vryn's Prodigy",
+	"Jace Beleren", "Jace's Erasure", "Disenchant", "Faithless Looting", "Lightning Bolt", "Lightning Helix",
+}
+
+func TestSuggestCardNames(t *testing.T) {
+	tables := []struct {
+		input  string
+		output []string
+	}{
+		{"Tarmogyof", []string{"Tarmogoyf"}},
+		{"Disenchnat", []string{"Disenchant"}},
+		{"Jace", []string{"Jace, Vryn's Prodigy", "Jace, the Mind Sculptor", "Jace Beleren"}},
+		{"Lightning Blot", []string{"Lightning Bolt"}},
+		{"Pond", []string{"Ponder", "Pondering Mage"}},
+		{"Hello there everyone", nil},
+		{"Tar", nil},
+		// Close enough to be a suggestion, but not to be the best one
+		{"Ponderous", nil},
+	}
+	names := newSuggestionNames(suggestionTestNames)
+	for _, table := range tables {
+		if got := suggestCardNames(table.input, names); !reflect.DeepEqual(got, table.output) {
+			t.Errorf("Incorrect suggestions for %s -- got %q -- want %q", table.input, got, table.output)
+		}
+	}
+}
+
+func TestSuggestionPicks(t *testing.T) {
+	var err error
+	nameToCardCache, err = lru.NewARC(2048)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	oldNames := currentSuggestionNames()
+	setSuggestionNames(suggestionTestNames)
+	defer func() {
+		suggestionNamesLock.Lock()
+		suggestionNames = oldNames
+		suggestionNamesLock.Unlock()
+	}()
+	suggestionCache.Flush()
+	defer suggestionCache.Flush()
+	source := newFakeScryfall(t)
+
+	tables := []struct {
+		channel string
+		user    string
+		input   string
+		output  []string
+	}{
+		{"#mtg", "fry", "!1", []string{""}},
+		{"#mtg", "fry", "!Pond", []string{"Card not found — did you mean Ponder or Pondering Mage? (!1 or !2 to pick)"}},
+		{"#mtg", "fry", "!Tarmogyof", []string{"Card not found — did you mean Tarmogoyf? (!1 to pick)"}},
+		// Someone else in the channel hasn't been given any
+		{"#mtg", "volo", "!1", []string{""}},
+		{"fryatog", "volo", "!Tarmogyof", []string{"Card not found — did you mean Tarmogoyf? (!1 to pick)"}},
+		// Nor has someone else sending private messages
+		{"fryatog", "other", "!1", []string{""}},
+		{"#mtg", "fry", "!1", []string{"\x02Tarmogoyf\x0F {1}{G} · Creature — Lhurgoyf · */1+* · Tarmogoyf's power is equal to the number of card types among cards in all graveyards and its toughness is equal to that number plus 1. · UMA-M · Vin,Cmr,Leg,Mod"}},
+		{"#mtg", "fry", "!2", []string{""}},
+		{"#other", "fry", "!1", []string{""}},
+	}
+	for _, table := range tables {
+		got := tokeniseAndDispatchInput(&fryatogParams{m: &hbot.Message{Content: table.input, To: table.channel, From: table.user}}, source)
+		if !reflect.DeepEqual(got, table.output) {
+			t.Errorf("Incorrect output for [%v] from %s in %s -- got %q -- want %q", table.input, table.user, table.channel, got, table.output)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const relatedCardCacheSize = 512
+
+// How many tokens are shown in full on IRC before we just list their names
+const ircTokensShown = 3
+
+var powerToughnessRegex = regexp.MustCompile(`^(\*|\d+)/(\*|\d+)$`)
+
+// relatedCardCache holds tokens and the like by their ID. They're kept apart from
+// the name cache so that asking for a Rhino card never turns up the Rhino token.
+var relatedCardCache, _ = lru.NewARC(relatedCardCacheSize)
+
+// tokenParts are the tokens and emblems a card makes
+func (card *Card) tokenParts() []RelatedCard {
+	var ret []RelatedCard
+	for _, part := range card.AllParts {
+		if part.Component == "token" || strings.HasPrefix(part.TypeLine, "Emblem") {
+			ret = append(ret, part)
+		}
+	}
+	return ret
+}
+
+// meldLine says what a meld card melds with, or what a melded card is melded from
+func (card *Card) meldLine() string {
+	var parts []string
+	var result string
+	for _, part := range card.AllParts {
+		switch part.Component {
+		case "meld_part":
+			if part.Name != card.Name {
+				parts = append(parts, part.Name)
+			}
+		case "meld_result":
+			result = part.Name
+		}
+	}
+	switch {
+	case len(parts) == 0 || result == "":
+		return ""
+	case result == card.Name:
+		return fmt.Sprintf("Melded from %s", strings.Join(parts, " and "))
+	default:
+		return fmt.Sprintf("Melds with %s into %s", strings.Join(parts, " and "), result)
+	}
+}
+
+// formatToken shows a token, which has no mana cost, set or legality worth showing
+func (card *Card) formatToken(isIRC bool) string {
+	var s []string
+	colors := "[Colorless]"
+	if len(card.Colors) > 0 {
+		colors = standardiseColorIndicator(card.Colors)
+	}
+	if isIRC {
+		s = append(s, fmt.Sprintf("\x02%s\x0F %s", card.Name, colors))
+		s = append(s, card.CommonCard.getCardOrFaceAsString("irc")...)
+	} else {
+		s = append(s, fmt.Sprintf("*<%s|%s>* %s", card.ScryfallURI, card.Name, colors))
+		s = append(s, card.CommonCard.getCardOrFaceAsString("slack")...)
+	}
+	return strings.TrimSuffix(strings.Join(s, " "), " ·")
+}
+
+// formatTokens shows each token in full, or just their names if there are too many for IRC
+func formatTokens(tokens []Card, isIRC bool) string {
+	if isIRC && len(tokens) > ircTokensShown {
+		var names []string
+		for _, t := range tokens {
+			names = append(names, t.tokenSummary())
+		}
+		return strings.Join(names, " · ")
+	}
+	var ret []string
+	for _, t := range tokens {
+		ret = append(ret, t.formatToken(isIRC))
+	}
+	return strings.Join(ret, "\n")
+}
+
+// tokenSummary is a token's name and P/T, if it has one
+func (card *Card) tokenSummary() string {
+	if card.Power == "" {
+		return card.Name
+	}
+	return fmt.Sprintf("%s %s/%s", card.Name, card.Power, card.Toughness)
+}
+
+// handleTokensQuery deals with !tokens <card>, showing what it makes
+func handleTokensQuery(params *fryatogParams, cardTokens []string) string {
+	tokenRequests.Add(1)
+	card, err := findCard(cardTokens, false, params.source.Named)
+	if err != nil {
+		return "Card not found"
+	}
+	parts := card.tokenParts()
+	if len(parts) == 0 {
+		return fmt.Sprintf("%s doesn't make any tokens", card.Name)
+	}
+	var tokens []Card
+	var missing []string
+	for _, part := range parts {
+		t, err := params.source.Related(part)
+		if err != nil {
+			missing = append(missing, part.Name)
+			continue
+		}
+		tokens = append(tokens, t)
+	}
+	var ret []string
+	if len(tokens) > 0 {
+		ret = append(ret, formatTokens(tokens, params.isIRC))
+	}
+	if len(missing) > 0 {
+		ret = append(ret, "Couldn't fetch: "+strings.Join(missing, ", "))
+	}
+	return strings.Join(ret, "\n")
+}
+
+// tokenSearchQuery is the Scryfall search for tokens with this name, and this P/T if there is one.
+// A * can't be compared with =, so that's pow:* rather than pow=*.
+func tokenSearchQuery(name string, pt string) string {
+	query := fmt.Sprintf(`t:token !"%s"`, strings.ReplaceAll(name, `"`, ""))
+	if m := powerToughnessRegex.FindStringSubmatch(pt); m != nil {
+		for i, stat := range []string{"pow", "tou"} {
+			if m[i+1] == "*" {
+				query += fmt.Sprintf(" %s:*", stat)
+			} else {
+				query += fmt.Sprintf(" %s=%s", stat, m[i+1])
+			}
+		}
+	}
+	return query
+}
+
+// handleTokenQuery deals with !token <name> [P/T]
+func handleTokenQuery(params *fryatogParams, tokens []string) string {
+	tokenRequests.Add(1)
+	var pt string
+	if len(tokens) > 1 && powerToughnessRegex.MatchString(tokens[len(tokens)-1]) {
+		pt = tokens[len(tokens)-1]
+		tokens = tokens[:len(tokens)-1]
+	}
+	name := strings.Join(tokens, " ")
+	found, err := params.source.Tokens(name, pt)
+	if err != nil || len(found) == 0 {
+		if pt != "" {
+			return fmt.Sprintf("No %s %s tokens found", pt, name)
+		}
+		return fmt.Sprintf("No %s tokens found", name)
+	}
+	return formatTokens(found, params.isIRC)
+}
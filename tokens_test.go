@@ -0,0 +1,109 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+func TestMeldLine(t *testing.T) {
+	parts := []RelatedCard{
+		{Component: "meld_part", Name: "Bruna, the Fading Light"},
+		{Component: "meld_part", Name: "Gisela, the Broken Blade"},
+		{Component: "meld_result", Name: "Brisela, Voice of Nightmares"},
+	}
+	tables := []struct {
+		name   string
+		parts  []RelatedCard
+		output string
+	}{
+		{"Gisela, the Broken Blade", parts, "Melds with Bruna, the Fading Light into Brisela, Voice of Nightmares"},
+		{"Brisela, Voice of Nightmares", parts, "Melded from Bruna, the Fading Light and Gisela, the Broken Blade"},
+		{"Crashing Footfalls", []RelatedCard{{Component: "token", Name: "Rhino"}}, ""},
+	}
+	for _, table := range tables {
+		card := Card{Name: table.name, AllParts: table.parts}
+		if got := card.meldLine(); got != table.output {
+			t.Errorf("Incorrect meld line for %s -- got %q -- want %q", table.name, got, table.output)
+		}
+	}
+
+	// On the reserved list as well, with just the one separator between them
+	for _, reserved := range []bool{false, true} {
+		card := Card{Name: "Gisela, the Broken Blade", AllParts: parts, Reserved: reserved}
+		card.ManaCost, card.TypeLine, card.OracleText = "{2}{W}{W}", "Legendary Creature — Angel Horror", "Flying, first strike, lifelink"
+		card.Power, card.Toughness = "4", "3"
+		got := card.formatCardForSlack()
+		if strings.Contains(got, "· ·") || !strings.Contains(got, "· Melds with") {
+			t.Errorf("Incorrect Slack meld line -- got %q", got)
+		}
+	}
+}
+
+func TestTokensQuery(t *testing.T) {
+	var err error
+	nameToCardCache, err = lru.NewARC(2048)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	source := newFakeScryfall(t)
+	tables := []struct {
+		input  string
+		isIRC  bool
+		output string
+	}{
+		{"Arlinn Kord", true, "\x02Wolf\x0F [Green] · Token Creature — Wolf · 2/2\nCouldn't fetch: Arlinn Kord Emblem"},
+		{"Arlinn Kord", false, "*<https://scryfall.com/card/tsoi/14/wolf?utm_source=api|Wolf>* [Green] · Token Creature — Wolf · 2/2\nCouldn't fetch: Arlinn Kord Emblem"},
+		{"Ponder", true, "Ponder doesn't make any tokens"},
+		{"Lightning Bolt", true, "Card not found"},
+	}
+	for _, table := range tables {
+		got := handleTokensQuery(&fryatogParams{isIRC: table.isIRC, source: source}, strings.Fields(table.input))
+		if got != table.output {
+			t.Errorf("Incorrect output for %s -- got %q -- want %q", table.input, got, table.output)
+		}
+	}
+}
+
+func TestTokenQuery(t *testing.T) {
+	source := newFakeScryfall(t)
+	tables := []struct {
+		input  string
+		output string
+	}{
+		{"Rhino", "\x02Rhino\x0F [Green] · Token Creature — Rhino · 4/4 · Trample"},
+		{"Wolf", "\x02Wolf\x0F [Green] · Token Creature — Wolf · 2/2\n\x02Wolf\x0F [Black] · Token Creature — Wolf · 1/1 · Deathtouch"},
+		{"Wolf 2/2", "\x02Wolf\x0F [Green] · Token Creature — Wolf · 2/2"},
+		{"Wolf 3/3", "No 3/3 Wolf tokens found"},
+		{"Goblin", "No Goblin tokens found"},
+	}
+	for _, table := range tables {
+		got := handleTokenQuery(&fryatogParams{isIRC: true, source: source}, strings.Fields(table.input))
+		if got != table.output {
+			t.Errorf("Incorrect output for %s -- got %q -- want %q", table.input, got, table.output)
+		}
+	}
+	many := []Card{{Name: "Goblin", CommonCard: CommonCard{Power: "1", Toughness: "1"}}, {Name: "Treasure"}, {Name: "Clue"}, {Name: "Food"}}
+	if got := formatTokens(many, true); got != "Goblin 1/1 · Treasure · Clue · Food" {
+		t.Errorf("Incorrect output for many tokens -- got %q", got)
+	}
+}
+
+func TestTokenSearchQuery(t *testing.T) {
+	tables := []struct {
+		name   string
+		pt     string
+		output string
+	}{
+		{"Wolf", "", `t:token !"Wolf"`},
+		{"Wolf", "2/2", `t:token !"Wolf" pow=2 tou=2`},
+		{"Construct", "*/*", `t:token !"Construct" pow:* tou:*`},
+		{"Say \"Hi\"", "0/*", `t:token !"Say Hi" pow=0 tou:*`},
+	}
+	for _, table := range tables {
+		if got := tokenSearchQuery(table.name, table.pt); got != table.output {
+			t.Errorf("Incorrect query for %s %s -- got %q -- want %q", table.name, table.pt, got, table.output)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	log "gopkg.in/inconshreveable/log15.v2"
+)
+
+const upstreamUserAgent = "Fryatog/1.0 (+https://github.com/fryyyyy/fryatog)"
+const upstreamAccept = "application/json;q=0.9,*/*;q=0.8"
+const upstreamTimeout = 10 * time.Second
+const upstreamMaxRetries = 3
+const upstreamBackoff = 250 * time.Millisecond
+const upstreamMaxBackoff = 5 * time.Second
+
+const prefetchWorkers = 4
+const prefetchQueueLength = 64
+
+// rateLimit is how quickly, and how bursty, we're allowed to hit a host.
+type rateLimit struct {
+	perSecond float64
+	burst     float64
+}
+
+var (
+	// Scryfall asks for 50-100ms between requests
+	upstreamHostLimits = map[string]rateLimit{
+		"api.scryfall.com": {perSecond: 10, burst: 2},
+	}
+	defaultUpstreamLimit = rateLimit{perSecond: 10, burst: 5}
+
+	// The client everything talking to other sites should go through
+	upstream = newUpstreamClient()
+)
+
+// tokenBucket hands out tokens at a steady rate, holding at most burst of them.
+type tokenBucket struct {
+	mu     sync.Mutex
+	limit  rateLimit
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit rateLimit) *tokenBucket {
+	return &tokenBucket{limit: limit, tokens: limit.burst, last: time.Now()}
+}
+
+// reserve takes a token, returning how long to wait before it can be used.
+func (tb *tokenBucket) reserve(now time.Time) time.Duration {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	tb.tokens = math.Min(tb.limit.burst, tb.tokens+now.Sub(tb.last).Seconds()*tb.limit.perSecond)
+	tb.last = now
+	tb.tokens--
+	if tb.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-tb.tokens / tb.limit.perSecond * float64(time.Second))
+}
+
+// wait blocks until a token is available, or the context is done.
+func (tb *tokenBucket) wait(ctx context.Context) error {
+	delay := tb.reserve(time.Now())
+	if delay == 0 {
+		return nil
+	}
+	upstreamThrottled.Add(1)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// upstreamClient is a polite HTTP client: it paces requests per host, identifies itself,
+// gives up on slow responses and retries when the other end asks us to back off.
+type upstreamClient struct {
+	client     *http.Client
+	timeout    time.Duration
+	maxRetries int
+	backoff    time.Duration
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newUpstreamClient() *upstreamClient {
+	return &upstreamClient{
+		client:     &http.Client{},
+		timeout:    upstreamTimeout,
+		maxRetries: upstreamMaxRetries,
+		backoff:    upstreamBackoff,
+		buckets:    make(map[string]*tokenBucket),
+	}
+}
+
+func (u *upstreamClient) bucket(host string) *tokenBucket {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if tb, ok := u.buckets[host]; ok {
+		return tb
+	}
+	limit, ok := upstreamHostLimits[host]
+	if !ok {
+		limit = defaultUpstreamLimit
+	}
+	tb := newTokenBucket(limit)
+	u.buckets[host] = tb
+	return tb
+}
+
+// cancelOnClose releases a request's context once its body has been read.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+// Get fetches a URL with the default timeout.
+func (u *upstreamClient) Get(rawURL string) (*http.Response, error) {
+	return u.GetWithTimeout(rawURL, u.timeout)
+}
+
+// GetWithTimeout fetches a URL, giving up after timeout.
+// The timeout covers reading the body too, so it's cleaned up when the body is closed.
+func (u *upstreamClient) GetWithTimeout(rawURL string, timeout time.Duration) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	resp, err := u.do(ctx, rawURL)
+	if err != nil {
+		cancel()
+		upstreamFailures.Add(1)
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		upstreamFailures.Add(1)
+	}
+	resp.Body = cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func (u *upstreamClient) do(ctx context.Context, rawURL string) (*http.Response, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	tb := u.bucket(parsed.Host)
+	for attempt := 0; ; attempt++ {
+		if err := tb.wait(ctx); err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("User-Agent", upstreamUserAgent)
+		req.Header.Set("Accept", upstreamAccept)
+		upstreamRequests.Add(1)
+		resp, err := u.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			return resp, nil
+		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			upstreamThrottled.Add(1)
+		}
+		if attempt >= u.maxRetries {
+			return resp, nil
+		}
+		delay := u.retryDelay(resp, attempt)
+		resp.Body.Close()
+		log.Info("Upstream: Backing off", "URL", rawURL, "Status Code", resp.StatusCode, "Delay", delay)
+		upstreamRetries.Add(1)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("Gave up waiting to retry %s: %w", rawURL, ctx.Err())
+		}
+	}
+}
+
+// retryDelay doubles each attempt, unless the response tells us how long to wait.
+func (u *upstreamClient) retryDelay(resp *http.Response, attempt int) time.Duration {
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+		return min(time.Duration(seconds)*time.Second, upstreamMaxBackoff)
+	}
+	return min(u.backoff<<attempt, upstreamMaxBackoff)
+}
+
+// prefetcher runs background jobs, like warming the card cache, on a fixed number of workers.
+// If the queue is full the job is dropped, since it's only ever an optimisation.
+type prefetcher struct {
+	jobs chan func()
+}
+
+func newPrefetcher(workers, queueLength int) *prefetcher {
+	p := &prefetcher{jobs: make(chan func(), queueLength)}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range p.jobs {
+				job()
+			}
+		}()
+	}
+	return p
+}
+
+func (p *prefetcher) submit(job func()) bool {
+	select {
+	case p.jobs <- job:
+		return true
+	default:
+		prefetchDropped.Add(1)
+		return false
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	tb := newTokenBucket(rateLimit{perSecond: 10, burst: 2})
+	now := tb.last
+	tables := []struct {
+		after time.Duration
+		want  time.Duration
+	}{
+		{0, 0},
+		{0, 0},
+		{0, 100 * time.Millisecond},
+		{0, 200 * time.Millisecond},
+		{time.Second, 0},
+	}
+	for i, table := range tables {
+		now = now.Add(table.after)
+		if got := tb.reserve(now); got != table.want {
+			t.Errorf("Incorrect wait for reservation %d -- got %v -- want %v", i, got, table.want)
+		}
+	}
+}
+
+func TestUpstreamRetries(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != upstreamUserAgent || r.Header.Get("Accept") != upstreamAccept {
+			t.Errorf("Missing headers: %v", r.Header)
+		}
+		switch r.URL.Path {
+		case "/flaky":
+			if calls.Add(1) < 3 {
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.Write([]byte("ok"))
+		case "/broken":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		}
+	}))
+	defer ts.Close()
+
+	u := newUpstreamClient()
+	u.backoff = time.Millisecond
+
+	resp, err := u.Get(ts.URL + "/flaky")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || calls.Load() != 3 {
+		t.Errorf("Expected success on the third try -- got %v after %d calls", resp.StatusCode, calls.Load())
+	}
+
+	resp, err = u.Get(ts.URL + "/broken")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected the last failure to be returned -- got %v", resp.StatusCode)
+	}
+
+	if _, err = u.GetWithTimeout(ts.URL+"/slow", 50*time.Millisecond); err == nil {
+		t.Errorf("Expected a timeout")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	u := newUpstreamClient()
+	tables := []struct {
+		retryAfter string
+		attempt    int
+		want       time.Duration
+	}{
+		{"", 0, upstreamBackoff},
+		{"", 2, 4 * upstreamBackoff},
+		{"", 10, upstreamMaxBackoff},
+		{"1", 0, time.Second},
+		{"60", 0, upstreamMaxBackoff},
+	}
+	for _, table := range tables {
+		resp := &http.Response{Header: http.Header{}}
+		if table.retryAfter != "" {
+			resp.Header.Set("Retry-After", table.retryAfter)
+		}
+		if got := u.retryDelay(resp, table.attempt); got != table.want {
+			t.Errorf("Incorrect delay for %q/%d -- got %v -- want %v", table.retryAfter, table.attempt, got, table.want)
+		}
+	}
+}
+
+func TestPrefetcher(t *testing.T) {
+	p := newPrefetcher(1, 1)
+	block := make(chan struct{})
+	done := make(chan struct{})
+	p.submit(func() { <-block })
+	// Wait for the worker to pick up the first job, so the next one sits in the queue
+	for len(p.jobs) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if !p.submit(func() { close(done) }) {
+		t.Errorf("Expected the queue to have room")
+	}
+	if p.submit(func() {}) {
+		t.Errorf("Expected a full queue to drop the job")
+	}
+	close(block)
+	<-done
+}
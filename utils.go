@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/gob"
+	"encoding/json"
 	"expvar"
 	"fmt"
 	"os"
@@ -20,7 +21,7 @@ var (
 	//Pulling all regex here *should* make it all compile once and then be left alone
 
 	//Stuff pared from main.go
-	botCommandRegex      = regexp.MustCompile(`[!&]([^=!&?[)][^!&?[)]+)\.\s|[!&]([^=!&?[)][^!&?[)]+)|\[\[(.*?)\]\]`)
+	botCommandRegex      = regexp.MustCompile(`[!&]([^=!&?[)][^!&?[)]+)\.\s|[!&]([^=!&?[)][^!&?[)]+)|\[\[(.*?)\]\]|^![1-9]$`)
 	singleQuotedWord     = regexp.MustCompile(`^(?:"\w+"|'\w+')$`)
 	wordEndingInBang     = regexp.MustCompile(`!["'] |\n+`)
 	wordStartingWithBang = regexp.MustCompile(`\s+! *\S+`)
@@ -30,6 +31,7 @@ var (
 
 	cardMetadataRegex = regexp.MustCompile(`(?i)^(?:rulings?|reminder|flavou?r) `)
 
+	rulingPageRegex     = regexp.MustCompile(`(?i)^(.+?)\s+page\s+(\d+)$`)
 	gathererRulingRegex = regexp.MustCompile(`^(?:(?P<start_number>\d+) ?(?P<name>.+)|(?P<name2>.*?) ?(?P<end_number>\d+).*?|(?P<name3>.+))`)
 
 	seeRuleRegexp = regexp.MustCompile(`rule (\d+\.?\d*\w?)`)
@@ -71,10 +73,38 @@ var (
 	reminderRequests       = expvar.NewInt("bot_reminderRequests")
 	flavourRequests        = expvar.NewInt("bot_flavourRequests")
 	rulingRequests         = expvar.NewInt("bot_rulingRequests")
+	rulingMentionRequests  = expvar.NewInt("bot_rulingMentionRequests")
 	exampleRequests        = expvar.NewInt("bot_exampleRequests")
 	rulesRequests          = expvar.NewInt("bot_rulesRequests")
 	defineRequests         = expvar.NewInt("bot_defineRequests")
 	hearthstoneRequests    = expvar.NewInt("bot_hearthstoneRequests")
+	indexRequests          = expvar.NewInt("bot_indexRequests")
+	indexHits              = expvar.NewInt("bot_indexHits")
+	localSearchRequests    = expvar.NewInt("bot_localSearchRequests")
+	priceRequests          = expvar.NewInt("bot_priceRequests")
+	printingsRequests      = expvar.NewInt("bot_printingsRequests")
+	legalRequests          = expvar.NewInt("bot_legalRequests")
+	printingRequests       = expvar.NewInt("bot_printingRequests")
+	imageRequests          = expvar.NewInt("bot_imageRequests")
+	errataRequests         = expvar.NewInt("bot_errataRequests")
+	suggestionRequests     = expvar.NewInt("bot_suggestionRequests")
+	suggestionPicks        = expvar.NewInt("bot_suggestionPicks")
+	deckRequests           = expvar.NewInt("bot_deckRequests")
+	pointsRequests         = expvar.NewInt("bot_pointsRequests")
+	commanderRequests      = expvar.NewInt("bot_commanderRequests")
+	relatedRequests        = expvar.NewInt("bot_relatedRequests")
+	tokenRequests          = expvar.NewInt("bot_tokenRequests")
+	momirRequests          = expvar.NewInt("bot_momirRequests")
+	packRequests           = expvar.NewInt("bot_packRequests")
+	scheduledPosts         = expvar.NewInt("bot_scheduledPosts")
+	spoilersPosted         = expvar.NewInt("bot_spoilersPosted")
+	legalityChanges        = expvar.NewInt("bot_legalityChanges")
+	foreignLookups         = expvar.NewInt("bot_foreignLookups")
+	upstreamRequests       = expvar.NewInt("bot_upstreamRequests")
+	upstreamRetries        = expvar.NewInt("bot_upstreamRetries")
+	upstreamThrottled      = expvar.NewInt("bot_upstreamThrottled")
+	upstreamFailures       = expvar.NewInt("bot_upstreamFailures")
+	prefetchDropped        = expvar.NewInt("bot_prefetchDropped")
 )
 
 func sliceUniqMap(s []string) []string {
@@ -152,6 +182,30 @@ func readGob(filePath string, object interface{}) error {
 	return err
 }
 
+func readJSONFile(path string, v interface{}) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func writeJSONFile(path string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+// writeFileAtomically writes next to path and renames over it, so a crash mid-write can't leave it half written
+func writeFileAtomically(path string, b []byte) error {
+	if err := os.WriteFile(path+".tmp", b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
 func dumpCardCache(conf *configuration, cache *lru.ARCCache) error {
 	// Dump cache keys
 	log.Debug("Dumping card cache", "len", cache.Len())
@@ -211,16 +265,23 @@ func dumpCardCacheTimer(conf *configuration, cache *lru.ARCCache) {
 	}
 }
 
-func reduceCardSentence(tokens []string) []string {
+// cardCandidate is a name that might be a card, made from the first few tokens of a sentence
+type cardCandidate struct {
+	name   string
+	tokens int
+}
+
+// reduceCardSentence gives the names that could be at the start of the sentence, longest first
+func reduceCardSentence(tokens []string) []cardCandidate {
 	log.Debug("In ReduceCard -- Tokens were", "Tokens", tokens, "Length", len(tokens))
-	var ret []string
+	var ret []cardCandidate
 	for i := len(tokens); i >= 1; i-- {
 		msg := strings.Join(tokens[0:i], " ")
 		msg = noPunctuationRegex.ReplaceAllString(msg, "")
 		// Eliminate short names which are not valid and would match too much
 		if len(msg) > 2 {
 			log.Debug("Reverse descent", "i", i, "msg", msg)
-			ret = append(ret, msg)
+			ret = append(ret, cardCandidate{name: msg, tokens: i})
 		}
 	}
 	return ret
@@ -266,6 +327,45 @@ func nco(a string, b string) string {
 	return b
 }
 
+// Anything in a reply after this marker is too long for the channel,
+// and goes to the asker privately (IRC) or in a thread (Slack)
+const overflowMarker = "\x1Eoverflow\x1E"
+
+// splitOverflow separates what's for the channel from what isn't
+func splitOverflow(s string) (string, string) {
+	public, overflow, _ := strings.Cut(s, overflowMarker)
+	return public, overflow
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// levenshteinDistance is the number of single character edits to turn a into b
+func levenshteinDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
 func mergeIntStringMaps(new map[int]string, existing map[int]string) map[int]string {
 	for k, v := range new {
 		existing[k] = v
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -43,3 +44,12 @@ func TestICC(t *testing.T) {
 		}
 	}
 }
+
+func TestReduceCardSentence(t *testing.T) {
+	// A trailing comma or question mark isn't part of the name
+	got := reduceCardSentence(strings.Fields("Ancestral Recall, ok?"))
+	want := []cardCandidate{{"Ancestral Recall, ok", 3}, {"Ancestral Recall", 2}, {"Ancestral", 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Incorrect candidates -- got %+v -- want %+v", got, want)
+	}
+}